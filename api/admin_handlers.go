@@ -2,29 +2,236 @@ package api
 
 import (
 	"encoding/base64"
-	"github.com/skip2/go-qrcode"
+	"encoding/json"
 	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/pkg/errors"
+	"github.com/skip2/go-qrcode"
+	"go.uber.org/zap"
+
+	"mnemo/services/ws"
 )
 
-type createRoomResponse struct {
-	QRCode string `json:"qr_code"` // base64 PNG
+type createRoomRequest struct {
+	BankID string `json:"bank_id,omitempty"`
 }
 
-func (a *API) createQRCodeHandler(wr http.ResponseWriter, r *http.Request) {
+type roomResponse struct {
+	ID           string    `json:"id"` // short join code students type in
+	Professor    string    `json:"professor,omitempty"`
+	BankID       string    `json:"bank_id,omitempty"`
+	BankPosition int       `json:"bank_position,omitempty"`
+	BankTotal    int       `json:"bank_total,omitempty"`
+	JoinURL      string    `json:"join_url"`
+	QRCode       string    `json:"qr_code,omitempty"` // base64 PNG
+	Members      int       `json:"members"`
+	Participants int       `json:"participants"`
+	Dropped      uint64    `json:"dropped_events"` // events slow members missed
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type joinResponse struct {
+	Room  string `json:"room"`
+	WsURL string `json:"ws_url"`
+}
+
+func (a *API) createRoomHandler(wr http.ResponseWriter, r *http.Request) {
+	logger := a.log.With(zap.String("method", "createRoomHandler"))
+
+	instructor := instructorFromContext(r.Context())
+
+	// the body is optional; an empty one creates a room for live questions
+	var req createRoomRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			WriteJSON(wr, ResponseJSON{Status: http.StatusBadRequest, Message: "invalid request body", Errors: err.Error()}, http.StatusBadRequest)
+			return
+		}
+	}
+
+	if req.BankID != "" {
+		if _, ok := a.ownedBank(wr, r, req.BankID); !ok {
+			return
+		}
+	}
+
+	room, err := a.deps.WebsocketManager.CreateRoom(r.Context(), instructor.ID, req.BankID)
+	if errors.Is(err, ws.ErrEmptyBank) {
+		WriteJSON(wr, ResponseJSON{Status: http.StatusBadRequest, Message: err.Error()}, http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		logger.Error("unable to create room", zap.Error(err))
+		WriteJSON(wr, ResponseJSON{Status: http.StatusInternalServerError, Message: "failed to create room"}, http.StatusInternalServerError)
+		return
+	}
+
+	resp := a.newRoomResponse(room)
 
-	// TODO: dynamically generate from config
-	png, err := qrcode.Encode("localhost:8080/api/v1/join", qrcode.Medium, 256)
+	png, err := qrcode.Encode(resp.JoinURL, qrcode.Medium, 256)
 	if err != nil {
+		a.deps.WebsocketManager.RemoveRoom(room.ID)
 		WriteJSON(wr, ResponseJSON{Status: http.StatusInternalServerError, Message: "failed to generate QR"}, http.StatusInternalServerError)
 		return
 	}
 
-	resp := createRoomResponse{
-		QRCode: base64.StdEncoding.EncodeToString(png),
+	resp.QRCode = base64.StdEncoding.EncodeToString(png)
+
+	logger.Info("room created", zap.String("room", room.ID), zap.String("instructor", instructor.ID))
+	WriteJSON(wr, resp, http.StatusCreated)
+}
+
+func (a *API) listRoomsHandler(wr http.ResponseWriter, r *http.Request) {
+	instructor := instructorFromContext(r.Context())
+	rooms := a.deps.WebsocketManager.Rooms()
+
+	resp := make([]roomResponse, 0, len(rooms))
+	for _, room := range rooms {
+		if room.Professor != instructor.ID {
+			continue
+		}
+		resp = append(resp, a.newRoomResponse(room))
 	}
+
 	WriteJSON(wr, resp, http.StatusOK)
 }
 
-//func (a *API) joinHubHandler(wr http.ResponseWriter, r *http.Request) {
-//	ws.ServeWs(a.deps.WebsocketHubService, wr, r)
-//}
+func (a *API) getRoomHandler(wr http.ResponseWriter, r *http.Request) {
+	room, ok := a.ownedRoom(wr, r)
+	if !ok {
+		return
+	}
+
+	WriteJSON(wr, a.newRoomResponse(room), http.StatusOK)
+}
+
+func (a *API) deleteRoomHandler(wr http.ResponseWriter, r *http.Request) {
+	room, ok := a.ownedRoom(wr, r)
+	if !ok {
+		return
+	}
+
+	a.deps.WebsocketManager.RemoveRoom(room.ID)
+
+	a.log.Info("room deleted", zap.String("method", "deleteRoomHandler"), zap.String("room", room.ID))
+	wr.WriteHeader(http.StatusNoContent)
+}
+
+// forwardedHeader marks requests forwarded to the instance hosting a room.
+const forwardedHeader = "X-Mnemo-Forwarded"
+
+// ownedRoom looks up the room in the ":id" route param and checks that it
+// belongs to the authenticated instructor. Requests for rooms hosted by
+// another instance are forwarded there. On failure, or once the request is
+// forwarded, it writes the response and returns false.
+func (a *API) ownedRoom(wr http.ResponseWriter, r *http.Request) (*ws.Room, bool) {
+	id := httprouter.ParamsFromContext(r.Context()).ByName("id")
+
+	room, host, err := a.deps.WebsocketManager.LocateRoom(r.Context(), id)
+	switch {
+	case errors.Is(err, ws.ErrRoomNotFound):
+		WriteJSON(wr, ResponseJSON{Status: http.StatusNotFound, Message: "room not found"}, http.StatusNotFound)
+		return nil, false
+	case errors.Is(err, ws.ErrRoomNotHosted):
+		WriteJSON(wr, ResponseJSON{Status: http.StatusServiceUnavailable, Message: err.Error()}, http.StatusServiceUnavailable)
+		return nil, false
+	case err != nil:
+		a.internalError(wr, "ownedRoom", "unable to look up room", err)
+		return nil, false
+	}
+
+	professor := ""
+	if room != nil {
+		professor = room.Professor
+	} else {
+		professor = host.Professor
+	}
+
+	if professor != instructorFromContext(r.Context()).ID {
+		WriteJSON(wr, ResponseJSON{Status: http.StatusForbidden, Message: "room belongs to another instructor"}, http.StatusForbidden)
+		return nil, false
+	}
+
+	if host != nil {
+		a.forwardToHost(wr, r, host)
+		return nil, false
+	}
+
+	return room, true
+}
+
+// forwardToHost proxies the request to the instance hosting the room. The
+// host checks the instructor's session again, so the instances have to share
+// their auth secret.
+func (a *API) forwardToHost(wr http.ResponseWriter, r *http.Request, host *ws.RoomHost) {
+	// the room moved on while the request was forwarded; let the client
+	// retry rather than bouncing the request between instances
+	if r.Header.Get(forwardedHeader) != "" {
+		WriteJSON(wr, ResponseJSON{Status: http.StatusServiceUnavailable, Message: ws.ErrRoomNotHosted.Error()}, http.StatusServiceUnavailable)
+		return
+	}
+
+	proxy := httputil.NewSingleHostReverseProxy(&url.URL{Scheme: "http", Host: host.Addr})
+	proxy.ErrorHandler = func(wr http.ResponseWriter, r *http.Request, err error) {
+		a.log.Error("unable to forward request to room host", zap.String("host", host.Addr), zap.Error(err))
+		WriteJSON(wr, ResponseJSON{Status: http.StatusBadGateway, Message: "unable to reach room host"}, http.StatusBadGateway)
+	}
+
+	r.Header.Set(forwardedHeader, host.Instance)
+	proxy.ServeHTTP(wr, r)
+}
+
+// joinHandler is what the QR code points at; it resolves a join code into the
+// websocket URL the client should connect to.
+func (a *API) joinHandler(wr http.ResponseWriter, r *http.Request) {
+	id := ws.NormalizeRoomCode(httprouter.ParamsFromContext(r.Context()).ByName("id"))
+
+	// the room may be hosted by another instance, or be adopted from storage
+	// by this one
+	_, _, err := a.deps.WebsocketManager.LocateRoom(r.Context(), id)
+	if errors.Is(err, ws.ErrRoomNotFound) {
+		WriteJSON(wr, ResponseJSON{Status: http.StatusNotFound, Message: "room not found"}, http.StatusNotFound)
+		return
+	}
+	if err != nil && !errors.Is(err, ws.ErrRoomNotHosted) {
+		a.internalError(wr, "joinHandler", "unable to look up room", err)
+		return
+	}
+
+	base := a.publicURL()
+	wsURL := "ws" + strings.TrimPrefix(base, "http") + "/ws?room=" + id
+
+	WriteJSON(wr, joinResponse{Room: id, WsURL: wsURL}, http.StatusOK)
+}
+
+func (a *API) newRoomResponse(room *ws.Room) roomResponse {
+	bankID, position, total := room.Bank()
+
+	return roomResponse{
+		BankID:       bankID,
+		BankPosition: position,
+		BankTotal:    total,
+		ID:           room.ID,
+		Professor:    room.Professor,
+		JoinURL:      a.publicURL() + "/api/v1/join/" + room.ID,
+		Members:      room.Members(),
+		Participants: room.Participants(),
+		Dropped:      room.DroppedEvents(),
+		CreatedAt:    room.CreatedAt,
+	}
+}
+
+// publicURL returns the base URL students use to reach this server, without a
+// trailing slash.
+func (a *API) publicURL() string {
+	if a.config.PublicURL != "" {
+		return strings.TrimSuffix(a.config.PublicURL, "/")
+	}
+
+	return "http://" + a.config.APIListenAddress
+}
@@ -0,0 +1,181 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"mnemo/services/broker"
+	"mnemo/services/ws"
+)
+
+// request sends an API request with the given session token, if any.
+func request(t *testing.T, method, url, token string, header http.Header) *http.Response {
+	t.Helper()
+
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, url, err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+
+	return resp
+}
+
+func createRoom(t *testing.T, a *API) *ws.Room {
+	t.Helper()
+
+	room, err := a.deps.WebsocketManager.CreateRoom(context.Background(), testProfessor, "")
+	if err != nil {
+		t.Fatalf("CreateRoom: %v", err)
+	}
+
+	return room
+}
+
+func TestOwnedRoom(t *testing.T) {
+	store, bus := newTestStore(t), broker.NewMemory()
+
+	// the requests go to a; b hosts rooms of its own
+	a, server := newTestInstance(t, store, bus)
+	b, _ := newTestInstance(t, store, bus)
+
+	local, remote := createRoom(t, a), createRoom(t, b)
+
+	tests := []struct {
+		name       string
+		method     string
+		room       string
+		instructor string
+		header     http.Header
+		wantStatus int
+	}{
+		{"own room", http.MethodGet, local.ID, testProfessor, nil, http.StatusOK},
+		{"join code in lower case", http.MethodGet, strings.ToLower(local.ID), testProfessor, nil, http.StatusOK},
+		{"room of another instructor", http.MethodGet, local.ID, otherProfessor, nil, http.StatusForbidden},
+		{"no session", http.MethodGet, local.ID, "", nil, http.StatusUnauthorized},
+		{"unknown room", http.MethodGet, "NOPE00", testProfessor, nil, http.StatusNotFound},
+		{"own room on another instance", http.MethodGet, remote.ID, testProfessor, nil, http.StatusOK},
+		{"room of another instructor on another instance", http.MethodGet, remote.ID, otherProfessor, nil, http.StatusForbidden},
+		{"request forwarded once already", http.MethodGet, remote.ID, testProfessor, http.Header{forwardedHeader: {"elsewhere"}}, http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := ""
+			if tt.instructor != "" {
+				token = sessionToken(t, a, tt.instructor)
+			}
+
+			resp := request(t, tt.method, server.URL+"/api/v1/rooms/"+tt.room, token, tt.header)
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if resp.StatusCode != http.StatusOK {
+				return
+			}
+
+			var room roomResponse
+			if err := json.NewDecoder(resp.Body).Decode(&room); err != nil {
+				t.Fatalf("decoding room: %v", err)
+			}
+			if !strings.EqualFold(room.ID, tt.room) || room.Professor != testProfessor {
+				t.Errorf("room = %+v", room)
+			}
+		})
+	}
+
+	// the room stayed with its host rather than being adopted by a
+	if _, ok := a.deps.WebsocketManager.GetRoom(remote.ID); ok {
+		t.Error("request for a room hosted elsewhere moved the room")
+	}
+}
+
+func TestDeleteRoomOnAnotherInstance(t *testing.T) {
+	store, bus := newTestStore(t), broker.NewMemory()
+
+	a, server := newTestInstance(t, store, bus)
+	b, _ := newTestInstance(t, store, bus)
+
+	remote := createRoom(t, b)
+
+	resp := request(t, http.MethodDelete, server.URL+"/api/v1/rooms/"+remote.ID, sessionToken(t, a, testProfessor), nil)
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusNoContent)
+	}
+
+	if _, ok := b.deps.WebsocketManager.GetRoom(remote.ID); ok {
+		t.Error("host still has the room")
+	}
+}
+
+func TestForwardToUnreachableHost(t *testing.T) {
+	store, bus := newTestStore(t), broker.NewMemory()
+
+	a, server := newTestInstance(t, store, bus)
+	b, hostServer := newTestInstance(t, store, bus)
+
+	remote := createRoom(t, b)
+
+	// b still holds the lease but no longer answers
+	hostServer.Close()
+
+	resp := request(t, http.MethodGet, server.URL+"/api/v1/rooms/"+remote.ID, sessionToken(t, a, testProfessor), nil)
+	if resp.StatusCode != http.StatusBadGateway {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusBadGateway)
+	}
+}
+
+func TestJoinHandler(t *testing.T) {
+	store, bus := newTestStore(t), broker.NewMemory()
+
+	a, server := newTestInstance(t, store, bus)
+	b, _ := newTestInstance(t, store, bus)
+
+	local, remote := createRoom(t, a), createRoom(t, b)
+
+	tests := []struct {
+		name       string
+		room       string
+		wantStatus int
+		wantRoom   string
+	}{
+		{"room on this instance", local.ID, http.StatusOK, local.ID},
+		{"join code in lower case", strings.ToLower(local.ID), http.StatusOK, local.ID},
+		{"room on another instance", remote.ID, http.StatusOK, remote.ID},
+		{"unknown room", "NOPE00", http.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := request(t, http.MethodGet, server.URL+"/api/v1/join/"+tt.room, "", nil)
+			if resp.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if tt.wantRoom == "" {
+				return
+			}
+
+			var join joinResponse
+			if err := json.NewDecoder(resp.Body).Decode(&join); err != nil {
+				t.Fatalf("decoding join: %v", err)
+			}
+			if join.Room != tt.wantRoom || !strings.HasSuffix(join.WsURL, "/ws?room="+tt.wantRoom) {
+				t.Errorf("join = %+v", join)
+			}
+		})
+	}
+}
@@ -11,7 +11,6 @@ import (
 	"net"
 	"net/http"
 	_ "net/http/pprof"
-	"time"
 
 	"github.com/julienschmidt/httprouter"
 	"github.com/pkg/errors"
@@ -68,44 +67,84 @@ func New(cfg *config.Config, d *deps.Dependencies, version string) (*API, error)
 		log:     d.Log.With(zap.String("pkg", "api")),
 	}
 
-	// Run shutdown listener
-	go a.runShutdownListener()
-
 	return a, nil
 
 }
 
-func (a *API) runShutdownListener() {
-	<-a.deps.ShutdownCtx.Done()
-
-	// Give server 5s to shutdown gracefully
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+// Start listens on the API address and serves in the background until Stop
+// is called.
+func (a *API) Start(ctx context.Context) error {
+	logger := a.log.With(zap.String("method", "Start"))
 
-	if err := a.server.Shutdown(ctx); err != nil {
-		a.log.Error("Error shutting down API server", zap.Error(err))
-	}
-}
-
-func (a *API) Run() error {
-	logger := a.log.With(zap.String("method", "Run"))
-
-	router := httprouter.New()
+	router := metricsRouter{Router: httprouter.New(), metrics: a.deps.Metrics}
 
 	a.server.Handler = router
 
 	router.HandlerFunc(http.MethodGet, "/health-check", a.healthCheckHandler)
+	router.HandlerFunc(http.MethodGet, "/health/live", a.healthLiveHandler)
+	router.HandlerFunc(http.MethodGet, "/health/ready", a.healthReadyHandler)
 	router.HandlerFunc(http.MethodGet, "/version", a.versionHandler)
+	router.Handler(http.MethodGet, "/metrics", a.deps.Metrics.Handler())
 
 	router.HandlerFunc(http.MethodGet, "/ws", a.deps.WebsocketManager.ServeWs)
+	router.HandlerFunc(http.MethodGet, "/ws/review", a.deps.WebsocketManager.ServeReview)
+
+	// fallback transport for networks that break websockets
+	router.HandlerFunc(http.MethodGet, "/sse", a.deps.WebsocketManager.ServeSSE)
+	router.HandlerFunc(http.MethodGet, "/sse/review", a.deps.WebsocketManager.ServeSSEReview)
+	router.HandlerFunc(http.MethodPost, "/sse/send", a.deps.WebsocketManager.ServeSSEPost)
+
+	router.HandlerFunc(http.MethodPost, "/api/v1/auth/login", a.loginHandler)
+	router.HandlerFunc(http.MethodPost, "/api/v1/auth/logout", a.logoutHandler)
+	router.HandlerFunc(http.MethodGet, "/api/v1/auth/me", a.requireInstructor(a.meHandler))
+
+	router.HandlerFunc(http.MethodPost, "/api/v1/rooms", a.requireInstructor(a.createRoomHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/rooms", a.requireInstructor(a.listRoomsHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/rooms/:id", a.requireInstructor(a.getRoomHandler))
+	router.HandlerFunc(http.MethodDelete, "/api/v1/rooms/:id", a.requireInstructor(a.deleteRoomHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/rooms/:id/export", a.requireInstructor(a.exportRoomHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/join/:id", a.joinHandler)
+
+	router.HandlerFunc(http.MethodGet, "/api/v1/banks", a.requireInstructor(a.listBanksHandler))
+	router.HandlerFunc(http.MethodPost, "/api/v1/banks", a.requireInstructor(a.createBankHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/banks/:id", a.requireInstructor(a.getBankHandler))
+	router.HandlerFunc(http.MethodPut, "/api/v1/banks/:id", a.requireInstructor(a.updateBankHandler))
+	router.HandlerFunc(http.MethodDelete, "/api/v1/banks/:id", a.requireInstructor(a.deleteBankHandler))
+	router.HandlerFunc(http.MethodPost, "/api/v1/banks/:id/questions", a.requireInstructor(a.createBankQuestionHandler))
+	router.HandlerFunc(http.MethodPost, "/api/v1/banks/:id/import", a.requireInstructor(a.importBankHandler))
+	router.HandlerFunc(http.MethodPut, "/api/v1/banks/:id/questions/:question_id", a.requireInstructor(a.updateBankQuestionHandler))
+	router.HandlerFunc(http.MethodDelete, "/api/v1/banks/:id/questions/:question_id", a.requireInstructor(a.deleteBankQuestionHandler))
+
+	router.HandlerFunc(http.MethodGet, "/api/v1/students/:id/reviews/due", a.requireStudent(a.dueReviewsHandler))
+
 	// Maybe enable profiling
 	if a.config.EnablePprof {
 		router.Handler(http.MethodGet, "/debug/pprof/*item", http.DefaultServeMux)
 	}
 
+	listener, err := net.Listen("tcp", a.config.APIListenAddress)
+	if err != nil {
+		return errors.Wrap(err, "unable to listen")
+	}
+
 	logger.Info("API server running", zap.String("listenAddress", a.config.APIListenAddress))
 
-	return a.server.ListenAndServe()
+	go func() {
+		if err := a.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("API server failed", zap.Error(err))
+
+			// nothing left to serve, take the process down
+			a.deps.ShutdownCancel()
+		}
+	}()
+
+	return nil
+}
+
+// Stop stops accepting connections and waits for the requests in flight.
+// Hijacked (websocket) connections are left to the hub.
+func (a *API) Stop(ctx context.Context) error {
+	return a.server.Shutdown(ctx)
 }
 
 // WriteJSON is a helper function for writing JSON responses
@@ -1 +1,172 @@
 package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+
+	"mnemo/clog"
+	"mnemo/config"
+	"mnemo/deps"
+	"mnemo/services/auth"
+	"mnemo/services/broker"
+	"mnemo/services/ws"
+	"mnemo/storage"
+)
+
+const (
+	testProfessor  = "prof@example.com"
+	otherProfessor = "other@example.com"
+	testPassword   = "secret"
+)
+
+// testConfig is the server's default configuration.
+func testConfig() *config.Config {
+	return &config.Config{
+		RoomLeaseTTL:             10 * time.Second,
+		EgressQueueSize:          256,
+		EgressPolicy:             "evict",
+		ReplayBufferSize:         512,
+		ShutdownReconnectWindow:  5 * time.Second,
+		QuestionTickInterval:     time.Second,
+		ScoreBasePoints:          500,
+		ScoreSpeedBonus:          500,
+		ScoreSpeedWindow:         30 * time.Second,
+		ScoreStreakBonus:         0.1,
+		ScoreMaxStreakMultiplier: 2,
+		LeaderboardTopN:          5,
+		LeaderboardToStudents:    true,
+		WordCloudTerms:           50,
+		MoodWindow:               60 * time.Second,
+		MoodUpdateInterval:       2 * time.Second,
+		MoodReactionRate:         5,
+		ReviewSessionSize:        20,
+	}
+}
+
+// newTestStore opens a fresh SQLite database with testProfessor and
+// otherProfessor as instructors.
+func newTestStore(t *testing.T) *storage.SQLite {
+	t.Helper()
+
+	store, err := storage.NewSQLite(context.Background(), filepath.Join(t.TempDir(), "mnemo.db"))
+	if err != nil {
+		t.Fatalf("NewSQLite: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(testPassword), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("GenerateFromPassword: %v", err)
+	}
+
+	for _, email := range []string{testProfessor, otherProfessor} {
+		if err := store.SaveInstructor(context.Background(), &auth.Instructor{ID: email, Email: email, PasswordHash: hash}); err != nil {
+			t.Fatalf("SaveInstructor: %v", err)
+		}
+	}
+
+	return store
+}
+
+// newTestAPI returns an API with a started websocket manager. Instances
+// sharing store and bus form a cluster, like servers sharing a database and
+// Redis; they share their auth secret too. The API server itself is not
+// started; see newTestInstance.
+func newTestAPI(t *testing.T, cfg *config.Config, store storage.Repository, bus broker.Broker) *API {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	authService, err := auth.New(store, []byte("test-secret"), time.Hour)
+	if err != nil {
+		t.Fatalf("auth.New: %v", err)
+	}
+
+	log := clog.New(zap.NewNop())
+
+	manager, err := ws.NewManager(cfg, log, authService, store, bus)
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+
+	if err := manager.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	t.Cleanup(func() {
+		cancel()
+
+		// tests connect no clients, so there is nothing to wait for
+		stopped, stop := context.WithCancel(context.Background())
+		stop()
+		manager.Stop(stopped)
+	})
+
+	d := &deps.Dependencies{
+		WebsocketManager: manager,
+		Auth:             authService,
+		Storage:          store,
+		Broker:           bus,
+		ShutdownCtx:      ctx,
+		ShutdownCancel:   cancel,
+		Config:           cfg,
+		Log:              log,
+	}
+
+	return &API{
+		config: cfg,
+		deps:   d,
+		log:    log.With(zap.String("pkg", "api")),
+	}
+}
+
+// newTestInstance starts an API server; see newTestAPI. The server's address
+// is what the instance advertises to the others in its room leases.
+func newTestInstance(t *testing.T, store storage.Repository, bus broker.Broker) (*API, *httptest.Server) {
+	t.Helper()
+
+	server := httptest.NewUnstartedServer(nil)
+
+	cfg := testConfig()
+	cfg.APIListenAddress = server.Listener.Addr().String()
+
+	a := newTestAPI(t, cfg, store, bus)
+
+	server.Config.Handler = testRoutes(a)
+	server.Start()
+	t.Cleanup(server.Close)
+
+	return a, server
+}
+
+// testRoutes registers the handlers under test like Start does.
+func testRoutes(a *API) http.Handler {
+	router := httprouter.New()
+
+	router.HandlerFunc(http.MethodPost, "/api/v1/rooms", a.requireInstructor(a.createRoomHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/rooms/:id", a.requireInstructor(a.getRoomHandler))
+	router.HandlerFunc(http.MethodDelete, "/api/v1/rooms/:id", a.requireInstructor(a.deleteRoomHandler))
+	router.HandlerFunc(http.MethodGet, "/api/v1/join/:id", a.joinHandler)
+
+	return router
+}
+
+// sessionToken issues a session token for the instructor.
+func sessionToken(t *testing.T, a *API, instructor string) string {
+	t.Helper()
+
+	token, _, err := a.deps.Auth.IssueToken(instructor)
+	if err != nil {
+		t.Fatalf("IssueToken: %v", err)
+	}
+
+	return token
+}
@@ -0,0 +1,77 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"mnemo/services/auth"
+)
+
+type loginRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+func (a *API) loginHandler(wr http.ResponseWriter, r *http.Request) {
+	logger := a.log.With(zap.String("method", "loginHandler"))
+
+	var req loginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteJSON(wr, ResponseJSON{Status: http.StatusBadRequest, Message: "invalid request body", Errors: err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	session, err := a.deps.Auth.Login(req.Email, req.Password)
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidCredentials) {
+			logger.Info("failed login attempt", zap.String("email", auth.NormalizeEmail(req.Email)), zap.String("remoteAddr", r.RemoteAddr))
+			WriteJSON(wr, ResponseJSON{Status: http.StatusUnauthorized, Message: err.Error()}, http.StatusUnauthorized)
+			return
+		}
+
+		logger.Error("unable to log in", zap.Error(err))
+		WriteJSON(wr, ResponseJSON{Status: http.StatusInternalServerError, Message: "unable to log in"}, http.StatusInternalServerError)
+		return
+	}
+
+	http.SetCookie(wr, &http.Cookie{
+		Name:     auth.SessionCookie,
+		Value:    session.Token,
+		Path:     "/",
+		Expires:  session.ExpiresAt,
+		HttpOnly: true,
+		Secure:   a.secureCookies(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	WriteJSON(wr, session, http.StatusOK)
+}
+
+func (a *API) logoutHandler(wr http.ResponseWriter, r *http.Request) {
+	http.SetCookie(wr, &http.Cookie{
+		Name:     auth.SessionCookie,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   a.secureCookies(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	wr.WriteHeader(http.StatusNoContent)
+}
+
+// secureCookies reports whether the session cookie is limited to HTTPS: when
+// the request came in over TLS or students reach the server under an https
+// URL, e.g. behind a proxy terminating TLS.
+func (a *API) secureCookies(r *http.Request) bool {
+	return r.TLS != nil || strings.HasPrefix(strings.ToLower(a.config.PublicURL), "https://")
+}
+
+func (a *API) meHandler(wr http.ResponseWriter, r *http.Request) {
+	WriteJSON(wr, instructorFromContext(r.Context()), http.StatusOK)
+}
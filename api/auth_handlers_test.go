@@ -0,0 +1,116 @@
+package api
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"mnemo/services/auth"
+	"mnemo/services/broker"
+)
+
+func TestLoginHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{"valid credentials", `{"email":"Prof@example.com","password":"secret"}`, http.StatusOK},
+		{"wrong password", `{"email":"prof@example.com","password":"guess"}`, http.StatusUnauthorized},
+		{"unknown instructor", `{"email":"nobody@example.com","password":"secret"}`, http.StatusUnauthorized},
+		{"invalid body", `{"email":`, http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestAPI(t, testConfig(), newTestStore(t), broker.NewMemory())
+
+			w := httptest.NewRecorder()
+			a.loginHandler(w, httptest.NewRequest(http.MethodPost, "/api/v1/auth/login", strings.NewReader(tt.body)))
+
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.wantStatus, w.Body)
+			}
+
+			cookie := sessionCookie(w)
+			if (cookie != nil) != (tt.wantStatus == http.StatusOK) {
+				t.Fatalf("session cookie = %v, want one: %v", cookie, tt.wantStatus == http.StatusOK)
+			}
+			if cookie == nil {
+				return
+			}
+
+			if !cookie.HttpOnly {
+				t.Error("session cookie is readable by scripts")
+			}
+
+			instructor, err := a.deps.Auth.Authenticate(cookie.Value)
+			if err != nil {
+				t.Fatalf("Authenticate: %v", err)
+			}
+			if instructor.ID != testProfessor {
+				t.Errorf("instructor = %s, want %s", instructor.ID, testProfessor)
+			}
+		})
+	}
+}
+
+func TestSessionCookieSecure(t *testing.T) {
+	tests := []struct {
+		name      string
+		publicURL string
+		tls       bool
+		want      bool
+	}{
+		{"plain HTTP", "http://mnemo.example.com", false, false},
+		{"no public URL", "", false, false},
+		{"TLS request", "", true, true},
+		{"https public URL", "https://mnemo.example.com", false, true},
+		{"https public URL in upper case", "HTTPS://mnemo.example.com", false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig()
+			cfg.PublicURL = tt.publicURL
+
+			a := newTestAPI(t, cfg, newTestStore(t), broker.NewMemory())
+
+			handlers := map[string]http.HandlerFunc{
+				"login":  a.loginHandler,
+				"logout": a.logoutHandler,
+			}
+
+			for name, handler := range handlers {
+				r := httptest.NewRequest(http.MethodPost, "/api/v1/auth/"+name, strings.NewReader(`{"email":"prof@example.com","password":"secret"}`))
+				if tt.tls {
+					r.TLS = &tls.ConnectionState{}
+				}
+
+				w := httptest.NewRecorder()
+				handler(w, r)
+
+				cookie := sessionCookie(w)
+				if cookie == nil {
+					t.Fatalf("%s set no session cookie", name)
+				}
+				if cookie.Secure != tt.want {
+					t.Errorf("%s cookie secure = %v, want %v", name, cookie.Secure, tt.want)
+				}
+			}
+		})
+	}
+}
+
+// sessionCookie returns the session cookie the response sets, if any.
+func sessionCookie(w *httptest.ResponseRecorder) *http.Cookie {
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == auth.SessionCookie {
+			return cookie
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,270 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"mnemo/services/quiz"
+	"mnemo/storage"
+)
+
+type bankRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+type bankResponse struct {
+	*storage.Bank
+	Questions []*storage.BankQuestion `json:"questions,omitempty"`
+}
+
+func (a *API) listBanksHandler(wr http.ResponseWriter, r *http.Request) {
+	banks, err := a.deps.Storage.ListBanks(r.Context(), instructorFromContext(r.Context()).ID)
+	if err != nil {
+		a.internalError(wr, "listBanksHandler", "unable to list banks", err)
+		return
+	}
+
+	WriteJSON(wr, banks, http.StatusOK)
+}
+
+func (a *API) createBankHandler(wr http.ResponseWriter, r *http.Request) {
+	req, ok := decodeBankRequest(wr, r)
+	if !ok {
+		return
+	}
+
+	id, err := storage.NewID()
+	if err != nil {
+		a.internalError(wr, "createBankHandler", "unable to create bank", err)
+		return
+	}
+
+	now := time.Now()
+	bank := &storage.Bank{
+		ID:          id,
+		Owner:       instructorFromContext(r.Context()).ID,
+		Name:        req.Name,
+		Description: req.Description,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	if err := a.deps.Storage.CreateBank(r.Context(), bank); err != nil {
+		a.internalError(wr, "createBankHandler", "unable to create bank", err)
+		return
+	}
+
+	WriteJSON(wr, bank, http.StatusCreated)
+}
+
+func (a *API) getBankHandler(wr http.ResponseWriter, r *http.Request) {
+	bank, ok := a.ownedBank(wr, r, bankIDParam(r))
+	if !ok {
+		return
+	}
+
+	questions, err := a.deps.Storage.ListBankQuestions(r.Context(), bank.ID)
+	if err != nil {
+		a.internalError(wr, "getBankHandler", "unable to list bank questions", err)
+		return
+	}
+
+	WriteJSON(wr, bankResponse{Bank: bank, Questions: questions}, http.StatusOK)
+}
+
+func (a *API) updateBankHandler(wr http.ResponseWriter, r *http.Request) {
+	bank, ok := a.ownedBank(wr, r, bankIDParam(r))
+	if !ok {
+		return
+	}
+
+	req, ok := decodeBankRequest(wr, r)
+	if !ok {
+		return
+	}
+
+	bank.Name = req.Name
+	bank.Description = req.Description
+	bank.UpdatedAt = time.Now()
+
+	if err := a.deps.Storage.UpdateBank(r.Context(), bank); err != nil {
+		a.internalError(wr, "updateBankHandler", "unable to update bank", err)
+		return
+	}
+
+	WriteJSON(wr, bank, http.StatusOK)
+}
+
+func (a *API) deleteBankHandler(wr http.ResponseWriter, r *http.Request) {
+	bank, ok := a.ownedBank(wr, r, bankIDParam(r))
+	if !ok {
+		return
+	}
+
+	if err := a.deps.Storage.DeleteBank(r.Context(), bank.ID); err != nil {
+		a.internalError(wr, "deleteBankHandler", "unable to delete bank", err)
+		return
+	}
+
+	wr.WriteHeader(http.StatusNoContent)
+}
+
+func (a *API) createBankQuestionHandler(wr http.ResponseWriter, r *http.Request) {
+	bank, ok := a.ownedBank(wr, r, bankIDParam(r))
+	if !ok {
+		return
+	}
+
+	question, ok := decodeBankQuestion(wr, r)
+	if !ok {
+		return
+	}
+
+	id, err := storage.NewID()
+	if err != nil {
+		a.internalError(wr, "createBankQuestionHandler", "unable to create question", err)
+		return
+	}
+
+	question.ID = id
+	question.BankID = bank.ID
+
+	if err := a.deps.Storage.CreateBankQuestion(r.Context(), question); err != nil {
+		a.internalError(wr, "createBankQuestionHandler", "unable to create question", err)
+		return
+	}
+
+	WriteJSON(wr, question, http.StatusCreated)
+}
+
+func (a *API) updateBankQuestionHandler(wr http.ResponseWriter, r *http.Request) {
+	bank, ok := a.ownedBank(wr, r, bankIDParam(r))
+	if !ok {
+		return
+	}
+
+	existing, err := a.deps.Storage.GetBankQuestion(r.Context(), bank.ID, questionIDParam(r))
+	if errors.Is(err, storage.ErrNotFound) {
+		WriteJSON(wr, ResponseJSON{Status: http.StatusNotFound, Message: "question not found"}, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		a.internalError(wr, "updateBankQuestionHandler", "unable to get question", err)
+		return
+	}
+
+	question, ok := decodeBankQuestion(wr, r)
+	if !ok {
+		return
+	}
+
+	question.ID = existing.ID
+	question.BankID = existing.BankID
+	question.Position = existing.Position
+
+	if err := a.deps.Storage.UpdateBankQuestion(r.Context(), question); err != nil {
+		a.internalError(wr, "updateBankQuestionHandler", "unable to update question", err)
+		return
+	}
+
+	WriteJSON(wr, question, http.StatusOK)
+}
+
+func (a *API) deleteBankQuestionHandler(wr http.ResponseWriter, r *http.Request) {
+	bank, ok := a.ownedBank(wr, r, bankIDParam(r))
+	if !ok {
+		return
+	}
+
+	err := a.deps.Storage.DeleteBankQuestion(r.Context(), bank.ID, questionIDParam(r))
+	if errors.Is(err, storage.ErrNotFound) {
+		WriteJSON(wr, ResponseJSON{Status: http.StatusNotFound, Message: "question not found"}, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		a.internalError(wr, "deleteBankQuestionHandler", "unable to delete question", err)
+		return
+	}
+
+	wr.WriteHeader(http.StatusNoContent)
+}
+
+// ownedBank loads the bank and checks that it belongs to the authenticated
+// instructor. On failure it writes the error response and returns false.
+func (a *API) ownedBank(wr http.ResponseWriter, r *http.Request, id string) (*storage.Bank, bool) {
+	bank, err := a.deps.Storage.GetBank(r.Context(), id)
+	if errors.Is(err, storage.ErrNotFound) {
+		WriteJSON(wr, ResponseJSON{Status: http.StatusNotFound, Message: "bank not found"}, http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		a.internalError(wr, "ownedBank", "unable to get bank", err)
+		return nil, false
+	}
+
+	if bank.Owner != instructorFromContext(r.Context()).ID {
+		WriteJSON(wr, ResponseJSON{Status: http.StatusForbidden, Message: "bank belongs to another instructor"}, http.StatusForbidden)
+		return nil, false
+	}
+
+	return bank, true
+}
+
+func (a *API) internalError(wr http.ResponseWriter, method, message string, err error) {
+	a.log.Error(message, zap.String("method", method), zap.Error(err))
+	WriteJSON(wr, ResponseJSON{Status: http.StatusInternalServerError, Message: message}, http.StatusInternalServerError)
+}
+
+func decodeBankRequest(wr http.ResponseWriter, r *http.Request) (*bankRequest, bool) {
+	var req bankRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		WriteJSON(wr, ResponseJSON{Status: http.StatusBadRequest, Message: "invalid request body", Errors: err.Error()}, http.StatusBadRequest)
+		return nil, false
+	}
+
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		WriteJSON(wr, ResponseJSON{Status: http.StatusBadRequest, Message: "name cannot be empty"}, http.StatusBadRequest)
+		return nil, false
+	}
+
+	return &req, true
+}
+
+func decodeBankQuestion(wr http.ResponseWriter, r *http.Request) (*storage.BankQuestion, bool) {
+	var question storage.BankQuestion
+	if err := json.NewDecoder(r.Body).Decode(&question); err != nil {
+		WriteJSON(wr, ResponseJSON{Status: http.StatusBadRequest, Message: "invalid request body", Errors: err.Error()}, http.StatusBadRequest)
+		return nil, false
+	}
+
+	if question.Tags == nil {
+		question.Tags = []string{}
+	}
+
+	if err := question.Validate(); err != nil {
+		status := http.StatusBadRequest
+		if !errors.Is(err, quiz.ErrInvalidQuestion) {
+			status = http.StatusInternalServerError
+		}
+		WriteJSON(wr, ResponseJSON{Status: status, Message: "invalid question", Errors: err.Error()}, status)
+		return nil, false
+	}
+
+	return &question, true
+}
+
+func bankIDParam(r *http.Request) string {
+	return httprouter.ParamsFromContext(r.Context()).ByName("id")
+}
+
+func questionIDParam(r *http.Request) string {
+	return httprouter.ParamsFromContext(r.Context()).ByName("question_id")
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"github.com/InVisionApp/go-health"
 	"go.uber.org/zap"
 )
 
@@ -20,6 +21,56 @@ func (a *API) healthCheckHandler(wr http.ResponseWriter, r *http.Request) {
 	wr.Write([]byte(body))
 }
 
+// HealthJSON is the body of the liveness and readiness endpoints.
+type HealthJSON struct {
+	Status string                  `json:"status"`
+	Checks map[string]health.State `json:"checks"`
+}
+
+// healthLiveHandler fails only when a fatal check does, i.e. when the process
+// should be restarted.
+func (a *API) healthLiveHandler(wr http.ResponseWriter, r *http.Request) {
+	states, failed, err := a.deps.Health.State()
+	if err != nil {
+		a.internalError(wr, "healthLiveHandler", "unable to fetch health states", err)
+		return
+	}
+
+	writeHealth(wr, states, !failed)
+}
+
+// healthReadyHandler fails when any check does, or before every check has
+// reported, so no traffic is routed to an instance that cannot serve it.
+func (a *API) healthReadyHandler(wr http.ResponseWriter, r *http.Request) {
+	states, _, err := a.deps.Health.State()
+	if err != nil {
+		a.internalError(wr, "healthReadyHandler", "unable to fetch health states", err)
+		return
+	}
+
+	ready := len(states) > 0 && a.deps.ShutdownCtx.Err() == nil
+
+	for _, state := range states {
+		if state.Status != "ok" {
+			ready = false
+		}
+	}
+
+	writeHealth(wr, states, ready)
+}
+
+func writeHealth(wr http.ResponseWriter, states map[string]health.State, ok bool) {
+	response := HealthJSON{Status: "ok", Checks: states}
+	status := http.StatusOK
+
+	if !ok {
+		response.Status = "failed"
+		status = http.StatusServiceUnavailable
+	}
+
+	WriteJSON(wr, response, status)
+}
+
 func (a *API) versionHandler(rw http.ResponseWriter, r *http.Request) {
 	logger := a.log.With(zap.String("method", "versionHandler"))
 	logger.Info("handling /version request", zap.String("remoteAddr", r.RemoteAddr))
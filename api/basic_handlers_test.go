@@ -0,0 +1,124 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/InVisionApp/go-health"
+	"github.com/pkg/errors"
+
+	"mnemo/services/broker"
+)
+
+// testHealth reports fixed check states.
+type testHealth struct {
+	health.IHealth
+
+	states map[string]health.State
+	failed bool // a fatal check failed
+	err    error
+}
+
+func (h *testHealth) State() (map[string]health.State, bool, error) {
+	return h.states, h.failed, h.err
+}
+
+func TestHealthHandlers(t *testing.T) {
+	ok := health.State{Name: "storage", Status: "ok"}
+	failed := health.State{Name: "broker", Status: "failed", Err: "connection refused"}
+	fatal := health.State{Name: "ws-manager", Status: "failed", Fatal: true}
+
+	tests := []struct {
+		name         string
+		health       *testHealth
+		shuttingDown bool
+		wantLive     int
+		wantReady    int
+	}{
+		{
+			name:      "all checks pass",
+			health:    &testHealth{states: map[string]health.State{"storage": ok}},
+			wantLive:  http.StatusOK,
+			wantReady: http.StatusOK,
+		},
+		{
+			name:      "no check has reported yet",
+			health:    &testHealth{states: map[string]health.State{}},
+			wantLive:  http.StatusOK,
+			wantReady: http.StatusServiceUnavailable,
+		},
+		{
+			name:      "a backend is down",
+			health:    &testHealth{states: map[string]health.State{"storage": ok, "broker": failed}},
+			wantLive:  http.StatusOK,
+			wantReady: http.StatusServiceUnavailable,
+		},
+		{
+			name:      "a fatal check failed",
+			health:    &testHealth{states: map[string]health.State{"storage": ok, "ws-manager": fatal}, failed: true},
+			wantLive:  http.StatusServiceUnavailable,
+			wantReady: http.StatusServiceUnavailable,
+		},
+		{
+			name:         "shutting down",
+			health:       &testHealth{states: map[string]health.State{"storage": ok}},
+			shuttingDown: true,
+			wantLive:     http.StatusOK,
+			wantReady:    http.StatusServiceUnavailable,
+		},
+		{
+			name:      "states unavailable",
+			health:    &testHealth{err: errors.New("not started")},
+			wantLive:  http.StatusInternalServerError,
+			wantReady: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestAPI(t, testConfig(), newTestStore(t), broker.NewMemory())
+			a.deps.Health = tt.health
+
+			if tt.shuttingDown {
+				a.deps.ShutdownCancel()
+			}
+
+			handlers := []struct {
+				path       string
+				handler    http.HandlerFunc
+				wantStatus int
+			}{
+				{"/health/live", a.healthLiveHandler, tt.wantLive},
+				{"/health/ready", a.healthReadyHandler, tt.wantReady},
+			}
+
+			for _, h := range handlers {
+				rec := httptest.NewRecorder()
+				h.handler(rec, httptest.NewRequest(http.MethodGet, h.path, nil))
+
+				if rec.Code != h.wantStatus {
+					t.Errorf("%s = %d, want %d", h.path, rec.Code, h.wantStatus)
+					continue
+				}
+				if rec.Code == http.StatusInternalServerError {
+					continue
+				}
+
+				var response HealthJSON
+				if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+					t.Fatalf("decoding %s: %v", h.path, err)
+				}
+
+				wantStatus := "ok"
+				if h.wantStatus != http.StatusOK {
+					wantStatus = "failed"
+				}
+				if response.Status != wantStatus || len(response.Checks) != len(tt.health.states) {
+					t.Errorf("%s = %+v", h.path, response)
+				}
+			}
+		})
+	}
+}
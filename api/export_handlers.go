@@ -0,0 +1,55 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"mnemo/services/gradebook"
+	"mnemo/services/ws"
+	"mnemo/storage"
+)
+
+// exportRoomHandler downloads the room's gradebook. It reads from storage so
+// rooms that were already closed can still be exported.
+func (a *API) exportRoomHandler(wr http.ResponseWriter, r *http.Request) {
+	logger := a.log.With(zap.String("method", "exportRoomHandler"))
+
+	format := gradebook.FormatCSV
+	if v := r.URL.Query().Get("format"); v != "" {
+		var err error
+		if format, err = gradebook.ParseFormat(v); err != nil {
+			WriteJSON(wr, ResponseJSON{Status: http.StatusBadRequest, Message: "format must be csv, xlsx or json"}, http.StatusBadRequest)
+			return
+		}
+	}
+
+	roomID := ws.NormalizeRoomCode(httprouter.ParamsFromContext(r.Context()).ByName("id"))
+
+	book, err := gradebook.Build(r.Context(), a.deps.Storage, roomID)
+	if errors.Is(err, storage.ErrNotFound) {
+		WriteJSON(wr, ResponseJSON{Status: http.StatusNotFound, Message: "room not found"}, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		a.internalError(wr, "exportRoomHandler", "unable to build gradebook", err)
+		return
+	}
+
+	if book.Professor != instructorFromContext(r.Context()).ID {
+		WriteJSON(wr, ResponseJSON{Status: http.StatusForbidden, Message: "room belongs to another instructor"}, http.StatusForbidden)
+		return
+	}
+
+	wr.Header().Set("Content-Type", format.ContentType())
+	wr.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"mnemo-%s.%s\"", book.Room, format))
+	wr.WriteHeader(http.StatusOK)
+
+	// headers are out, so all we can do on failure is log it
+	if err := book.Write(format, wr); err != nil {
+		logger.Error("unable to write gradebook", zap.String("room", book.Room), zap.Error(err))
+	}
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"io"
+	"net/http"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"mnemo/services/importer"
+)
+
+// MaxImportSize caps the size of an uploaded question file.
+const MaxImportSize = 10 << 20
+
+type importResponse struct {
+	Imported int `json:"imported"`
+	*importer.Result
+}
+
+// importBankHandler appends questions from a GIFT, Aiken or Moodle XML file to
+// the bank. The file is either the raw request body or a multipart "file"
+// field; ?format= overrides detection from the file name. Questions that fail
+// to parse are reported with their line number and the rest are imported.
+func (a *API) importBankHandler(wr http.ResponseWriter, r *http.Request) {
+	logger := a.log.With(zap.String("method", "importBankHandler"))
+
+	bank, ok := a.ownedBank(wr, r, bankIDParam(r))
+	if !ok {
+		return
+	}
+
+	r.Body = http.MaxBytesReader(wr, r.Body, MaxImportSize)
+
+	var (
+		body     io.Reader = r.Body
+		filename string
+	)
+
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			WriteJSON(wr, ResponseJSON{Status: http.StatusBadRequest, Message: "missing file field", Errors: err.Error()}, http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+
+		body = file
+		filename = header.Filename
+	}
+
+	format := importer.Format(r.URL.Query().Get("format"))
+	if format == "" {
+		detected, ok := importer.DetectFormat(filename)
+		if !ok {
+			WriteJSON(wr, ResponseJSON{Status: http.StatusBadRequest, Message: "unable to detect format, set ?format=gift|aiken|moodle-xml"}, http.StatusBadRequest)
+			return
+		}
+		format = detected
+	}
+
+	result, err := importer.Parse(format, body)
+	if err != nil {
+		WriteJSON(wr, ResponseJSON{Status: http.StatusBadRequest, Message: "unable to parse file", Errors: err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	if err := importer.Save(r.Context(), a.deps.Storage, bank.ID, result.Questions); err != nil {
+		a.internalError(wr, "importBankHandler", "unable to save questions", err)
+		return
+	}
+
+	logger.Info("questions imported",
+		zap.String("bank", bank.ID),
+		zap.String("format", string(format)),
+		zap.Int("imported", len(result.Questions)),
+		zap.Int("errors", len(result.Errors)),
+	)
+
+	WriteJSON(wr, importResponse{Imported: len(result.Questions), Result: result}, http.StatusOK)
+}
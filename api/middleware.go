@@ -0,0 +1,92 @@
+package api
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/pkg/errors"
+
+	"mnemo/services/auth"
+	"mnemo/services/metrics"
+	"mnemo/services/ws"
+	"mnemo/storage"
+)
+
+type contextKey string
+
+const (
+	instructorContextKey contextKey = "instructor"
+	studentContextKey    contextKey = "student"
+)
+
+// requireInstructor rejects requests without a valid instructor session and
+// makes the authenticated instructor available via instructorFromContext.
+func (a *API) requireInstructor(next http.HandlerFunc) http.HandlerFunc {
+	return func(wr http.ResponseWriter, r *http.Request) {
+		token := auth.TokenFromRequest(r)
+		if token == "" {
+			WriteJSON(wr, ResponseJSON{Status: http.StatusUnauthorized, Message: "missing session token"}, http.StatusUnauthorized)
+			return
+		}
+
+		instructor, err := a.deps.Auth.Authenticate(token)
+		if err != nil {
+			WriteJSON(wr, ResponseJSON{Status: http.StatusUnauthorized, Message: "invalid session token", Errors: err.Error()}, http.StatusUnauthorized)
+			return
+		}
+
+		next(wr, r.WithContext(context.WithValue(r.Context(), instructorContextKey, instructor)))
+	}
+}
+
+// requireStudent rejects requests that don't carry the token of the student
+// in the ":id" route param and makes the student available via
+// studentFromContext.
+func (a *API) requireStudent(next http.HandlerFunc) http.HandlerFunc {
+	return func(wr http.ResponseWriter, r *http.Request) {
+		token := ws.StudentTokenFromRequest(r)
+		if token == "" {
+			WriteJSON(wr, ResponseJSON{Status: http.StatusUnauthorized, Message: "missing student token"}, http.StatusUnauthorized)
+			return
+		}
+
+		student, err := a.deps.WebsocketManager.AuthenticateStudent(r.Context(), token)
+		if errors.Is(err, ws.ErrUnknownStudent) {
+			WriteJSON(wr, ResponseJSON{Status: http.StatusUnauthorized, Message: "invalid student token"}, http.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			a.internalError(wr, "requireStudent", "unable to authenticate student", err)
+			return
+		}
+
+		if student.ID != httprouter.ParamsFromContext(r.Context()).ByName("id") {
+			WriteJSON(wr, ResponseJSON{Status: http.StatusForbidden, Message: "token belongs to another student"}, http.StatusForbidden)
+			return
+		}
+
+		next(wr, r.WithContext(context.WithValue(r.Context(), studentContextKey, student)))
+	}
+}
+
+func studentFromContext(ctx context.Context) *storage.Student {
+	student, _ := ctx.Value(studentContextKey).(*storage.Student)
+	return student
+}
+
+func instructorFromContext(ctx context.Context) *auth.Instructor {
+	instructor, _ := ctx.Value(instructorContextKey).(*auth.Instructor)
+	return instructor
+}
+
+// metricsRouter registers every handler with the HTTP request metrics
+// middleware, labelled by its route pattern.
+type metricsRouter struct {
+	*httprouter.Router
+	metrics *metrics.Metrics
+}
+
+func (r metricsRouter) HandlerFunc(method, path string, handler http.HandlerFunc) {
+	r.Router.HandlerFunc(method, path, r.metrics.Instrument(path, handler))
+}
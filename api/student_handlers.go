@@ -0,0 +1,60 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"mnemo/services/quiz"
+	"mnemo/services/srs"
+)
+
+type dueReview struct {
+	srs.Card
+
+	BankID   string         `json:"bank_id"`
+	Question *quiz.Question `json:"question"`
+}
+
+type dueReviewsResponse struct {
+	StudentID string      `json:"student_id"`
+	Due       []dueReview `json:"due"`
+}
+
+// dueReviewsHandler lists the questions the student should review now, most
+// overdue first. ?limit= caps the number returned.
+func (a *API) dueReviewsHandler(wr http.ResponseWriter, r *http.Request) {
+	student := studentFromContext(r.Context())
+
+	limit := 0
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			WriteJSON(wr, ResponseJSON{Status: http.StatusBadRequest, Message: "limit must be a non-negative integer"}, http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	due, err := a.deps.Storage.ListDueReviews(r.Context(), student.ID, time.Now(), limit)
+	if err != nil {
+		a.internalError(wr, "dueReviewsHandler", "unable to list due reviews", err)
+		return
+	}
+
+	resp := dueReviewsResponse{
+		StudentID: student.ID,
+		Due:       make([]dueReview, 0, len(due)),
+	}
+
+	// the answers stay hidden; students are about to be quizzed on these
+	for _, d := range due {
+		resp.Due = append(resp.Due, dueReview{
+			Card:     d.Card,
+			BankID:   d.Question.BankID,
+			Question: d.Question.Question.Public(),
+		})
+	}
+
+	WriteJSON(wr, resp, http.StatusOK)
+}
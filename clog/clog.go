@@ -46,7 +46,7 @@ func New(logger *zap.Logger, fields ...zap.Field) ICustomLog {
 }
 
 func (c CustomLog) Debug(msg string, fields ...zap.Field) {
-	c.logger.Debug(msg, MapToFields(c.fieldsMtx, c.fields)...)
+	c.logger.Debug(msg, append(MapToFields(c.fieldsMtx, c.fields), fields...)...)
 }
 
 func (c CustomLog) Info(msg string, fields ...zap.Field) {
@@ -66,7 +66,9 @@ func (c CustomLog) Fatal(msg string, fields ...zap.Field) {
 }
 
 func (c CustomLog) With(fields ...zap.Field) ICustomLog {
-	return New(c.logger, MapToFields(c.fieldsMtx, UpdateMap(c.fieldsMtx, c.fields, fields...))...)
+	// Copy the parent's fields so that child loggers never leak their fields
+	// back into the parent (or into their siblings)
+	return New(c.logger, append(MapToFields(c.fieldsMtx, c.fields), fields...)...)
 }
 
 func UpdateMap(mtx *sync.Mutex, m map[string]zap.Field, f ...zap.Field) map[string]zap.Field {
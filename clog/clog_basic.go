@@ -36,31 +36,31 @@ func NewBasic(fields ...zap.Field) ICustomLog {
 
 func (c CustomLogBasic) Debug(msg string, fields ...zap.Field) {
 	date := time.Now().UTC().Format(timeFormat)
-	fmt.Printf("%s [DEBUG] %s {%s}\n", date, msg, FieldsToString(MapToFields(c.mtx, UpdateMap(c.mtx, c.fields, fields...))))
+	fmt.Printf("%s [DEBUG] %s {%s}\n", date, msg, FieldsToString(append(MapToFields(c.mtx, c.fields), fields...)))
 }
 
 func (c CustomLogBasic) Info(msg string, fields ...zap.Field) {
 	date := time.Now().UTC().Format(timeFormat)
-	fmt.Printf("%s [INFO] %s {%s}\n", date, msg, FieldsToString(MapToFields(c.mtx, UpdateMap(c.mtx, c.fields, fields...))))
+	fmt.Printf("%s [INFO] %s {%s}\n", date, msg, FieldsToString(append(MapToFields(c.mtx, c.fields), fields...)))
 }
 
 func (c CustomLogBasic) Warn(msg string, fields ...zap.Field) {
 	date := time.Now().UTC().Format(timeFormat)
-	fmt.Printf("%s [WARN] %s {%s}\n", date, msg, FieldsToString(MapToFields(c.mtx, UpdateMap(c.mtx, c.fields, fields...))))
+	fmt.Printf("%s [WARN] %s {%s}\n", date, msg, FieldsToString(append(MapToFields(c.mtx, c.fields), fields...)))
 }
 
 func (c CustomLogBasic) Error(msg string, fields ...zap.Field) {
 	date := time.Now().UTC().Format(timeFormat)
-	fmt.Printf("%s [ERROR] %s {%s}\n", date, msg, FieldsToString(MapToFields(c.mtx, UpdateMap(c.mtx, c.fields, fields...))))
+	fmt.Printf("%s [ERROR] %s {%s}\n", date, msg, FieldsToString(append(MapToFields(c.mtx, c.fields), fields...)))
 }
 
 func (c CustomLogBasic) Fatal(msg string, fields ...zap.Field) {
 	date := time.Now().UTC().Format(timeFormat)
-	fmt.Printf("%s [FATAL] %s {%s}\n", date, msg, FieldsToString(MapToFields(c.mtx, UpdateMap(c.mtx, c.fields, fields...))))
+	fmt.Printf("%s [FATAL] %s {%s}\n", date, msg, FieldsToString(append(MapToFields(c.mtx, c.fields), fields...)))
 }
 
 func (c CustomLogBasic) With(fields ...zap.Field) ICustomLog {
-	return NewBasic(MapToFields(c.mtx, UpdateMap(c.mtx, c.fields, fields...))...)
+	return NewBasic(append(MapToFields(c.mtx, c.fields), fields...)...)
 }
 
 func FieldsToString(fields []zap.Field) string {
@@ -0,0 +1,100 @@
+package clog
+
+import (
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestWith(t *testing.T) {
+	tests := []struct {
+		name string
+		// log writes with the logger that has the field "pkg"
+		log  func(parent ICustomLog)
+		want []map[string]any // fields of each entry
+	}{
+		{
+			name: "child adds fields",
+			log: func(parent ICustomLog) {
+				parent.With(zap.String("room", "a")).Info("msg")
+			},
+			want: []map[string]any{{"pkg": "ws", "room": "a"}},
+		},
+		{
+			name: "child does not change the parent",
+			log: func(parent ICustomLog) {
+				parent.With(zap.String("room", "a"))
+				parent.Info("msg")
+			},
+			want: []map[string]any{{"pkg": "ws"}},
+		},
+		{
+			name: "siblings keep their own fields",
+			log: func(parent ICustomLog) {
+				a := parent.With(zap.String("room", "a"))
+				b := parent.With(zap.String("room", "b"))
+				a.Info("msg")
+				b.Info("msg")
+			},
+			want: []map[string]any{{"pkg": "ws", "room": "a"}, {"pkg": "ws", "room": "b"}},
+		},
+		{
+			name: "child overrides a parent field",
+			log: func(parent ICustomLog) {
+				parent.With(zap.String("pkg", "api")).Info("msg")
+			},
+			want: []map[string]any{{"pkg": "api"}},
+		},
+		{
+			name: "debug includes the call's fields",
+			log: func(parent ICustomLog) {
+				parent.Debug("msg", zap.String("event", "join_room"))
+			},
+			want: []map[string]any{{"pkg": "ws", "event": "join_room"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			core, logs := observer.New(zapcore.DebugLevel)
+
+			tt.log(New(zap.New(core), zap.String("pkg", "ws")))
+
+			entries := logs.All()
+			if len(entries) != len(tt.want) {
+				t.Fatalf("logged %d entries, want %d", len(entries), len(tt.want))
+			}
+
+			for i, entry := range entries {
+				got := entry.ContextMap()
+				if len(got) != len(tt.want[i]) {
+					t.Errorf("entry %d fields = %v, want %v", i, got, tt.want[i])
+					continue
+				}
+
+				for key, value := range tt.want[i] {
+					if got[key] != value {
+						t.Errorf("entry %d fields = %v, want %v", i, got, tt.want[i])
+						break
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestBasicWith(t *testing.T) {
+	parent := NewBasic(zap.String("pkg", "ws")).(*CustomLogBasic)
+
+	child := parent.With(zap.String("room", "a")).(*CustomLogBasic)
+	child.Info("msg", zap.String("event", "join_room"))
+
+	if len(parent.fields) != 1 {
+		t.Errorf("parent fields = %v, want only pkg", parent.fields)
+	}
+	if len(child.fields) != 2 {
+		t.Errorf("child fields = %v, want pkg and room", child.fields)
+	}
+}
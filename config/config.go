@@ -3,6 +3,7 @@ package config
 import (
 	"fmt"
 	"reflect"
+	"time"
 
 	"github.com/alecthomas/kong"
 	"github.com/joho/godotenv"
@@ -13,6 +14,9 @@ import (
 const (
 	EnvFile         = ".env"
 	EnvConfigPrefix = "GO_MNEMO"
+
+	// redacted replaces the values of fields tagged redact:"true" in GetMap
+	redacted = "[redacted]"
 )
 
 type Config struct {
@@ -22,12 +26,75 @@ type Config struct {
 	EnablePprof      bool             `kong:"help='Enable pprof endpoints (http://$apiListenAddress/debug).',default=false"`
 	APIListenAddress string           `kong:"help='API listen address (serves health, metrics, version).',default=:8080"`
 	LogConfig        string           `kong:"help='Logging config to use.',enum='dev,prod',default='dev'"`
+	PublicURL        string           `kong:"help='Base URL advertised to students in join links and QR codes (defaults to http://$apiListenAddress).'"`
 
 	NumGeneratorWorkers int `kong:"help='Number of generator workers to run.',default=4"`
 
+	DatabasePath string `kong:"help='Path to the SQLite database file.',default='mnemo.db'"`
+
+	Broker   string `kong:"help='Pub/sub broker connecting instances that share rooms; memory only serves a single instance.',enum='memory,redis',default='memory'"`
+	RedisURL string `kong:"help='Redis server used by the redis broker.',default='redis://localhost:6379/0'" redact:"true"`
+
+	RoomLeaseTTL time.Duration `kong:"help='How long an instance holds on to its rooms without renewing; rooms of an instance that died are adopted by another one after that.',default=10s"`
+
+	Instructors []string      `kong:"help='Instructor accounts as email:bcrypt-hash pairs.'" redact:"true"`
+	AuthSecret  string        `kong:"help='Secret used to sign instructor session tokens (random per process if unset).'" redact:"true"`
+	SessionTTL  time.Duration `kong:"help='Lifetime of instructor session tokens.',default=12h"`
+
+	EgressQueueSize  int    `kong:"help='Outgoing events buffered per websocket client.',default=256"`
+	EgressPolicy     string `kong:"help='What to do when a client falls behind and its queue is full.',enum='drop-newest,drop-oldest,evict',default='evict'"`
+	ReplayBufferSize int    `kong:"help='Recent events kept per room for replay to reconnecting clients.',default=512"`
+
+	ShutdownReconnectWindow time.Duration `kong:"help='Window over which websocket clients are told to spread their reconnects when the server shuts down.',default=5s"`
+
+	HealthMaxGoroutines int     `kong:"help='Goroutines above which the websocket hub reports itself unhealthy (0 disables the check).',default=10000"`
+	HealthMaxQueueFill  float64 `kong:"help='Fraction of the total egress queue capacity above which the websocket hub reports itself unhealthy.',default=0.9"`
+
+	QuestionTickInterval time.Duration `kong:"help='How often timed questions broadcast the remaining time.',default=1s"`
+
+	ScoreBasePoints          int           `kong:"help='Points for a correct answer.',default=500"`
+	ScoreSpeedBonus          int           `kong:"help='Extra points for an instant correct answer, decaying to 0 at the deadline.',default=500"`
+	ScoreSpeedWindow         time.Duration `kong:"help='Time over which the speed bonus decays for questions without a time limit.',default=30s"`
+	ScoreStreakBonus         float64       `kong:"help='Multiplier added per consecutive correct answer.',default=0.1"`
+	ScoreMaxStreakMultiplier float64       `kong:"help='Upper bound for the streak multiplier.',default=2"`
+	LeaderboardTopN          int           `kong:"help='Number of leaderboard entries shown to students.',default=5"`
+	LeaderboardToStudents    bool          `kong:"help='Push the leaderboard to students after each question.',default=true,negatable"`
+
+	WordCloudTerms int `kong:"help='Most frequent words reported in word cloud poll results.',default=50"`
+
+	MoodWindow         time.Duration `kong:"help='How long student reactions count towards the mood shown to the professor.',default=60s"`
+	MoodUpdateInterval time.Duration `kong:"help='Shortest time between two mood updates pushed to the professor.',default=2s"`
+	MoodReactionRate   int           `kong:"help='Reactions a student may send per second; faster ones are rejected.',default=5"`
+
+	ReviewSessionSize int `kong:"help='Maximum number of due questions in one self-paced review session.',default=20"`
+
+	Serve  ServeCmd  `kong:"cmd,default='1',help='Run the API and websocket server (default).'"`
+	Import ImportCmd `kong:"cmd,help='Import questions from a GIFT, Aiken or Moodle XML file into a question bank.'"`
+	Export ExportCmd `kong:"cmd,help='Export the gradebook of a room as CSV, XLSX or JSON.'"`
+
 	KongContext *kong.Context `kong:"-"`
 }
 
+// ServeCmd runs the server; it takes no options beyond the global ones.
+type ServeCmd struct{}
+
+// ImportCmd loads a question file into a bank without going through the API.
+type ImportCmd struct {
+	File     string `kong:"arg,type='existingfile',help='Question file to import.'"`
+	Format   string `kong:"help='File format; auto detects from the file extension.',enum='auto,gift,aiken,moodle-xml',default='auto'"`
+	Bank     string `kong:"help='ID of an existing bank to append to.',xor='bank'"`
+	BankName string `kong:"help='Create a new bank with this name.',xor='bank'"`
+	Owner    string `kong:"help='Email of the instructor owning the new bank (with --bank-name).'"`
+	DryRun   bool   `kong:"help='Parse the file and report errors without saving anything.'"`
+}
+
+// ExportCmd writes a room's gradebook straight from the database.
+type ExportCmd struct {
+	Room   string `kong:"arg,help='Room join code.'"`
+	Format string `kong:"help='Output format.',enum='csv,xlsx,json',default='csv'"`
+	Output string `kong:"short='o',help='Output file; defaults to stdout.'"`
+}
+
 func New(version string) *Config {
 	// Attempt to load .env - do not fail if it's not there. Only environment
 	// that might have this is in local/dev; staging, prod should not have one.
@@ -58,10 +125,72 @@ func (c *Config) Validate() error {
 		return errors.New("Config cannot be nil")
 	}
 
+	if c.RoomLeaseTTL <= 0 {
+		return errors.New("RoomLeaseTTL must be positive")
+	}
+
+	if c.SessionTTL <= 0 {
+		return errors.New("SessionTTL must be positive")
+	}
+
+	if c.EgressQueueSize <= 0 {
+		return errors.New("EgressQueueSize must be positive")
+	}
+
+	if c.ReplayBufferSize < 0 {
+		return errors.New("ReplayBufferSize cannot be negative")
+	}
+
+	if c.ShutdownReconnectWindow < 0 {
+		return errors.New("ShutdownReconnectWindow cannot be negative")
+	}
+
+	if c.HealthMaxGoroutines < 0 {
+		return errors.New("HealthMaxGoroutines cannot be negative")
+	}
+
+	if c.HealthMaxQueueFill <= 0 || c.HealthMaxQueueFill > 1 {
+		return errors.New("HealthMaxQueueFill must be in (0, 1]")
+	}
+
+	if c.QuestionTickInterval <= 0 {
+		return errors.New("QuestionTickInterval must be positive")
+	}
+
+	if c.ScoreBasePoints < 0 || c.ScoreSpeedBonus < 0 || c.ScoreStreakBonus < 0 {
+		return errors.New("scoring settings cannot be negative")
+	}
+
+	if c.ScoreMaxStreakMultiplier < 1 {
+		return errors.New("ScoreMaxStreakMultiplier must be at least 1")
+	}
+
+	if c.ReviewSessionSize <= 0 {
+		return errors.New("ReviewSessionSize must be positive")
+	}
+
+	if c.LeaderboardTopN < 0 {
+		return errors.New("LeaderboardTopN cannot be negative")
+	}
+
+	if c.WordCloudTerms <= 0 {
+		return errors.New("WordCloudTerms must be positive")
+	}
+
+	if c.MoodWindow <= 0 || c.MoodUpdateInterval <= 0 {
+		return errors.New("MoodWindow and MoodUpdateInterval must be positive")
+	}
+
+	if c.MoodReactionRate <= 0 {
+		return errors.New("MoodReactionRate must be positive")
+	}
+
 	return nil
 }
 
-// GetMap generates a map of field:value pairs for all fields in Config struct
+// GetMap generates a map of field:value pairs for all fields in Config struct.
+// Fields tagged redact:"true" hold credentials; their values are replaced
+// unless they are unset.
 func (c *Config) GetMap() map[string]string {
 	fields := make(map[string]string)
 
@@ -75,6 +204,11 @@ func (c *Config) GetMap() map[string]string {
 	for i := 0; i < t.NumField(); i++ {
 		field := t.Field(i)
 		value := val.Field(i)
+		if field.Tag.Get("redact") == "true" && !value.IsZero() {
+			fields[field.Name] = redacted
+			continue
+		}
+
 		fields[field.Name] = fmt.Sprintf("%v", value)
 	}
 
@@ -0,0 +1,67 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGetMapRedactsSecrets(t *testing.T) {
+	const (
+		secret     = "hmac-signing-secret"
+		redisURL   = "redis://:redis-password@cache:6379/0"
+		instructor = "prof@example.com:$2a$10$abcdefghijklmnopqrstuv"
+	)
+
+	tests := []struct {
+		name    string
+		cfg     *Config
+		secrets []string
+		want    map[string]string
+	}{
+		{
+			name: "set secrets are redacted",
+			cfg: &Config{
+				AuthSecret:  secret,
+				RedisURL:    redisURL,
+				Instructors: []string{instructor},
+				EnvName:     "prod",
+			},
+			secrets: []string{secret, redisURL, "redis-password", instructor, "$2a$10$"},
+			want: map[string]string{
+				"AuthSecret":  redacted,
+				"RedisURL":    redacted,
+				"Instructors": redacted,
+				"EnvName":     "prod",
+			},
+		},
+		{
+			name: "unset secrets stay empty",
+			cfg:  &Config{},
+			want: map[string]string{
+				"AuthSecret":  "",
+				"RedisURL":    "",
+				"Instructors": "[]",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fields := tt.cfg.GetMap()
+
+			for key, want := range tt.want {
+				if fields[key] != want {
+					t.Errorf("GetMap()[%s] = %q, want %q", key, fields[key], want)
+				}
+			}
+
+			for key, value := range fields {
+				for _, s := range tt.secrets {
+					if strings.Contains(value, s) {
+						t.Errorf("GetMap()[%s] leaks %q", key, s)
+					}
+				}
+			}
+		})
+	}
+}
@@ -7,7 +7,11 @@ import (
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 	"mnemo/clog"
+	"mnemo/services/auth"
+	"mnemo/services/broker"
+	"mnemo/services/metrics"
 	"mnemo/services/ws"
+	"mnemo/storage"
 	"os"
 	"strconv"
 	"strings"
@@ -19,13 +23,23 @@ import (
 
 const (
 	DefaultHealthCheckIntervalSecs = 1
+
+	// DefaultHealthCheckTimeout bounds a single ping of a backend
+	DefaultHealthCheckTimeout = 2 * time.Second
 )
 
-type customCheck struct{}
+// pingCheck adapts a backend's Ping to go-health.ICheckable
+type pingCheck func(ctx context.Context) error
 
 type Dependencies struct {
 	// Services
 	WebsocketManager *ws.Manager
+	Auth             *auth.Service
+	Metrics          *metrics.Metrics
+
+	// Backends
+	Storage storage.Repository
+	Broker  broker.Broker
 
 	Health health.IHealth
 
@@ -36,10 +50,9 @@ type Dependencies struct {
 	// ShutdownCancel is the cancel function for the global shutdown context
 	ShutdownCancel context.CancelFunc
 
-	// Channel written to by publisher when it's done shutting down; read by
-	// shutdown handler in main(). We need this so that we can tell the shutdown
-	// handler when it is safe to exit.
-	PublisherShutdownDoneCh chan struct{}
+	// Lifecycle starts the components in dependency order and stops them in
+	// reverse; main() drives it.
+	Lifecycle *Lifecycle
 
 	Config *config.Config
 
@@ -57,33 +70,35 @@ func New(cfg *config.Config) (*Dependencies, error) {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	d := &Dependencies{
-		ShutdownCtx:             ctx,
-		ShutdownCancel:          cancel,
-		PublisherShutdownDoneCh: make(chan struct{}),
-		Config:                  cfg,
+		ShutdownCtx:    ctx,
+		ShutdownCancel: cancel,
+		Config:         cfg,
 	}
 
 	if err := d.setupLogging(); err != nil {
 		return nil, errors.Wrap(err, "unable to setup logging")
 	}
 
+	d.Lifecycle = NewLifecycle(d.Log)
+
 	// Pretty print config in dev mode
 	if d.Config.LogConfig == "dev" {
 		d.LogConfig()
 	}
 
-	if err := d.setupHealth(); err != nil {
-		return nil, errors.Wrap(err, "unable to setup health")
-	}
-
-	if err := d.Health.Start(); err != nil {
-		return nil, errors.Wrap(err, "unable to start health runner")
+	if err := d.setupBackends(cfg); err != nil {
+		return nil, errors.Wrap(err, "unable to setup backends")
 	}
 
 	if err := d.setupServices(cfg); err != nil {
 		return nil, errors.Wrap(err, "unable to setup services")
 	}
 
+	// Health checks watch the backends and services, so they come last
+	if err := d.setupHealth(); err != nil {
+		return nil, errors.Wrap(err, "unable to setup health")
+	}
+
 	return d, nil
 }
 
@@ -125,15 +140,28 @@ func (d *Dependencies) setupHealth() error {
 	gohealth := health.New()
 	gohealth.DisableLogging()
 
-	cc := &customCheck{}
+	interval := time.Duration(DefaultHealthCheckIntervalSecs) * time.Second
 
+	// Fatal checks decide liveness: a hub that can't keep up won't recover
+	// by itself. The backends only decide readiness, restarting us does not
+	// bring them back.
 	err := gohealth.AddChecks([]*health.Config{
 		{
-			Name:     "health-check",
-			Checker:  cc,
-			Interval: time.Duration(DefaultHealthCheckIntervalSecs) * time.Second,
+			Name:     "ws-manager",
+			Checker:  d.WebsocketManager,
+			Interval: interval,
 			Fatal:    true,
 		},
+		{
+			Name:     "storage",
+			Checker:  pingCheck(d.Storage.Ping),
+			Interval: interval,
+		},
+		{
+			Name:     "broker",
+			Checker:  pingCheck(d.Broker.Ping),
+			Interval: interval,
+		},
 	})
 
 	d.Health = gohealth
@@ -142,6 +170,50 @@ func (d *Dependencies) setupHealth() error {
 		return err
 	}
 
+	d.Lifecycle.Register(Hook{
+		Name:  "health",
+		Start: func(context.Context) error { return d.Health.Start() },
+		Stop:  func(context.Context) error { return d.Health.Stop() },
+	})
+
+	return nil
+}
+
+func (d *Dependencies) setupBackends(cfg *config.Config) error {
+	logger := d.Log.With(zap.String("method", "setupBackends"))
+	logger.Debug("Setting up storage", zap.String("path", cfg.DatabasePath))
+
+	store, err := storage.NewSQLite(d.ShutdownCtx, cfg.DatabasePath)
+	if err != nil {
+		return errors.Wrap(err, "unable to setup storage")
+	}
+
+	d.Storage = store
+
+	d.Lifecycle.Register(Hook{
+		Name: "storage",
+		Stop: func(context.Context) error { return d.Storage.Close() },
+	})
+
+	logger.Debug("Setting up broker", zap.String("broker", cfg.Broker))
+
+	switch cfg.Broker {
+	case "redis":
+		bus, err := broker.NewRedis(d.ShutdownCtx, cfg.RedisURL)
+		if err != nil {
+			return errors.Wrap(err, "unable to setup redis broker")
+		}
+
+		d.Broker = bus
+	default:
+		d.Broker = broker.NewMemory()
+	}
+
+	d.Lifecycle.Register(Hook{
+		Name: "broker",
+		Stop: func(context.Context) error { return d.Broker.Close() },
+	})
+
 	return nil
 }
 
@@ -149,23 +221,78 @@ func (d *Dependencies) setupServices(cfg *config.Config) error {
 	logger := d.Log.With(zap.String("method", "setupServices"))
 	logger.Debug("Setting up services")
 
+	logger.Debug("Setting up auth service")
+
+	// Instructors from config are (re)seeded on every start so password
+	// changes take effect
+	for _, spec := range cfg.Instructors {
+		instructor, err := auth.ParseInstructor(spec)
+		if err != nil {
+			return errors.Wrap(err, "unable to parse instructor")
+		}
+
+		if err := d.Storage.SaveInstructor(d.ShutdownCtx, instructor); err != nil {
+			return errors.Wrap(err, "unable to save instructor")
+		}
+	}
+
+	if len(cfg.Instructors) == 0 {
+		logger.Warn("No instructors configured; only previously stored instructors can log in")
+	}
+
+	secret := []byte(cfg.AuthSecret)
+	if len(secret) == 0 {
+		logger.Warn("No auth secret configured; sessions will not survive a restart")
+
+		var err error
+		if secret, err = auth.NewSecret(); err != nil {
+			return errors.Wrap(err, "unable to generate auth secret")
+		}
+	}
+
+	authService, err := auth.New(d.Storage, secret, cfg.SessionTTL)
+	if err != nil {
+		return errors.Wrap(err, "unable to create auth service")
+	}
+
+	d.Auth = authService
+
 	logger.Debug("Setting up hub service")
 
-	manager := ws.NewManager()
+	manager, err := ws.NewManager(cfg, d.Log, authService, d.Storage, d.Broker)
+	if err != nil {
+		return errors.Wrap(err, "unable to create hub service")
+	}
+
+	d.Metrics = metrics.New(manager)
+	manager.SetObserver(d.Metrics)
+
 	d.WebsocketManager = manager
 
+	d.Lifecycle.Register(Hook{
+		Name: "ws-manager",
+		Start: func(ctx context.Context) error {
+			// the hub drains its clients as soon as shutdown begins
+			if err := manager.Start(d.ShutdownCtx); err != nil {
+				return errors.Wrap(err, "unable to start hub service")
+			}
+
+			return errors.Wrap(manager.Restore(ctx), "unable to restore rooms")
+		},
+		Stop: manager.Stop,
+		// restoring reloads every open room from storage
+		Timeout: 30 * time.Second,
+	})
+
 	return nil
 }
 
 // Status satisfies the go-health.ICheckable interface
-func (c *customCheck) Status() (interface{}, error) {
-	if false {
-		return nil, errors.New("something major just broke")
-	}
+func (p pingCheck) Status() (interface{}, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultHealthCheckTimeout)
+	defer cancel()
 
-	// You can return additional information pertaining to the check as long
-	// as it can be JSON marshalled
-	return map[string]int{}, nil
+	return nil, p(ctx)
 }
 
 // LogConfig pretty prints the config to the log
@@ -0,0 +1,136 @@
+package deps
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"mnemo/clog"
+)
+
+const (
+	DefaultHookTimeout = 5 * time.Second
+)
+
+// Hook is a component's part in the process lifecycle. Components are
+// started in the order they are registered and stopped in reverse, so a
+// component can rely on everything registered before it while it runs.
+type Hook struct {
+	Name string
+
+	// Start brings the component up; it must not block beyond that. Nil for
+	// components that are ready once constructed.
+	Start func(ctx context.Context) error
+
+	// Stop releases the component, returning once it is done or ctx is.
+	// Nil for components with nothing to release.
+	Stop func(ctx context.Context) error
+
+	// Timeout bounds Start and Stop each (DefaultHookTimeout if zero)
+	Timeout time.Duration
+}
+
+// Lifecycle starts and stops the registered components.
+type Lifecycle struct {
+	hooks   []Hook
+	started int
+	log     clog.ICustomLog
+
+	sync sync.Mutex
+}
+
+func NewLifecycle(logger clog.ICustomLog) *Lifecycle {
+	return &Lifecycle{log: logger.With(zap.String("pkg", "lifecycle"))}
+}
+
+// Register adds a component; components can't be registered once Start was
+// called.
+func (l *Lifecycle) Register(hook Hook) {
+	l.sync.Lock()
+	defer l.sync.Unlock()
+
+	if hook.Timeout <= 0 {
+		hook.Timeout = DefaultHookTimeout
+	}
+
+	l.hooks = append(l.hooks, hook)
+}
+
+// Start starts the components in order and stops at the first failure. The
+// components started until then still have to be stopped with Stop.
+func (l *Lifecycle) Start(ctx context.Context) error {
+	l.sync.Lock()
+	defer l.sync.Unlock()
+
+	for ; l.started < len(l.hooks); l.started++ {
+		hook := l.hooks[l.started]
+		if hook.Start == nil {
+			continue
+		}
+
+		began := time.Now()
+
+		if err := run(ctx, hook.Timeout, hook.Start); err != nil {
+			return errors.Wrapf(err, "unable to start %s", hook.Name)
+		}
+
+		l.log.Debug("component started", zap.String("component", hook.Name), zap.Duration("took", time.Since(began)))
+	}
+
+	return nil
+}
+
+// Stop stops the started components in reverse order. A component that
+// fails or times out is reported and skipped, the others are still stopped.
+func (l *Lifecycle) Stop(ctx context.Context) error {
+	l.sync.Lock()
+	defer l.sync.Unlock()
+
+	var failed []string
+
+	for ; l.started > 0; l.started-- {
+		hook := l.hooks[l.started-1]
+		if hook.Stop == nil {
+			continue
+		}
+
+		began := time.Now()
+
+		if err := run(ctx, hook.Timeout, hook.Stop); err != nil {
+			l.log.Error("component failed to stop", zap.String("component", hook.Name), zap.Error(err))
+			failed = append(failed, hook.Name)
+			continue
+		}
+
+		l.log.Info("component stopped", zap.String("component", hook.Name), zap.Duration("took", time.Since(began)))
+	}
+
+	if len(failed) > 0 {
+		return errors.Errorf("components failed to stop: %v", failed)
+	}
+
+	return nil
+}
+
+// run calls fn with a context bounded by timeout, and gives up on fn when the
+// context is done even if fn does not return.
+func run(ctx context.Context, timeout time.Duration, fn func(ctx context.Context) error) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+
+	go func() {
+		done <- fn(ctx)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return errors.Wrap(ctx.Err(), "timed out")
+	}
+}
@@ -0,0 +1,194 @@
+package deps
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"mnemo/clog"
+)
+
+// Behaviours of a test component's Start or Stop.
+const (
+	succeed = "succeed"
+	fail    = "fail"
+	hang    = "hang" // until its context is done, then keeps hanging
+	none    = "none" // nil function
+)
+
+type testComponent struct {
+	name  string
+	start string
+	stop  string
+}
+
+// calls records the Start and Stop calls in order.
+type calls struct {
+	log  []string
+	sync sync.Mutex
+}
+
+func (c *calls) record(call string) {
+	c.sync.Lock()
+	defer c.sync.Unlock()
+
+	c.log = append(c.log, call)
+}
+
+func (c *calls) get() []string {
+	c.sync.Lock()
+	defer c.sync.Unlock()
+
+	return append([]string(nil), c.log...)
+}
+
+// hookFunc returns a Start or Stop function behaving as given.
+func hookFunc(c *calls, call, behaviour string) func(ctx context.Context) error {
+	if behaviour == none {
+		return nil
+	}
+
+	return func(ctx context.Context) error {
+		c.record(call)
+
+		switch behaviour {
+		case fail:
+			return errors.New("broken")
+		case hang:
+			<-ctx.Done()
+			select {}
+		}
+
+		return nil
+	}
+}
+
+func TestLifecycle(t *testing.T) {
+	tests := []struct {
+		name       string
+		components []testComponent
+
+		wantCalls    []string
+		wantStartErr string // in the Start error if set
+		wantStopErr  string // in the Stop error if set
+	}{
+		{
+			name: "stopped in reverse order",
+			components: []testComponent{
+				{"storage", succeed, succeed},
+				{"broker", succeed, succeed},
+				{"ws-manager", succeed, succeed},
+			},
+			wantCalls: []string{
+				"start storage", "start broker", "start ws-manager",
+				"stop ws-manager", "stop broker", "stop storage",
+			},
+		},
+		{
+			name: "components without start or stop",
+			components: []testComponent{
+				{"storage", none, succeed},
+				{"metrics", succeed, none},
+				{"ws-manager", succeed, succeed},
+			},
+			wantCalls: []string{
+				"start metrics", "start ws-manager",
+				"stop ws-manager", "stop storage",
+			},
+		},
+		{
+			name: "start failure stops the started components only",
+			components: []testComponent{
+				{"storage", succeed, succeed},
+				{"broker", fail, succeed},
+				{"ws-manager", succeed, succeed},
+			},
+			wantCalls:    []string{"start storage", "start broker", "stop storage"},
+			wantStartErr: "unable to start broker: broken",
+		},
+		{
+			name: "start timeout",
+			components: []testComponent{
+				{"storage", succeed, succeed},
+				{"broker", hang, succeed},
+			},
+			wantCalls:    []string{"start storage", "start broker", "stop storage"},
+			wantStartErr: "unable to start broker: timed out",
+		},
+		{
+			name: "stop failure does not stop the others",
+			components: []testComponent{
+				{"storage", succeed, succeed},
+				{"broker", succeed, fail},
+				{"ws-manager", succeed, succeed},
+			},
+			wantCalls: []string{
+				"start storage", "start broker", "start ws-manager",
+				"stop ws-manager", "stop broker", "stop storage",
+			},
+			wantStopErr: "components failed to stop: [broker]",
+		},
+		{
+			name: "stop timeout",
+			components: []testComponent{
+				{"storage", succeed, succeed},
+				{"ws-manager", succeed, hang},
+			},
+			wantCalls: []string{
+				"start storage", "start ws-manager",
+				"stop ws-manager", "stop storage",
+			},
+			wantStopErr: "components failed to stop: [ws-manager]",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewLifecycle(clog.New(zap.NewNop()))
+			c := &calls{}
+
+			for _, component := range tt.components {
+				l.Register(Hook{
+					Name:    component.name,
+					Start:   hookFunc(c, "start "+component.name, component.start),
+					Stop:    hookFunc(c, "stop "+component.name, component.stop),
+					Timeout: 50 * time.Millisecond,
+				})
+			}
+
+			err := l.Start(context.Background())
+			if tt.wantStartErr == "" && err != nil {
+				t.Fatalf("Start: %v", err)
+			}
+			if tt.wantStartErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantStartErr)) {
+				t.Fatalf("Start = %v, want %q", err, tt.wantStartErr)
+			}
+
+			err = l.Stop(context.Background())
+			if tt.wantStopErr == "" && err != nil {
+				t.Fatalf("Stop: %v", err)
+			}
+			if tt.wantStopErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantStopErr)) {
+				t.Fatalf("Stop = %v, want %q", err, tt.wantStopErr)
+			}
+
+			if calls := c.get(); !reflect.DeepEqual(calls, tt.wantCalls) {
+				t.Errorf("calls = %v, want %v", calls, tt.wantCalls)
+			}
+
+			// everything is stopped already
+			if err := l.Stop(context.Background()); err != nil {
+				t.Errorf("second Stop: %v", err)
+			}
+			if calls := c.get(); len(calls) != len(tt.wantCalls) {
+				t.Errorf("second Stop called %v", calls[len(tt.wantCalls):])
+			}
+		})
+	}
+}
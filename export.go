@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"os"
+
+	"github.com/pkg/errors"
+
+	"mnemo/config"
+	"mnemo/services/gradebook"
+	"mnemo/services/ws"
+	"mnemo/storage"
+)
+
+// runExport implements `mnemo export`, writing the gradebook of a room to a
+// file or stdout.
+func runExport(cfg *config.Config) error {
+	cmd := cfg.Export
+
+	format, err := gradebook.ParseFormat(cmd.Format)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	store, err := storage.NewSQLite(ctx, cfg.DatabasePath)
+	if err != nil {
+		return errors.Wrap(err, "unable to open storage")
+	}
+	defer store.Close()
+
+	book, err := gradebook.Build(ctx, store, ws.NormalizeRoomCode(cmd.Room))
+	if err != nil {
+		return errors.Wrapf(err, "unable to build gradebook for room '%s'", cmd.Room)
+	}
+
+	if cmd.Output == "" {
+		return book.Write(format, os.Stdout)
+	}
+
+	f, err := os.Create(cmd.Output)
+	if err != nil {
+		return errors.Wrap(err, "unable to create output file")
+	}
+
+	if err := book.Write(format, f); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"mnemo/config"
+	"mnemo/services/auth"
+	"mnemo/services/importer"
+	"mnemo/storage"
+)
+
+// runImport implements `mnemo import`. It talks to the database directly so
+// banks can be loaded before the server is started. Per-question errors are
+// printed with their line number; the process exits non-zero if there were
+// any, after importing the questions that did parse.
+func runImport(cfg *config.Config) error {
+	cmd := cfg.Import
+
+	format := importer.Format(cmd.Format)
+	if cmd.Format == "auto" {
+		detected, ok := importer.DetectFormat(cmd.File)
+		if !ok {
+			return errors.Errorf("unable to detect format of '%s', set --format", cmd.File)
+		}
+		format = detected
+	}
+
+	if !cmd.DryRun && cmd.Bank == "" && cmd.BankName == "" {
+		return errors.New("one of --bank or --bank-name is required")
+	}
+
+	if cmd.BankName != "" && cmd.Owner == "" {
+		return errors.New("--owner is required with --bank-name")
+	}
+
+	f, err := os.Open(cmd.File)
+	if err != nil {
+		return errors.Wrap(err, "unable to open file")
+	}
+	defer f.Close()
+
+	result, err := importer.Parse(format, f)
+	if err != nil {
+		return errors.Wrap(err, "unable to parse file")
+	}
+
+	for _, e := range result.Errors {
+		fmt.Fprintf(os.Stderr, "%s:%d: %s\n", cmd.File, e.Line, e.Message)
+	}
+
+	if cmd.DryRun {
+		fmt.Printf("%d questions parsed, %d errors (dry run, nothing saved)\n", len(result.Questions), len(result.Errors))
+		return importErrors(result)
+	}
+
+	ctx := context.Background()
+
+	store, err := storage.NewSQLite(ctx, cfg.DatabasePath)
+	if err != nil {
+		return errors.Wrap(err, "unable to open storage")
+	}
+	defer store.Close()
+
+	bankID := cmd.Bank
+	if bankID != "" {
+		if _, err := store.GetBank(ctx, bankID); err != nil {
+			return errors.Wrapf(err, "unable to get bank '%s'", bankID)
+		}
+	} else {
+		owner, err := bankOwner(cfg, store, cmd.Owner)
+		if err != nil {
+			return err
+		}
+
+		id, err := storage.NewID()
+		if err != nil {
+			return errors.Wrap(err, "unable to generate bank id")
+		}
+
+		now := time.Now()
+		bank := &storage.Bank{
+			ID:        id,
+			Owner:     owner.ID,
+			Name:      cmd.BankName,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+
+		if err := store.CreateBank(ctx, bank); err != nil {
+			return errors.Wrap(err, "unable to create bank")
+		}
+
+		bankID = bank.ID
+	}
+
+	if err := importer.Save(ctx, store, bankID, result.Questions); err != nil {
+		return errors.Wrap(err, "unable to save questions")
+	}
+
+	fmt.Printf("%d questions imported into bank %s, %d errors\n", len(result.Questions), bankID, len(result.Errors))
+
+	return importErrors(result)
+}
+
+// bankOwner looks up the instructor given with --owner, among the configured
+// instructors first and then among those the server stored earlier, so a new
+// bank never belongs to someone who can't log in.
+func bankOwner(cfg *config.Config, store storage.Repository, email string) (*auth.Instructor, error) {
+	email = auth.NormalizeEmail(email)
+
+	for _, spec := range cfg.Instructors {
+		instructor, err := auth.ParseInstructor(spec)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to parse instructor")
+		}
+
+		if instructor.Email == email {
+			return instructor, nil
+		}
+	}
+
+	instructor, err := store.GetInstructorByEmail(email)
+	if errors.Is(err, auth.ErrNotFound) {
+		return nil, errors.Errorf("--owner '%s' is not a configured instructor", email)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to get instructor '%s'", email)
+	}
+
+	return instructor, nil
+}
+
+func importErrors(result *importer.Result) error {
+	if len(result.Errors) > 0 {
+		return errors.Errorf("%d questions could not be imported", len(result.Errors))
+	}
+
+	return nil
+}
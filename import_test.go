@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"mnemo/config"
+	"mnemo/services/auth"
+	"mnemo/storage"
+)
+
+func TestBankOwner(t *testing.T) {
+	ctx := context.Background()
+
+	store, err := storage.NewSQLite(ctx, filepath.Join(t.TempDir(), "mnemo.db"))
+	if err != nil {
+		t.Fatalf("NewSQLite: %v", err)
+	}
+	defer store.Close()
+
+	hash, err := auth.HashPassword("secret")
+	if err != nil {
+		t.Fatalf("HashPassword: %v", err)
+	}
+
+	stored := &auth.Instructor{ID: "stored@example.com", Email: "stored@example.com", PasswordHash: hash}
+	if err := store.SaveInstructor(ctx, stored); err != nil {
+		t.Fatalf("SaveInstructor: %v", err)
+	}
+
+	cfg := &config.Config{Instructors: []string{"prof@example.com:" + string(hash)}}
+
+	tests := []struct {
+		name    string
+		owner   string
+		want    string
+		wantErr bool
+	}{
+		{name: "configured instructor", owner: "prof@example.com", want: "prof@example.com"},
+		{name: "email is normalized", owner: " Prof@Example.com ", want: "prof@example.com"},
+		{name: "stored instructor", owner: "stored@example.com", want: "stored@example.com"},
+		{name: "unknown instructor", owner: "student@example.com", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			instructor, err := bankOwner(cfg, store, tt.owner)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("bankOwner = %+v, want error", instructor)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("bankOwner: %v", err)
+			}
+			if instructor.ID != tt.want {
+				t.Errorf("owner = %s, want %s", instructor.ID, tt.want)
+			}
+		})
+	}
+}
@@ -1,14 +1,13 @@
 package main
 
 import (
+	"context"
 	"log"
-	"net/http"
 	"os"
 	"os/signal"
-	"sync"
+	"syscall"
 	"time"
 
-	"github.com/pkg/errors"
 	"go.uber.org/zap"
 
 	"mnemo/api"
@@ -17,7 +16,9 @@ import (
 )
 
 const (
-	GracefulShutdownTimeout = 4 * time.Second
+	// GracefulShutdownTimeout bounds stopping all components; each one is
+	// also bounded by its own hook timeout
+	GracefulShutdownTimeout = 30 * time.Second
 )
 
 var (
@@ -30,6 +31,19 @@ func main() {
 		log.Fatalf("unable to validate config: %s", err)
 	}
 
+	switch cfg.KongContext.Command() {
+	case "import <file>":
+		if err := runImport(cfg); err != nil {
+			log.Fatalf("import failed: %s", err)
+		}
+		return
+	case "export <room>":
+		if err := runExport(cfg); err != nil {
+			log.Fatalf("export failed: %s", err)
+		}
+		return
+	}
+
 	d, err := deps.New(cfg)
 	if err != nil {
 		log.Fatalf("Could not setup dependencies: %s", err)
@@ -41,20 +55,14 @@ func main() {
 		log.Fatalf("unable to create API instance: %s", err)
 	}
 
-	// Run API server in a goroutine so that we can allow signal listener to
-	// block the main thread so it can orchestrate graceful shutdown.
+	// The API is registered last: it is started once everything it serves is
+	// up, and stopped first
+	d.Lifecycle.Register(deps.Hook{Name: "api", Start: a.Start, Stop: a.Stop})
 
-	// TODO: fix this, not working now
-	go func() {
-		if err := a.Run(); err != nil {
-			if errors.Is(err, http.ErrServerClosed) {
-				// Graceful API server shutdown
-				return
-			}
-
-			log.Fatalf("API server run() failed: %s", err)
-		}
-	}()
+	if err := d.Lifecycle.Start(d.ShutdownCtx); err != nil {
+		d.Log.Error("Startup failed", zap.Error(err))
+		shutdown(d, 1)
+	}
 
 	handleShutdown(d)
 }
@@ -63,48 +71,39 @@ func handleShutdown(d *deps.Dependencies) {
 	llog := d.Log.With(zap.String("method", "handleShutdown"), zap.String("pkg", "main"))
 	llog.Debug("Listening for shutdown")
 
-	componentWaitGroup := &sync.WaitGroup{}
-	componentDoneCh := make(chan struct{})
-
-	// Launch a goroutine for each component that has a graceful shutdown. Once
-	// component has shutdown, it will send signal on componentDoneCh. This will
-	// decrease wait group counter. Once all components have shutdown,
-	// componentWaitGroup.Wait() will unblock, allowing 2nd goroutine to write
-	// to componentDoneCh which will be caught by the select().
-	go func() {
-		componentWaitGroup.Add(1) // Add(1) for every component
-		<-d.PublisherShutdownDoneCh
-		componentWaitGroup.Done()
-	}()
-
-	// ^ If you have additional components that have a graceful shutdown, you
-	// will want to launch a separate goroutine here, same as for publisher.
-
-	// Goroutine listening for all components to have completed shutdown
-	go func() {
-		componentWaitGroup.Wait()
-		componentDoneCh <- struct{}{}
-	}()
-
-	// Detect ctrl-c and gracefully shutdown
+	// Detect ctrl-c / SIGTERM and gracefully shutdown
 	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt)
-	signal.Notify(c, os.Kill)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 
-	// Block waiting for signal
-	sig := <-c
-	llog.Debug("Received system call", zap.Any("signal", sig))
+	// Block waiting for a signal, or for a component giving up
+	select {
+	case sig := <-c:
+		llog.Debug("Received system call", zap.Any("signal", sig))
+		shutdown(d, 0)
+	case <-d.ShutdownCtx.Done():
+		llog.Error("A component failed, shutting down")
+		shutdown(d, 1)
+	}
+}
+
+// shutdown stops all components in reverse order of startup and exits with
+// code, or 1 if they did not stop cleanly.
+func shutdown(d *deps.Dependencies, code int) {
+	llog := d.Log.With(zap.String("method", "shutdown"), zap.String("pkg", "main"))
 	llog.Debug("Shutting down all components...")
 
-	// Send termination signal to all components
+	// Tell all components that we are going down
 	d.ShutdownCancel()
 
-	select {
-	case <-componentDoneCh:
+	ctx, cancel := context.WithTimeout(context.Background(), GracefulShutdownTimeout)
+	defer cancel()
+
+	if err := d.Lifecycle.Stop(ctx); err != nil {
+		llog.Error("Graceful shutdown failed", zap.Error(err))
+		code = 1
+	} else {
 		llog.Debug("Graceful shutdown complete")
-		os.Exit(0)
-	case <-time.After(GracefulShutdownTimeout):
-		llog.Debug("Graceful shutdown timed out", zap.String("timeout", GracefulShutdownTimeout.String()))
-		os.Exit(1)
 	}
+
+	os.Exit(code)
 }
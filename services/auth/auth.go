@@ -0,0 +1,231 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	// SessionCookie is the cookie the login endpoint sets; browsers cannot
+	// set custom headers on websocket upgrades, so ServeWs accepts it too.
+	SessionCookie = "mnemo_session"
+
+	// SessionQueryParam is the last-resort session token location for
+	// websocket clients that can neither set headers nor cookies.
+	SessionQueryParam = "session"
+
+	secretLength = 32
+)
+
+var (
+	ErrNotFound           = errors.New("instructor not found")
+	ErrInvalidCredentials = errors.New("invalid email or password")
+	ErrInvalidToken       = errors.New("invalid session token")
+	ErrExpiredToken       = errors.New("session token expired")
+)
+
+type Instructor struct {
+	ID           string `json:"id"`
+	Email        string `json:"email"`
+	Name         string `json:"name,omitempty"`
+	PasswordHash []byte `json:"-"`
+}
+
+type InstructorStore interface {
+	GetInstructor(id string) (*Instructor, error)
+	GetInstructorByEmail(email string) (*Instructor, error)
+}
+
+type Session struct {
+	Token      string      `json:"token"`
+	ExpiresAt  time.Time   `json:"expires_at"`
+	Instructor *Instructor `json:"instructor"`
+}
+
+type claims struct {
+	Subject   string `json:"sub"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// Service authenticates instructors and issues HMAC-signed session tokens of
+// the form base64url(claims).base64url(signature).
+type Service struct {
+	store  InstructorStore
+	secret []byte
+	ttl    time.Duration
+}
+
+func New(store InstructorStore, secret []byte, ttl time.Duration) (*Service, error) {
+	if store == nil {
+		return nil, errors.New("store cannot be nil")
+	}
+
+	if len(secret) == 0 {
+		return nil, errors.New("secret cannot be empty")
+	}
+
+	if ttl <= 0 {
+		return nil, errors.New("ttl must be positive")
+	}
+
+	return &Service{
+		store:  store,
+		secret: secret,
+		ttl:    ttl,
+	}, nil
+}
+
+// NewSecret generates a random signing secret. Tokens signed with it do not
+// survive a restart, so it should only be used when no secret is configured.
+func NewSecret() ([]byte, error) {
+	secret := make([]byte, secretLength)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+
+	return secret, nil
+}
+
+func HashPassword(password string) ([]byte, error) {
+	return bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+}
+
+// Login checks the instructor's credentials and issues a new session.
+func (s *Service) Login(email, password string) (*Session, error) {
+	instructor, err := s.store.GetInstructorByEmail(NormalizeEmail(email))
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			return nil, ErrInvalidCredentials
+		}
+		return nil, err
+	}
+
+	if err := bcrypt.CompareHashAndPassword(instructor.PasswordHash, []byte(password)); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	token, expiresAt, err := s.IssueToken(instructor.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Session{
+		Token:      token,
+		ExpiresAt:  expiresAt,
+		Instructor: instructor,
+	}, nil
+}
+
+func (s *Service) IssueToken(instructorID string) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(s.ttl)
+
+	data, err := json.Marshal(claims{
+		Subject:   instructorID,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: expiresAt.Unix(),
+	})
+	if err != nil {
+		return "", time.Time{}, errors.Wrap(err, "unable to marshal claims")
+	}
+
+	payload := base64.RawURLEncoding.EncodeToString(data)
+
+	return payload + "." + s.sign(payload), expiresAt, nil
+}
+
+// Authenticate verifies the token and returns the instructor it was issued to.
+func (s *Service) Authenticate(token string) (*Instructor, error) {
+	payload, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+
+	if subtle.ConstantTimeCompare([]byte(signature), []byte(s.sign(payload))) != 1 {
+		return nil, ErrInvalidToken
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	var c claims
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	if time.Now().Unix() >= c.ExpiresAt {
+		return nil, ErrExpiredToken
+	}
+
+	instructor, err := s.store.GetInstructor(c.Subject)
+	if err != nil {
+		if errors.Is(err, ErrNotFound) {
+			// account was removed after the token was issued
+			return nil, ErrInvalidToken
+		}
+		return nil, err
+	}
+
+	return instructor, nil
+}
+
+func (s *Service) sign(payload string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(payload))
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// TokenFromRequest extracts an instructor session token from the
+// Authorization header, the session cookie or the session query parameter,
+// in that order. Student tokens have their own locations, so a student is
+// never mistaken for an instructor.
+func TokenFromRequest(r *http.Request) string {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(token)
+	}
+
+	if cookie, err := r.Cookie(SessionCookie); err == nil {
+		return cookie.Value
+	}
+
+	return r.URL.Query().Get(SessionQueryParam)
+}
+
+// ParseInstructor parses an "email:bcrypt-hash" account spec as accepted by
+// the --instructors flag.
+func ParseInstructor(spec string) (*Instructor, error) {
+	email, hash, ok := strings.Cut(spec, ":")
+	if !ok || email == "" || hash == "" {
+		return nil, errors.Errorf("invalid instructor spec '%s' (expected email:bcrypt-hash)", email)
+	}
+
+	if !strings.HasPrefix(hash, "$2") {
+		return nil, errors.Errorf("password for instructor '%s' is not a bcrypt hash", email)
+	}
+
+	email = NormalizeEmail(email)
+
+	return &Instructor{
+		ID:           email,
+		Email:        email,
+		PasswordHash: []byte(hash),
+	}, nil
+}
+
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
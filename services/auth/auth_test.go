@@ -0,0 +1,242 @@
+package auth
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testStore holds instructors by ID.
+type testStore map[string]*Instructor
+
+func (s testStore) GetInstructor(id string) (*Instructor, error) {
+	instructor, ok := s[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return instructor, nil
+}
+
+func (s testStore) GetInstructorByEmail(email string) (*Instructor, error) {
+	for _, instructor := range s {
+		if instructor.Email == email {
+			return instructor, nil
+		}
+	}
+
+	return nil, ErrNotFound
+}
+
+func newTestService(t *testing.T) (*Service, testStore) {
+	t.Helper()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("GenerateFromPassword: %v", err)
+	}
+
+	store := testStore{
+		"prof@example.com": {ID: "prof@example.com", Email: "prof@example.com", PasswordHash: hash},
+	}
+
+	s, err := New(store, []byte("test-secret"), time.Hour)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	return s, store
+}
+
+// signedToken signs arbitrary claims the way IssueToken does.
+func signedToken(s *Service, c claims) string {
+	data, _ := json.Marshal(c)
+	payload := base64.RawURLEncoding.EncodeToString(data)
+
+	return payload + "." + s.sign(payload)
+}
+
+func TestLogin(t *testing.T) {
+	s, _ := newTestService(t)
+
+	tests := []struct {
+		name     string
+		email    string
+		password string
+		wantErr  error
+	}{
+		{"valid credentials", "prof@example.com", "secret", nil},
+		{"email is normalized", " Prof@Example.com ", "secret", nil},
+		{"wrong password", "prof@example.com", "guess", ErrInvalidCredentials},
+		{"unknown email", "nobody@example.com", "secret", ErrInvalidCredentials},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session, err := s.Login(tt.email, tt.password)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Login = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			instructor, err := s.Authenticate(session.Token)
+			if err != nil {
+				t.Fatalf("Authenticate: %v", err)
+			}
+			if instructor.ID != "prof@example.com" {
+				t.Errorf("instructor = %s", instructor.ID)
+			}
+			if d := time.Until(session.ExpiresAt); d <= 0 || d > time.Hour {
+				t.Errorf("session expires in %v, want within an hour", d)
+			}
+		})
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	s, store := newTestService(t)
+
+	valid, _, err := s.IssueToken("prof@example.com")
+	if err != nil {
+		t.Fatalf("IssueToken: %v", err)
+	}
+	payload, signature, _ := strings.Cut(valid, ".")
+
+	other, err := New(store, []byte("other-secret"), time.Hour)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	foreign, _, err := other.IssueToken("prof@example.com")
+	if err != nil {
+		t.Fatalf("IssueToken: %v", err)
+	}
+
+	// the claims of another instructor under the valid token's signature
+	forged, _ := json.Marshal(claims{Subject: "admin@example.com", ExpiresAt: time.Now().Add(time.Hour).Unix()})
+
+	now := time.Now()
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr error
+	}{
+		{"valid", valid, nil},
+		{"empty", "", ErrInvalidToken},
+		{"no signature", payload, ErrInvalidToken},
+		{"tampered payload", base64.RawURLEncoding.EncodeToString(forged) + "." + signature, ErrInvalidToken},
+		{"tampered signature", payload + "." + strings.Repeat("A", len(signature)), ErrInvalidToken},
+		{"signed with another secret", foreign, ErrInvalidToken},
+		{"signed garbage", "bm90IGpzb24." + s.sign("bm90IGpzb24"), ErrInvalidToken},
+		{"expired", signedToken(s, claims{Subject: "prof@example.com", IssuedAt: now.Add(-2 * time.Hour).Unix(), ExpiresAt: now.Add(-time.Hour).Unix()}), ErrExpiredToken},
+		{"removed instructor", signedToken(s, claims{Subject: "gone@example.com", ExpiresAt: now.Add(time.Hour).Unix()}), ErrInvalidToken},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			instructor, err := s.Authenticate(tt.token)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Authenticate = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && instructor.ID != "prof@example.com" {
+				t.Errorf("instructor = %s", instructor.ID)
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name   string
+		store  InstructorStore
+		secret []byte
+		ttl    time.Duration
+	}{
+		{"no store", nil, []byte("secret"), time.Hour},
+		{"no secret", testStore{}, nil, time.Hour},
+		{"no ttl", testStore{}, []byte("secret"), 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(tt.store, tt.secret, tt.ttl); err == nil {
+				t.Fatal("New accepted invalid arguments")
+			}
+		})
+	}
+}
+
+func TestTokenFromRequest(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		header http.Header
+		cookie string
+		want   string
+	}{
+		{"none", "/", nil, "", ""},
+		{"bearer token", "/", http.Header{"Authorization": {"Bearer abc "}}, "", "abc"},
+		{"cookie", "/", nil, "abc", "abc"},
+		{"query parameter", "/?session=abc", nil, "", "abc"},
+		{"header wins", "/?session=query", http.Header{"Authorization": {"Bearer header"}}, "cookie", "header"},
+		{"cookie wins over query", "/?session=query", nil, "cookie", "cookie"},
+		{"basic auth is no session", "/", http.Header{"Authorization": {"Basic abc"}}, "", ""},
+		{"token query parameter is no session", "/?token=abc", nil, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			for key, values := range tt.header {
+				r.Header[key] = values
+			}
+			if tt.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: SessionCookie, Value: tt.cookie})
+			}
+
+			if got := TokenFromRequest(r); got != tt.want {
+				t.Errorf("TokenFromRequest = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseInstructor(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		want    string
+		wantErr bool
+	}{
+		{name: "valid", spec: "Prof@Example.com:$2a$10$hash", want: "prof@example.com"},
+		{name: "no separator", spec: "prof@example.com", wantErr: true},
+		{name: "no hash", spec: "prof@example.com:", wantErr: true},
+		{name: "no email", spec: ":$2a$10$hash", wantErr: true},
+		{name: "plain password", spec: "prof@example.com:secret", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			instructor, err := ParseInstructor(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseInstructor = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if instructor.ID != tt.want || instructor.Email != tt.want {
+				t.Errorf("instructor = %+v, want %s", instructor, tt.want)
+			}
+		})
+	}
+}
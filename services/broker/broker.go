@@ -0,0 +1,53 @@
+// Package broker fans messages out between mnemo instances, so several of
+// them behind a load balancer can share rooms.
+package broker
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var ErrClosed = errors.New("broker is closed")
+
+// Handler is called with every message published to a subscribed topic.
+type Handler func(data []byte)
+
+// Broker is a topic based publish/subscribe bus. Messages are delivered at
+// most once, to the subscribers present when they are published, and in
+// publish order per publisher and subscription. Leases give one instance at
+// a time ownership of something, like the hosting of a room.
+type Broker interface {
+	// Publish sends data to the current subscribers of topic and reports how
+	// many there were; distributed brokers count subscribed instances rather
+	// than subscriptions. Zero means nobody received it.
+	Publish(ctx context.Context, topic string, data []byte) (int, error)
+
+	// Subscribe calls handler for every message published to topic until the
+	// subscription is closed or ctx is done. Handler calls are sequential.
+	// The subscription is active when Subscribe returns.
+	Subscribe(ctx context.Context, topic string, handler Handler) (Subscription, error)
+
+	// Acquire takes the lease on key for holder, or renews it if holder
+	// has it already, and reports whether holder has it now. A lease that is
+	// not renewed within ttl expires, so the key is free again once its
+	// holder dies.
+	Acquire(ctx context.Context, key, holder string, ttl time.Duration) (bool, error)
+
+	// Release ends holder's lease on key. It does nothing if the lease
+	// expired or somebody else holds it.
+	Release(ctx context.Context, key, holder string) error
+
+	// Holder returns who holds the lease on key; empty if nobody does.
+	Holder(ctx context.Context, key string) (string, error)
+
+	// Ping checks that the broker is reachable.
+	Ping(ctx context.Context) error
+
+	Close() error
+}
+
+type Subscription interface {
+	Unsubscribe() error
+}
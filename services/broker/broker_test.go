@@ -0,0 +1,324 @@
+package broker
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+const receiveTimeout = 2 * time.Second
+
+// advanceFunc lets the broker's clock move on by d, so leases expire.
+type advanceFunc func(d time.Duration)
+
+func TestMemory(t *testing.T) {
+	testBroker(t, func(t *testing.T) (Broker, advanceFunc) {
+		return NewMemory(), time.Sleep
+	})
+}
+
+func TestRedis(t *testing.T) {
+	testBroker(t, func(t *testing.T) (Broker, advanceFunc) {
+		server := miniredis.RunT(t)
+
+		b, err := NewRedis(context.Background(), "redis://"+server.Addr())
+		if err != nil {
+			t.Fatalf("NewRedis: %v", err)
+		}
+
+		return b, server.FastForward
+	})
+}
+
+// testBroker is the contract every Broker implementation satisfies.
+func testBroker(t *testing.T, newBroker func(t *testing.T) (Broker, advanceFunc)) {
+	tests := []struct {
+		name string
+		run  func(t *testing.T, b Broker)
+	}{
+		{"delivers in publish order", testOrder},
+		{"delivers to every subscription", testFanout},
+		{"publish without subscribers", testNoSubscribers},
+		{"unsubscribe stops delivery", testUnsubscribe},
+		{"context ends subscription", testContextDone},
+		{"handler may publish", testHandlerPublishes},
+		{"topics are separate", testTopics},
+		{"closed broker", testClosed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, _ := newBroker(t)
+			t.Cleanup(func() { b.Close() })
+
+			tt.run(t, b)
+		})
+	}
+
+	t.Run("leases", func(t *testing.T) {
+		b, advance := newBroker(t)
+		t.Cleanup(func() { b.Close() })
+
+		testLeases(t, b, advance)
+	})
+}
+
+func testLeases(t *testing.T, b Broker, advance advanceFunc) {
+	ctx := context.Background()
+	ttl := 100 * time.Millisecond
+
+	steps := []struct {
+		name    string
+		do      func() (bool, error)
+		want    bool
+		holder  string        // holding "k" after the step
+		advance time.Duration // then let time pass
+	}{
+		{name: "free lease is taken", do: func() (bool, error) { return b.Acquire(ctx, "k", "a", ttl) }, want: true, holder: "a"},
+		{name: "held lease is refused", do: func() (bool, error) { return b.Acquire(ctx, "k", "b", ttl) }, want: false, holder: "a"},
+		{name: "holder renews", do: func() (bool, error) { return b.Acquire(ctx, "k", "a", ttl) }, want: true, holder: "a"},
+		{name: "others can't release", do: func() (bool, error) { return true, b.Release(ctx, "k", "b") }, want: true, holder: "a"},
+		{name: "holder releases", do: func() (bool, error) { return true, b.Release(ctx, "k", "a") }, want: true, holder: ""},
+		{name: "released lease is taken", do: func() (bool, error) { return b.Acquire(ctx, "k", "b", ttl) }, want: true, holder: "b", advance: 2 * ttl},
+		{name: "expired lease is free", do: func() (bool, error) { return true, nil }, want: true, holder: ""},
+		{name: "expired lease is taken", do: func() (bool, error) { return b.Acquire(ctx, "k", "a", ttl) }, want: true, holder: "a"},
+		{name: "other keys are separate", do: func() (bool, error) { return b.Acquire(ctx, "other", "b", ttl) }, want: true, holder: "a"},
+	}
+
+	for _, step := range steps {
+		got, err := step.do()
+		if err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if got != step.want {
+			t.Fatalf("%s: got %v, want %v", step.name, got, step.want)
+		}
+
+		holder, err := b.Holder(ctx, "k")
+		if err != nil {
+			t.Fatalf("%s: Holder: %v", step.name, err)
+		}
+		if holder != step.holder {
+			t.Fatalf("%s: holder %q, want %q", step.name, holder, step.holder)
+		}
+
+		if step.advance > 0 {
+			advance(step.advance)
+		}
+	}
+}
+
+// collect subscribes to topic and returns the channel its messages go to.
+func collect(t *testing.T, ctx context.Context, b Broker, topic string) (<-chan string, Subscription) {
+	t.Helper()
+
+	received := make(chan string, 100)
+	sub, err := b.Subscribe(ctx, topic, func(data []byte) {
+		received <- string(data)
+	})
+	if err != nil {
+		t.Fatalf("Subscribe(%s): %v", topic, err)
+	}
+
+	return received, sub
+}
+
+func publish(t *testing.T, b Broker, topic, msg string) int {
+	t.Helper()
+
+	n, err := b.Publish(context.Background(), topic, []byte(msg))
+	if err != nil {
+		t.Fatalf("Publish(%s): %v", topic, err)
+	}
+
+	return n
+}
+
+func expect(t *testing.T, received <-chan string, want ...string) {
+	t.Helper()
+
+	for _, w := range want {
+		select {
+		case got := <-received:
+			if got != w {
+				t.Fatalf("received %q, want %q", got, w)
+			}
+		case <-time.After(receiveTimeout):
+			t.Fatalf("timed out waiting for %q", w)
+		}
+	}
+}
+
+func expectNothing(t *testing.T, received <-chan string) {
+	t.Helper()
+
+	select {
+	case got := <-received:
+		t.Fatalf("received %q, want nothing", got)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func testOrder(t *testing.T, b Broker) {
+	received, _ := collect(t, context.Background(), b, "t")
+
+	var want []string
+	for i := 0; i < 50; i++ {
+		msg := fmt.Sprint(i)
+		want = append(want, msg)
+
+		// the subscription is active as soon as Subscribe returned
+		if n := publish(t, b, "t", msg); n == 0 {
+			t.Fatalf("Publish reported no subscribers")
+		}
+	}
+
+	expect(t, received, want...)
+}
+
+func testFanout(t *testing.T, b Broker) {
+	first, _ := collect(t, context.Background(), b, "t")
+	second, _ := collect(t, context.Background(), b, "t")
+
+	publish(t, b, "t", "hello")
+
+	expect(t, first, "hello")
+	expect(t, second, "hello")
+}
+
+func testNoSubscribers(t *testing.T, b Broker) {
+	if n := publish(t, b, "nobody", "hello"); n != 0 {
+		t.Fatalf("Publish reported %d subscribers, want 0", n)
+	}
+}
+
+func testUnsubscribe(t *testing.T, b Broker) {
+	received, sub := collect(t, context.Background(), b, "t")
+	other, _ := collect(t, context.Background(), b, "other")
+
+	if err := sub.Unsubscribe(); err != nil {
+		t.Fatalf("Unsubscribe: %v", err)
+	}
+
+	// the other topic's subscription keeps the connection busy, the
+	// unsubscribed one still has to go quiet
+	publish(t, b, "other", "ping")
+	expect(t, other, "ping")
+
+	// unsubscribing may take a moment to reach a remote server
+	deadline := time.Now().Add(receiveTimeout)
+	for publish(t, b, "t", "late") != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("topic still has subscribers after Unsubscribe")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	expectNothing(t, received)
+
+	// a second Unsubscribe is harmless
+	if err := sub.Unsubscribe(); err != nil {
+		t.Fatalf("second Unsubscribe: %v", err)
+	}
+
+	again, _ := collect(t, context.Background(), b, "t")
+	publish(t, b, "t", "back")
+	expect(t, again, "back")
+}
+
+func testContextDone(t *testing.T, b Broker) {
+	ctx, cancel := context.WithCancel(context.Background())
+	received, _ := collect(t, ctx, b, "t")
+
+	publish(t, b, "t", "before")
+	expect(t, received, "before")
+
+	cancel()
+
+	deadline := time.Now().Add(receiveTimeout)
+	for publish(t, b, "t", "after") != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("topic still has subscribers after the context is done")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	expectNothing(t, received)
+}
+
+func testHandlerPublishes(t *testing.T, b Broker) {
+	replies, _ := collect(t, context.Background(), b, "replies")
+
+	_, err := b.Subscribe(context.Background(), "requests", func(data []byte) {
+		if _, err := b.Publish(context.Background(), "replies", append([]byte("re: "), data...)); err != nil {
+			t.Errorf("Publish from handler: %v", err)
+		}
+	})
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+
+	publish(t, b, "requests", "a")
+	publish(t, b, "requests", "b")
+
+	expect(t, replies, "re: a", "re: b")
+}
+
+func testTopics(t *testing.T, b Broker) {
+	a, _ := collect(t, context.Background(), b, "a")
+	c, _ := collect(t, context.Background(), b, "c")
+
+	publish(t, b, "a", "for a")
+	publish(t, b, "c", "for c")
+
+	expect(t, a, "for a")
+	expect(t, c, "for c")
+	expectNothing(t, a)
+}
+
+func testClosed(t *testing.T, b Broker) {
+	received, _ := collect(t, context.Background(), b, "t")
+
+	if err := b.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	if _, err := b.Publish(context.Background(), "t", []byte("late")); err == nil {
+		t.Fatalf("Publish after Close succeeded")
+	}
+
+	if _, err := b.Subscribe(context.Background(), "t", func([]byte) {}); err == nil {
+		t.Fatalf("Subscribe after Close succeeded")
+	}
+
+	if err := b.Ping(context.Background()); err == nil {
+		t.Fatalf("Ping after Close succeeded")
+	}
+
+	expectNothing(t, received)
+}
+
+func TestRedisSharesConnection(t *testing.T) {
+	server := miniredis.RunT(t)
+
+	b, err := NewRedis(context.Background(), "redis://"+server.Addr())
+	if err != nil {
+		t.Fatalf("NewRedis: %v", err)
+	}
+	t.Cleanup(func() { b.Close() })
+
+	before := server.CurrentConnectionCount()
+
+	for i := 0; i < 20; i++ {
+		if _, err := b.Subscribe(context.Background(), fmt.Sprint("room:", i), func([]byte) {}); err != nil {
+			t.Fatalf("Subscribe: %v", err)
+		}
+	}
+
+	if got := server.CurrentConnectionCount() - before; got != 1 {
+		t.Fatalf("20 subscriptions opened %d connections, want 1", got)
+	}
+}
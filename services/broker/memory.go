@@ -0,0 +1,151 @@
+package broker
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Memory is an in-process Broker. It connects the rooms of a single
+// instance and serves as the reference for the distributed brokers.
+type Memory struct {
+	topics map[string]map[*subscription]bool
+	leases map[string]memoryLease
+	closed bool
+
+	sync sync.RWMutex
+}
+
+func NewMemory() *Memory {
+	return &Memory{
+		topics: make(map[string]map[*subscription]bool),
+		leases: make(map[string]memoryLease),
+	}
+}
+
+type memoryLease struct {
+	holder  string
+	expires time.Time
+}
+
+func (m *Memory) Publish(ctx context.Context, topic string, data []byte) (int, error) {
+	m.sync.RLock()
+	defer m.sync.RUnlock()
+
+	if m.closed {
+		return 0, ErrClosed
+	}
+
+	for sub := range m.topics[topic] {
+		// subscribers get their own copy, the caller may reuse data
+		sub.push(append([]byte(nil), data...))
+	}
+
+	return len(m.topics[topic]), nil
+}
+
+func (m *Memory) Ping(ctx context.Context) error {
+	m.sync.RLock()
+	defer m.sync.RUnlock()
+
+	if m.closed {
+		return ErrClosed
+	}
+
+	return nil
+}
+
+func (m *Memory) Subscribe(ctx context.Context, topic string, handler Handler) (Subscription, error) {
+	m.sync.Lock()
+	defer m.sync.Unlock()
+
+	if m.closed {
+		return nil, ErrClosed
+	}
+
+	var sub *subscription
+	sub = newSubscription(handler, func() {
+		m.sync.Lock()
+		defer m.sync.Unlock()
+
+		delete(m.topics[topic], sub)
+		if len(m.topics[topic]) == 0 {
+			delete(m.topics, topic)
+		}
+	})
+
+	if m.topics[topic] == nil {
+		m.topics[topic] = make(map[*subscription]bool)
+	}
+	m.topics[topic][sub] = true
+
+	go sub.run(ctx)
+
+	return sub, nil
+}
+
+func (m *Memory) Acquire(ctx context.Context, key, holder string, ttl time.Duration) (bool, error) {
+	m.sync.Lock()
+	defer m.sync.Unlock()
+
+	if m.closed {
+		return false, ErrClosed
+	}
+
+	now := time.Now()
+
+	if lease, ok := m.leases[key]; ok && lease.holder != holder && now.Before(lease.expires) {
+		return false, nil
+	}
+
+	m.leases[key] = memoryLease{holder: holder, expires: now.Add(ttl)}
+
+	return true, nil
+}
+
+func (m *Memory) Release(ctx context.Context, key, holder string) error {
+	m.sync.Lock()
+	defer m.sync.Unlock()
+
+	if m.closed {
+		return ErrClosed
+	}
+
+	if m.leases[key].holder == holder {
+		delete(m.leases, key)
+	}
+
+	return nil
+}
+
+func (m *Memory) Holder(ctx context.Context, key string) (string, error) {
+	m.sync.RLock()
+	defer m.sync.RUnlock()
+
+	if m.closed {
+		return "", ErrClosed
+	}
+
+	lease, ok := m.leases[key]
+	if !ok || !time.Now().Before(lease.expires) {
+		return "", nil
+	}
+
+	return lease.holder, nil
+}
+
+func (m *Memory) Close() error {
+	m.sync.Lock()
+	defer m.sync.Unlock()
+
+	m.closed = true
+
+	for _, subs := range m.topics {
+		for sub := range subs {
+			sub.stop()
+		}
+	}
+	m.topics = make(map[string]map[*subscription]bool)
+
+	return nil
+}
@@ -0,0 +1,239 @@
+package broker
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/redis/go-redis/v9"
+)
+
+// Redis is a Broker on Redis pub/sub, shared by every instance connected to
+// the same server. All subscriptions of a process share one connection;
+// topics are added to and removed from it as subscriptions come and go.
+type Redis struct {
+	client *redis.Client
+	pubsub *redis.PubSub
+
+	topics map[string]*redisTopic
+	closed bool
+
+	// dispatching is started with the first subscription
+	dispatching sync.Once
+
+	sync sync.Mutex
+}
+
+// redisTopic is a channel the shared connection is subscribed to.
+type redisTopic struct {
+	subs map[*subscription]bool
+
+	// ready is closed once redis confirmed the subscription
+	ready     chan struct{}
+	confirmed bool
+}
+
+// NewRedis connects to the server at url, e.g. redis://localhost:6379/0.
+func NewRedis(ctx context.Context, url string) (*Redis, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid redis url")
+	}
+
+	client := redis.NewClient(opts)
+
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, errors.Wrap(err, "unable to reach redis")
+	}
+
+	return &Redis{
+		client: client,
+		pubsub: client.Subscribe(context.Background()),
+		topics: make(map[string]*redisTopic),
+	}, nil
+}
+
+func (r *Redis) Publish(ctx context.Context, topic string, data []byte) (int, error) {
+	n, err := r.client.Publish(ctx, topic, data).Result()
+	if err != nil {
+		return 0, errors.Wrapf(err, "unable to publish to %s", topic)
+	}
+
+	return int(n), nil
+}
+
+func (r *Redis) Subscribe(ctx context.Context, topic string, handler Handler) (Subscription, error) {
+	r.sync.Lock()
+
+	if r.closed {
+		r.sync.Unlock()
+		return nil, ErrClosed
+	}
+
+	t, ok := r.topics[topic]
+	if !ok {
+		if err := r.pubsub.Subscribe(ctx, topic); err != nil {
+			r.sync.Unlock()
+			return nil, errors.Wrapf(err, "unable to subscribe to %s", topic)
+		}
+
+		t = &redisTopic{subs: make(map[*subscription]bool), ready: make(chan struct{})}
+		r.topics[topic] = t
+	}
+
+	var sub *subscription
+	sub = newSubscription(handler, func() {
+		r.detach(topic, sub)
+	})
+	t.subs[sub] = true
+	r.sync.Unlock()
+
+	r.dispatching.Do(func() {
+		go r.dispatch()
+	})
+
+	// wait for the confirmation, so publishes after Subscribe returns are seen
+	select {
+	case <-t.ready:
+	case <-ctx.Done():
+		sub.Unsubscribe()
+		return nil, errors.Wrapf(ctx.Err(), "unable to subscribe to %s", topic)
+	}
+
+	go sub.run(ctx)
+
+	return sub, nil
+}
+
+// detach removes the subscription and unsubscribes the connection from the
+// topic once nobody listens to it anymore.
+func (r *Redis) detach(topic string, sub *subscription) {
+	r.sync.Lock()
+	defer r.sync.Unlock()
+
+	t, ok := r.topics[topic]
+	if !ok || !t.subs[sub] {
+		return
+	}
+
+	delete(t.subs, sub)
+	if len(t.subs) > 0 || r.closed {
+		return
+	}
+
+	delete(r.topics, topic)
+
+	// a failure leaves the connection subscribed; dispatch drops what
+	// arrives for topics without subscriptions
+	_ = r.pubsub.Unsubscribe(context.Background(), topic)
+}
+
+// dispatch hands the messages of the shared connection to the subscriptions
+// of their topic until the broker is closed.
+func (r *Redis) dispatch() {
+	for msg := range r.pubsub.ChannelWithSubscriptions() {
+		switch msg := msg.(type) {
+		case *redis.Subscription:
+			// go-redis resubscribes after reconnecting, so topics can
+			// be confirmed more than once
+			if msg.Kind != "subscribe" {
+				continue
+			}
+
+			r.sync.Lock()
+			if t, ok := r.topics[msg.Channel]; ok && !t.confirmed {
+				t.confirmed = true
+				close(t.ready)
+			}
+			r.sync.Unlock()
+		case *redis.Message:
+			r.sync.Lock()
+			var subs []*subscription
+			if t, ok := r.topics[msg.Channel]; ok {
+				for sub := range t.subs {
+					subs = append(subs, sub)
+				}
+			}
+			r.sync.Unlock()
+
+			for _, sub := range subs {
+				sub.push([]byte(msg.Payload))
+			}
+		}
+	}
+}
+
+// acquireScript sets the lease if it is free or already the holder's, in one
+// step so two instances can't both take it.
+var acquireScript = redis.NewScript(`
+local current = redis.call('GET', KEYS[1])
+if current == false or current == ARGV[1] then
+	redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+	return 1
+end
+return 0
+`)
+
+var releaseScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+func (r *Redis) Acquire(ctx context.Context, key, holder string, ttl time.Duration) (bool, error) {
+	n, err := acquireScript.Run(ctx, r.client, []string{key}, holder, ttl.Milliseconds()).Int()
+	if err != nil {
+		return false, errors.Wrapf(err, "unable to acquire lease %s", key)
+	}
+
+	return n == 1, nil
+}
+
+func (r *Redis) Release(ctx context.Context, key, holder string) error {
+	if err := releaseScript.Run(ctx, r.client, []string{key}, holder).Err(); err != nil {
+		return errors.Wrapf(err, "unable to release lease %s", key)
+	}
+
+	return nil
+}
+
+func (r *Redis) Holder(ctx context.Context, key string) (string, error) {
+	holder, err := r.client.Get(ctx, key).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", nil
+	}
+	if err != nil {
+		return "", errors.Wrapf(err, "unable to look up lease %s", key)
+	}
+
+	return holder, nil
+}
+
+func (r *Redis) Ping(ctx context.Context) error {
+	if err := r.client.Ping(ctx).Err(); err != nil {
+		return errors.Wrap(err, "unable to reach redis")
+	}
+
+	return nil
+}
+
+func (r *Redis) Close() error {
+	r.sync.Lock()
+	r.closed = true
+	for _, t := range r.topics {
+		for sub := range t.subs {
+			sub.stop()
+		}
+	}
+	r.topics = make(map[string]*redisTopic)
+	r.sync.Unlock()
+
+	if err := r.pubsub.Close(); err != nil && !errors.Is(err, redis.ErrClosed) {
+		return errors.Wrap(err, "unable to close redis subscriptions")
+	}
+
+	return r.client.Close()
+}
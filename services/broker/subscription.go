@@ -0,0 +1,86 @@
+package broker
+
+import (
+	"context"
+	"sync"
+)
+
+// subscription queues messages without bound, so a publisher never waits for
+// a handler; a handler that publishes itself can't deadlock. Every broker
+// delivers through it, so all of them call handlers the same way.
+type subscription struct {
+	handler Handler
+
+	// detach removes the subscription from its broker
+	detach func()
+
+	queue [][]byte
+	wake  chan struct{}
+
+	done     chan struct{}
+	stopOnce sync.Once
+
+	sync sync.Mutex
+}
+
+func newSubscription(handler Handler, detach func()) *subscription {
+	return &subscription{
+		handler: handler,
+		detach:  detach,
+		wake:    make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+}
+
+func (s *subscription) push(data []byte) {
+	s.sync.Lock()
+	s.queue = append(s.queue, data)
+	s.sync.Unlock()
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *subscription) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			s.Unsubscribe()
+			return
+		case <-s.done:
+			return
+		case <-s.wake:
+		}
+
+		s.sync.Lock()
+		queue := s.queue
+		s.queue = nil
+		s.sync.Unlock()
+
+		for _, data := range queue {
+			select {
+			case <-s.done:
+				return
+			default:
+			}
+
+			s.handler(data)
+		}
+	}
+}
+
+// stop ends delivery without detaching from the broker.
+func (s *subscription) stop() {
+	s.stopOnce.Do(func() {
+		close(s.done)
+	})
+}
+
+func (s *subscription) Unsubscribe() error {
+	s.detach()
+	s.stop()
+
+	return nil
+}
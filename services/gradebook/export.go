@@ -0,0 +1,192 @@
+package gradebook
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/xuri/excelize/v2"
+)
+
+const (
+	gradesSheet    = "Gradebook"
+	questionsSheet = "Questions"
+)
+
+// Write encodes the gradebook in the given format.
+func (g *Gradebook) Write(format Format, w io.Writer) error {
+	switch format {
+	case FormatCSV:
+		return g.WriteCSV(w)
+	case FormatXLSX:
+		return g.WriteXLSX(w)
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(g)
+	default:
+		return errors.Wrapf(ErrUnknownFormat, "'%s'", format)
+	}
+}
+
+// WriteCSV writes the grades table: one row per student with answer,
+// correctness and response time columns per question, followed by totals.
+// Text that a spreadsheet would read as a formula is escaped, see csvValue.
+func (g *Gradebook) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+
+	for _, row := range g.table() {
+		record := make([]string, len(row))
+		for i, value := range row {
+			record[i] = csvValue(value)
+		}
+
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteXLSX writes a workbook with the grades table on the first sheet and
+// the questions with their correct answers on the second.
+func (g *Gradebook) WriteXLSX(w io.Writer) error {
+	f := excelize.NewFile()
+	defer f.Close()
+
+	if err := f.SetSheetName(f.GetSheetName(0), gradesSheet); err != nil {
+		return err
+	}
+
+	if err := writeSheet(f, gradesSheet, g.table()); err != nil {
+		return err
+	}
+
+	if _, err := f.NewSheet(questionsSheet); err != nil {
+		return err
+	}
+
+	if err := writeSheet(f, questionsSheet, g.questionTable()); err != nil {
+		return err
+	}
+
+	return f.Write(w)
+}
+
+// writeSheet writes the rows from the top left cell on. Strings are stored as
+// string cells, so text starting with = is shown as is and never evaluated.
+func writeSheet(f *excelize.File, sheet string, rows [][]any) error {
+	for i, row := range rows {
+		cell, err := excelize.CoordinatesToCellName(1, i+1)
+		if err != nil {
+			return err
+		}
+
+		if err := f.SetSheetRow(sheet, cell, &row); err != nil {
+			return errors.Wrapf(err, "unable to write %s row %d", sheet, i+1)
+		}
+	}
+
+	// keep the header visible while scrolling
+	return f.SetPanes(sheet, &excelize.Panes{
+		Freeze:      true,
+		YSplit:      1,
+		TopLeftCell: "A2",
+		ActivePane:  "bottomLeft",
+	})
+}
+
+// table lays the gradebook out as rows of cells. Unanswered questions are
+// empty cells; response times are in seconds.
+func (g *Gradebook) table() [][]any {
+	header := []any{"Name", "Student ID"}
+	for i := range g.Questions {
+		n := i + 1
+		header = append(header, fmt.Sprintf("Q%d answer", n), fmt.Sprintf("Q%d correct", n), fmt.Sprintf("Q%d time (s)", n))
+	}
+	header = append(header, "Answered", "Correct", "Score", "Avg time (s)")
+
+	rows := [][]any{header}
+
+	for _, student := range g.Students {
+		row := []any{student.Name, student.StudentID}
+
+		for i, cell := range student.Answers {
+			if cell == nil {
+				row = append(row, nil, nil, nil)
+				continue
+			}
+
+			row = append(row, g.Questions[i].answerText(cell.Choices), cell.Correct, seconds(cell.ResponseTimeMs))
+		}
+
+		row = append(row, student.Answered, student.Correct, student.Score, seconds(student.AvgResponseMs))
+		rows = append(rows, row)
+	}
+
+	return rows
+}
+
+func (g *Gradebook) questionTable() [][]any {
+	rows := [][]any{{"#", "Prompt", "Kind", "Correct answer", "Answered", "Correct", "Correct (%)"}}
+
+	for i, q := range g.Questions {
+		var percent any
+		if q.Answered > 0 {
+			percent = math.Round(float64(q.CorrectN*1000)/float64(q.Answered)) / 10
+		}
+
+		rows = append(rows, []any{i + 1, q.Prompt, string(q.Kind), q.answerText(q.Correct), q.Answered, q.CorrectN, percent})
+	}
+
+	return rows
+}
+
+func seconds(ms int64) float64 {
+	return float64(ms) / 1000
+}
+
+// formulaPrefixes are the characters spreadsheet applications start a
+// formula with, or skip before looking for one.
+const formulaPrefixes = "=+-@\t\r"
+
+// csvValue formats a cell. Strings hold what students and professors typed;
+// those a spreadsheet would evaluate get a leading apostrophe, which makes
+// it treat them as text.
+func csvValue(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case bool:
+		if v {
+			return "yes"
+		}
+		return "no"
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case string:
+		if v != "" && strings.ContainsRune(formulaPrefixes, rune(v[0])) {
+			return "'" + v
+		}
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// ParseFormat validates a format name.
+func ParseFormat(s string) (Format, error) {
+	switch format := Format(s); format {
+	case FormatCSV, FormatXLSX, FormatJSON:
+		return format, nil
+	default:
+		return "", errors.Wrapf(ErrUnknownFormat, "'%s'", s)
+	}
+}
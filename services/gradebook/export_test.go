@@ -0,0 +1,244 @@
+package gradebook
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+
+	"mnemo/services/quiz"
+)
+
+// testGradebook has two questions and two students; the texts students and
+// professors typed start with characters spreadsheets evaluate.
+func testGradebook() *Gradebook {
+	capital := &Column{
+		ID:      "q1",
+		Kind:    quiz.KindMultipleChoice,
+		Prompt:  "=HYPERLINK(\"http://evil.example\")",
+		Options: []quiz.Option{{ID: "a", Text: "Paris"}, {ID: "b", Text: "@SUM(A1:A9)"}},
+		Correct: []string{"a"},
+	}
+
+	truth := &Column{
+		ID:      "q2",
+		Kind:    quiz.KindTrueFalse,
+		Prompt:  "The earth is flat",
+		Options: []quiz.Option{{ID: quiz.OptionTrue, Text: "True"}, {ID: quiz.OptionFalse, Text: "False"}},
+		Correct: []string{quiz.OptionFalse},
+	}
+
+	for _, column := range []*Column{capital, truth} {
+		column.options = make(map[string]int, len(column.Options))
+		for i, option := range column.Options {
+			column.options[option.ID] = i
+		}
+	}
+
+	capital.Answered, capital.CorrectN = 2, 1
+	truth.Answered, truth.CorrectN = 1, 1
+
+	return &Gradebook{
+		Room:      "ABC123",
+		Professor: "prof@example.com",
+		CreatedAt: time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC),
+		Questions: []*Column{capital, truth},
+		Students: []*Row{
+			{
+				ParticipantID: "p1",
+				StudentID:     "s1",
+				Name:          "+Ada",
+				Answers: []*Cell{
+					{Choices: []string{"a"}, Correct: true, ResponseTimeMs: 1500, Points: 900},
+					{Choices: []string{quiz.OptionFalse}, Correct: true, ResponseTimeMs: 2500, Points: 800},
+				},
+				Score:         1700,
+				Answered:      2,
+				Correct:       2,
+				AvgResponseMs: 2000,
+			},
+			{
+				ParticipantID: "p2",
+				StudentID:     "s2",
+				Name:          "Bob",
+				Answers: []*Cell{
+					{Choices: []string{"b"}, Correct: false, ResponseTimeMs: 4000},
+					nil,
+				},
+				Answered:      1,
+				AvgResponseMs: 4000,
+			},
+		},
+	}
+}
+
+func TestCSVValue(t *testing.T) {
+	tests := []struct {
+		name  string
+		value any
+		want  string
+	}{
+		{"empty cell", nil, ""},
+		{"true", true, "yes"},
+		{"false", false, "no"},
+		{"seconds", 1.5, "1.5"},
+		{"whole seconds", 2.0, "2"},
+		{"integer", 1700, "1700"},
+		{"plain text", "Paris", "Paris"},
+		{"empty text", "", ""},
+		{"formula", "=1+1", "'=1+1"},
+		{"plus", "+1+1", "'+1+1"},
+		{"minus", "-1+1", "'-1+1"},
+		{"at", "@SUM(A1)", "'@SUM(A1)"},
+		{"tab", "\t=1+1", "'\t=1+1"},
+		{"carriage return", "\r=1+1", "'\r=1+1"},
+		{"formula characters inside text", "a=b+c", "a=b+c"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := csvValue(tt.value); got != tt.want {
+				t.Errorf("csvValue(%#v) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testGradebook().WriteCSV(&buf); err != nil {
+		t.Fatalf("WriteCSV: %v", err)
+	}
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("reading CSV: %v", err)
+	}
+
+	want := [][]string{
+		{"Name", "Student ID", "Q1 answer", "Q1 correct", "Q1 time (s)", "Q2 answer", "Q2 correct", "Q2 time (s)", "Answered", "Correct", "Score", "Avg time (s)"},
+		{"'+Ada", "s1", "Paris", "yes", "1.5", "False", "yes", "2.5", "2", "2", "1700", "2"},
+		{"Bob", "s2", "'@SUM(A1:A9)", "no", "4", "", "", "", "1", "0", "0", "4"},
+	}
+
+	if len(records) != len(want) {
+		t.Fatalf("got %d rows, want %d: %q", len(records), len(want), records)
+	}
+
+	for i := range want {
+		if len(records[i]) != len(want[i]) {
+			t.Fatalf("row %d = %q, want %q", i, records[i], want[i])
+		}
+
+		for j := range want[i] {
+			if records[i][j] != want[i][j] {
+				t.Errorf("row %d column %d = %q, want %q", i, j, records[i][j], want[i][j])
+			}
+		}
+	}
+}
+
+func TestWriteXLSX(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testGradebook().WriteXLSX(&buf); err != nil {
+		t.Fatalf("WriteXLSX: %v", err)
+	}
+
+	f, err := excelize.OpenReader(&buf)
+	if err != nil {
+		t.Fatalf("opening workbook: %v", err)
+	}
+	defer f.Close()
+
+	if sheets := f.GetSheetList(); len(sheets) != 2 || sheets[0] != gradesSheet || sheets[1] != questionsSheet {
+		t.Fatalf("sheets = %q, want %q and %q", sheets, gradesSheet, questionsSheet)
+	}
+
+	tests := []struct {
+		sheet string
+		cell  string
+		want  string
+		text  bool
+	}{
+		{gradesSheet, "A1", "Name", true},
+		{gradesSheet, "A2", "+Ada", true},
+		{gradesSheet, "C2", "Paris", true},
+		{gradesSheet, "C3", "@SUM(A1:A9)", true},
+		{gradesSheet, "E2", "1.5", false},
+		{gradesSheet, "F3", "", false},
+		{gradesSheet, "K2", "1700", false},
+		{questionsSheet, "B2", "=HYPERLINK(\"http://evil.example\")", true},
+		{questionsSheet, "D3", "False", true},
+		{questionsSheet, "G2", "50", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.sheet+"!"+tt.cell, func(t *testing.T) {
+			value, err := f.GetCellValue(tt.sheet, tt.cell)
+			if err != nil {
+				t.Fatalf("GetCellValue: %v", err)
+			}
+			if value != tt.want {
+				t.Errorf("value = %q, want %q", value, tt.want)
+			}
+
+			formula, err := f.GetCellFormula(tt.sheet, tt.cell)
+			if err != nil {
+				t.Fatalf("GetCellFormula: %v", err)
+			}
+			if formula != "" {
+				t.Errorf("cell holds formula %q", formula)
+			}
+
+			if !tt.text {
+				return
+			}
+
+			cellType, err := f.GetCellType(tt.sheet, tt.cell)
+			if err != nil {
+				t.Fatalf("GetCellType: %v", err)
+			}
+			if cellType != excelize.CellTypeSharedString && cellType != excelize.CellTypeInlineString {
+				t.Errorf("cell type = %v, want a string", cellType)
+			}
+		})
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := testGradebook().Write(FormatJSON, &buf); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	var got Gradebook
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("decoding JSON: %v", err)
+	}
+
+	if got.Room != "ABC123" || len(got.Questions) != 2 || len(got.Students) != 2 {
+		t.Fatalf("decoded %+v", got)
+	}
+
+	// JSON is not read by spreadsheets, so text stays as typed
+	if got.Questions[0].Prompt != "=HYPERLINK(\"http://evil.example\")" || got.Students[0].Name != "+Ada" {
+		t.Errorf("text was changed: prompt %q, name %q", got.Questions[0].Prompt, got.Students[0].Name)
+	}
+
+	if got.Students[1].Answers[1] != nil {
+		t.Errorf("unanswered question = %+v, want null", got.Students[1].Answers[1])
+	}
+
+	if a := got.Students[0].Answers[0]; a == nil || !a.Correct || a.ResponseTimeMs != 1500 || a.Points != 900 {
+		t.Errorf("answer = %+v", a)
+	}
+}
+
+func TestWriteUnknownFormat(t *testing.T) {
+	if err := testGradebook().Write(Format("pdf"), &bytes.Buffer{}); err == nil {
+		t.Fatal("Write accepted an unknown format")
+	}
+}
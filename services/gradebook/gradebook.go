@@ -0,0 +1,207 @@
+// Package gradebook turns a room's stored questions and answers into a
+// per-student results table that can be exported as CSV, XLSX or JSON.
+package gradebook
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"mnemo/services/quiz"
+	"mnemo/storage"
+)
+
+type Format string
+
+const (
+	FormatCSV  Format = "csv"
+	FormatXLSX Format = "xlsx"
+	FormatJSON Format = "json"
+)
+
+var ErrUnknownFormat = errors.New("unknown export format")
+
+// ContentType returns the MIME type of the format.
+func (f Format) ContentType() string {
+	switch f {
+	case FormatCSV:
+		return "text/csv; charset=utf-8"
+	case FormatXLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	default:
+		return "application/json"
+	}
+}
+
+// Gradebook holds the results of one room: a column per question and a row
+// per student.
+type Gradebook struct {
+	Room      string     `json:"room"`
+	Professor string     `json:"professor"`
+	CreatedAt time.Time  `json:"created_at"`
+	ClosedAt  *time.Time `json:"closed_at,omitempty"`
+
+	Questions []*Column `json:"questions"`
+	Students  []*Row    `json:"students"`
+}
+
+// Column describes one question and how the room did on it.
+type Column struct {
+	ID          string         `json:"id"`
+	Kind        quiz.Kind      `json:"kind"`
+	Prompt      string         `json:"prompt"`
+	Options     []quiz.Option  `json:"options"`
+	Correct     []string       `json:"correct"`
+	PublishedAt time.Time      `json:"published_at"`
+	Answered    int            `json:"answered"`
+	CorrectN    int            `json:"correct_count"`
+	options     map[string]int // option ID -> index
+}
+
+// Row is one student's results. Answers lines up with Gradebook.Questions;
+// unanswered questions are nil.
+type Row struct {
+	ParticipantID string  `json:"participant_id"`
+	StudentID     string  `json:"student_id,omitempty"`
+	Name          string  `json:"name"`
+	Answers       []*Cell `json:"answers"`
+
+	// totals
+	Score    int `json:"score"`
+	Answered int `json:"answered"`
+	Correct  int `json:"correct"`
+	// AvgResponseMs is the mean response time over answered questions
+	AvgResponseMs int64 `json:"avg_response_time_ms"`
+}
+
+type Cell struct {
+	Choices        []string `json:"choices"`
+	Correct        bool     `json:"correct"`
+	ResponseTimeMs int64    `json:"response_time_ms"`
+	Points         int      `json:"points"`
+}
+
+// Build loads everything stored for the room into a gradebook. Students are
+// sorted by name, questions by publish time.
+func Build(ctx context.Context, store storage.Repository, roomID string) (*Gradebook, error) {
+	room, err := store.GetRoom(ctx, roomID)
+	if err != nil {
+		return nil, err
+	}
+
+	questions, err := store.ListQuestions(ctx, room.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	participants, err := store.ListParticipants(ctx, room.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	answers, err := store.ListAnswers(ctx, room.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	g := &Gradebook{
+		Room:      room.ID,
+		Professor: room.Professor,
+		CreatedAt: room.CreatedAt,
+		ClosedAt:  room.ClosedAt,
+		Questions: make([]*Column, 0, len(questions)),
+		Students:  make([]*Row, 0, len(participants)),
+	}
+
+	columns := make(map[string]int, len(questions))
+	for i, q := range questions {
+		column := &Column{
+			ID:          q.ID,
+			Kind:        q.Kind,
+			Prompt:      q.Prompt,
+			Options:     q.Options,
+			Correct:     q.Correct,
+			PublishedAt: q.PublishedAt,
+			options:     make(map[string]int, len(q.Options)),
+		}
+
+		for j, option := range q.Options {
+			column.options[option.ID] = j
+		}
+
+		columns[q.ID] = i
+		g.Questions = append(g.Questions, column)
+	}
+
+	rows := make(map[string]*Row, len(participants))
+	for _, p := range participants {
+		row := &Row{
+			ParticipantID: p.ID,
+			StudentID:     p.StudentID,
+			Name:          p.Name,
+			Score:         p.Score,
+			Answers:       make([]*Cell, len(questions)),
+		}
+
+		rows[p.ID] = row
+		g.Students = append(g.Students, row)
+	}
+
+	responseTimes := make(map[*Row]time.Duration)
+
+	for _, a := range answers {
+		i, ok := columns[a.QuestionID]
+		if !ok {
+			continue
+		}
+
+		row, ok := rows[a.ParticipantID]
+		if !ok {
+			continue
+		}
+
+		row.Answers[i] = &Cell{
+			Choices:        a.Choices,
+			Correct:        a.Correct,
+			ResponseTimeMs: a.ResponseTime.Milliseconds(),
+			Points:         a.Points,
+		}
+
+		row.Answered++
+		responseTimes[row] += a.ResponseTime
+		g.Questions[i].Answered++
+
+		if a.Correct {
+			row.Correct++
+			g.Questions[i].CorrectN++
+		}
+	}
+
+	for row, total := range responseTimes {
+		row.AvgResponseMs = (total / time.Duration(row.Answered)).Milliseconds()
+	}
+
+	sort.SliceStable(g.Students, func(i, j int) bool {
+		return strings.ToLower(g.Students[i].Name) < strings.ToLower(g.Students[j].Name)
+	})
+
+	return g, nil
+}
+
+// answerText renders the chosen options by their text, falling back to the
+// option ID for options that no longer exist.
+func (c *Column) answerText(choices []string) string {
+	texts := make([]string, 0, len(choices))
+	for _, id := range choices {
+		if i, ok := c.options[id]; ok {
+			texts = append(texts, c.Options[i].Text)
+		} else {
+			texts = append(texts, id)
+		}
+	}
+
+	return strings.Join(texts, "; ")
+}
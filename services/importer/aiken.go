@@ -0,0 +1,110 @@
+package importer
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"strings"
+
+	"mnemo/services/quiz"
+	"mnemo/storage"
+)
+
+var (
+	aikenOption = regexp.MustCompile(`^([A-Za-z])[.)]\s+(.+)$`)
+	aikenAnswer = regexp.MustCompile(`^ANSWER:\s*([A-Za-z])\s*$`)
+)
+
+// ParseAiken parses the Aiken format: a question, lettered options and an
+// ANSWER line.
+//
+//	What is 2 + 2?
+//	A. 3
+//	B. 4
+//	ANSWER: B
+func ParseAiken(r io.Reader) (*Result, error) {
+	result := newResult()
+
+	var (
+		q      *storage.BankQuestion
+		start  int
+		prompt []string
+		ids    map[string]bool
+		broken bool // an error was already reported for q
+	)
+
+	reset := func() {
+		q, prompt, ids, broken = nil, nil, nil, false
+	}
+
+	scanner := bufio.NewScanner(r)
+	line := 0
+
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+
+		if text == "" {
+			// a blank line in the middle of a question means it was never
+			// finished with an ANSWER line
+			if q != nil && len(q.Options) > 0 {
+				if !broken {
+					result.addError(start, "missing ANSWER line")
+				}
+				reset()
+			}
+			continue
+		}
+
+		if q == nil {
+			q = &storage.BankQuestion{Question: quiz.Question{Kind: quiz.KindMultipleChoice}}
+			ids = make(map[string]bool)
+			start = line
+		}
+
+		if m := aikenAnswer.FindStringSubmatch(text); m != nil {
+			id := strings.ToLower(m[1])
+			q.Prompt = strings.Join(prompt, "\n")
+
+			switch {
+			case broken:
+				// already reported
+			case !ids[id]:
+				result.addError(start, "answer '%s' does not match any option", m[1])
+			default:
+				q.Correct = []string{id}
+				result.addQuestion(start, q)
+			}
+
+			reset()
+			continue
+		}
+
+		if m := aikenOption.FindStringSubmatch(text); m != nil && len(prompt) > 0 {
+			id := strings.ToLower(m[1])
+			q.Options = append(q.Options, quiz.Option{ID: id, Text: m[2]})
+			ids[id] = true
+			continue
+		}
+
+		if len(q.Options) > 0 {
+			if !broken {
+				result.addError(line, "unexpected text after options")
+				broken = true
+			}
+			continue
+		}
+
+		prompt = append(prompt, text)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if q != nil && !broken {
+		result.addError(start, "missing ANSWER line")
+	}
+
+	return result, nil
+}
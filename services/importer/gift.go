@@ -0,0 +1,300 @@
+package importer
+
+import (
+	"bufio"
+	"io"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"mnemo/services/quiz"
+	"mnemo/storage"
+)
+
+var (
+	giftTitle  = regexp.MustCompile(`^::(.*?)::`)
+	giftMarkup = regexp.MustCompile(`^\[(html|moodle|plain|markdown)\]`)
+	giftWeight = regexp.MustCompile(`^%(-?[0-9.]+)%`)
+)
+
+type giftAnswer struct {
+	correct bool
+	weight  float64
+	text    string
+}
+
+// ParseGIFT parses Moodle's GIFT format. Multiple choice ({=a ~b}), true/false
+// ({T}, {FALSE}) and weighted multi-select ({~%50%a ~%50%b ~%-100%c})
+// questions are supported; general feedback (####) becomes the explanation
+// and $CATEGORY lines become a tag on the questions that follow.
+func ParseGIFT(r io.Reader) (*Result, error) {
+	result := newResult()
+
+	var (
+		block    []string
+		start    int
+		category string
+	)
+
+	flush := func() {
+		if len(block) > 0 {
+			parseGIFTQuestion(result, start, strings.Join(block, "\n"), category)
+		}
+		block = nil
+	}
+
+	scanner := bufio.NewScanner(r)
+	line := 0
+
+	for scanner.Scan() {
+		line++
+		text := strings.TrimRight(scanner.Text(), " \t\r")
+		trimmed := strings.TrimSpace(text)
+
+		switch {
+		case strings.HasPrefix(trimmed, "//"):
+			continue
+		case strings.HasPrefix(trimmed, "$CATEGORY:"):
+			flush()
+			category = path.Base(strings.TrimSpace(strings.TrimPrefix(trimmed, "$CATEGORY:")))
+			continue
+		case trimmed == "":
+			flush()
+			continue
+		}
+
+		if len(block) == 0 {
+			start = line
+		}
+		block = append(block, text)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	flush()
+
+	return result, nil
+}
+
+func parseGIFTQuestion(result *Result, line int, text, category string) {
+	text = strings.TrimSpace(giftTitle.ReplaceAllString(strings.TrimSpace(text), ""))
+	text = strings.TrimSpace(giftMarkup.ReplaceAllString(text, ""))
+
+	open := indexUnescaped(text, '{')
+	if open < 0 {
+		result.addError(line, "missing answer block")
+		return
+	}
+
+	closing := indexUnescaped(text[open:], '}')
+	if closing < 0 {
+		result.addError(line, "unterminated answer block")
+		return
+	}
+	closing += open
+
+	// text after the block turns the question into a fill-in-the-blank
+	prompt := strings.TrimSpace(text[:open])
+	if rest := strings.TrimSpace(text[closing+1:]); rest != "" {
+		prompt += " _____ " + rest
+	}
+
+	q := &storage.BankQuestion{
+		Question: quiz.Question{Prompt: unescapeGIFT(prompt)},
+	}
+
+	if category != "" && category != "." {
+		q.Tags = []string{category}
+	}
+
+	body, explanation := splitUnescaped(text[open+1:closing], "####")
+	q.Explanation = unescapeGIFT(strings.TrimSpace(explanation))
+
+	if err := parseGIFTAnswers(q, strings.TrimSpace(body)); err != nil {
+		result.addError(line, err.Error())
+		return
+	}
+
+	result.addQuestion(line, q)
+}
+
+func parseGIFTAnswers(q *storage.BankQuestion, body string) error {
+	answer, _ := splitUnescaped(body, "#")
+	switch strings.ToUpper(strings.TrimSpace(answer)) {
+	case "T", "TRUE":
+		q.Kind = quiz.KindTrueFalse
+		q.Correct = []string{quiz.OptionTrue}
+		return nil
+	case "F", "FALSE":
+		q.Kind = quiz.KindTrueFalse
+		q.Correct = []string{quiz.OptionFalse}
+		return nil
+	}
+
+	if strings.HasPrefix(body, "#") {
+		return errors.New("numerical questions are not supported")
+	}
+
+	answers, err := splitGIFTAnswers(body)
+	if err != nil {
+		return err
+	}
+
+	var (
+		right, weighted int
+		wrong           int
+	)
+
+	for _, a := range answers {
+		switch {
+		case a.correct:
+			right++
+		case a.weight > 0:
+			weighted++
+		default:
+			wrong++
+		}
+	}
+
+	switch {
+	case right > 0 && wrong == 0 && weighted == 0:
+		return errors.New("short answer questions are not supported")
+	case right == 1 && weighted == 0:
+		q.Kind = quiz.KindMultipleChoice
+	case right == 0 && weighted > 0:
+		q.Kind = quiz.KindMultiSelect
+	default:
+		return errors.New("question must have exactly one =answer or weighted ~%n% answers")
+	}
+
+	for i, a := range answers {
+		id := optionID(i)
+		q.Options = append(q.Options, quiz.Option{ID: id, Text: a.text})
+
+		if a.correct || a.weight > 0 {
+			q.Correct = append(q.Correct, id)
+		}
+	}
+
+	return nil
+}
+
+// splitGIFTAnswers splits "=a#feedback ~b ~%50%c" into its answers.
+func splitGIFTAnswers(body string) ([]giftAnswer, error) {
+	var (
+		answers []giftAnswer
+		current *strings.Builder
+		marker  byte
+	)
+
+	finish := func() error {
+		if current == nil {
+			return nil
+		}
+
+		text, _ := splitUnescaped(current.String(), "#")
+		text = strings.TrimSpace(text)
+
+		a := giftAnswer{correct: marker == '='}
+
+		if m := giftWeight.FindStringSubmatch(text); m != nil {
+			weight, err := strconv.ParseFloat(m[1], 64)
+			if err != nil {
+				return errors.Errorf("invalid answer weight '%s'", m[1])
+			}
+			a.weight = weight
+			text = strings.TrimSpace(text[len(m[0]):])
+		}
+
+		a.text = unescapeGIFT(text)
+		if a.text == "" {
+			return errors.New("empty answer")
+		}
+
+		answers = append(answers, a)
+		return nil
+	}
+
+	for i := 0; i < len(body); i++ {
+		c := body[i]
+
+		if c == '\\' && i+1 < len(body) {
+			if current != nil {
+				current.WriteByte(c)
+				current.WriteByte(body[i+1])
+			}
+			i++
+			continue
+		}
+
+		if c == '=' || c == '~' {
+			if err := finish(); err != nil {
+				return nil, err
+			}
+			current = &strings.Builder{}
+			marker = c
+			continue
+		}
+
+		if current == nil {
+			if c == ' ' || c == '\t' || c == '\n' {
+				continue
+			}
+			return nil, errors.New("answers must start with = or ~")
+		}
+
+		current.WriteByte(c)
+	}
+
+	if err := finish(); err != nil {
+		return nil, err
+	}
+
+	if len(answers) == 0 {
+		return nil, errors.New("no answers")
+	}
+
+	return answers, nil
+}
+
+// indexUnescaped returns the index of the first c not preceded by a
+// backslash, or -1.
+func indexUnescaped(s string, c byte) int {
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' {
+			i++
+			continue
+		}
+		if s[i] == c {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// splitUnescaped splits s around the first unescaped sep.
+func splitUnescaped(s, sep string) (string, string) {
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' {
+			i++
+			continue
+		}
+		if strings.HasPrefix(s[i:], sep) {
+			return s[:i], s[i+len(sep):]
+		}
+	}
+
+	return s, ""
+}
+
+var giftUnescaper = strings.NewReplacer(`\~`, "~", `\=`, "=", `\#`, "#", `\{`, "{", `\}`, "}", `\:`, ":", `\n`, "\n", `\\`, `\`)
+
+func unescapeGIFT(s string) string {
+	return giftUnescaper.Replace(s)
+}
@@ -0,0 +1,121 @@
+package importer
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"mnemo/storage"
+)
+
+type Format string
+
+const (
+	FormatGIFT      Format = "gift"
+	FormatAiken     Format = "aiken"
+	FormatMoodleXML Format = "moodle-xml"
+)
+
+var ErrUnknownFormat = errors.New("unknown import format")
+
+// ParseError describes a question that could not be imported. Line is the
+// 1-based line the question starts on.
+type ParseError struct {
+	Line    int    `json:"line"`
+	Message string `json:"message"`
+}
+
+func (e ParseError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Message)
+}
+
+// Result holds every question that parsed and validated, plus one error per
+// question that did not. A file with some broken questions still yields the
+// good ones.
+type Result struct {
+	Questions []*storage.BankQuestion `json:"questions"`
+	Errors    []ParseError            `json:"errors"`
+}
+
+func (r *Result) addQuestion(line int, q *storage.BankQuestion) {
+	if q.Tags == nil {
+		q.Tags = []string{}
+	}
+
+	if err := q.Validate(); err != nil {
+		r.addError(line, err.Error())
+		return
+	}
+
+	r.Questions = append(r.Questions, q)
+}
+
+func (r *Result) addError(line int, format string, args ...any) {
+	r.Errors = append(r.Errors, ParseError{Line: line, Message: fmt.Sprintf(format, args...)})
+}
+
+func newResult() *Result {
+	return &Result{
+		Questions: make([]*storage.BankQuestion, 0),
+		Errors:    make([]ParseError, 0),
+	}
+}
+
+// Parse reads questions in the given format. The returned error is only set
+// for problems reading the input as a whole; per-question problems are
+// reported in Result.Errors.
+func Parse(format Format, r io.Reader) (*Result, error) {
+	switch format {
+	case FormatGIFT:
+		return ParseGIFT(r)
+	case FormatAiken:
+		return ParseAiken(r)
+	case FormatMoodleXML:
+		return ParseMoodleXML(r)
+	default:
+		return nil, errors.Wrapf(ErrUnknownFormat, "'%s'", format)
+	}
+}
+
+// DetectFormat guesses the format from a file name's extension.
+func DetectFormat(filename string) (Format, bool) {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".gift":
+		return FormatGIFT, true
+	case ".xml":
+		return FormatMoodleXML, true
+	case ".txt", ".aiken":
+		return FormatAiken, true
+	default:
+		return "", false
+	}
+}
+
+// Save appends the questions to the end of the bank, assigning fresh IDs.
+// Either all of them are saved or none.
+func Save(ctx context.Context, store storage.Repository, bankID string, questions []*storage.BankQuestion) error {
+	for _, q := range questions {
+		id, err := storage.NewID()
+		if err != nil {
+			return errors.Wrap(err, "unable to generate question id")
+		}
+
+		q.ID = id
+		q.BankID = bankID
+	}
+
+	return store.CreateBankQuestions(ctx, questions)
+}
+
+// optionID returns the option ID for the i-th option: "a", "b", ...
+func optionID(i int) string {
+	if i < 26 {
+		return string(rune('a' + i))
+	}
+
+	return fmt.Sprintf("o%d", i+1)
+}
@@ -0,0 +1,384 @@
+package importer
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"mnemo/services/quiz"
+)
+
+// wantQuestion is the part of an imported question the tests look at.
+type wantQuestion struct {
+	kind        quiz.Kind
+	prompt      string
+	options     []string // option texts, in order
+	correct     []string
+	explanation string
+	tags        []string
+}
+
+type wantError struct {
+	line     int
+	contains string
+}
+
+type parseTest struct {
+	name      string
+	input     string
+	questions []wantQuestion
+	errors    []wantError
+}
+
+func runParseTests(t *testing.T, parse func(string) (*Result, error), tests []parseTest) {
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := parse(tt.input)
+			if err != nil {
+				t.Fatalf("parse: %v", err)
+			}
+
+			if len(result.Questions) != len(tt.questions) {
+				t.Fatalf("got %d questions, want %d (errors: %v)", len(result.Questions), len(tt.questions), result.Errors)
+			}
+
+			for i, want := range tt.questions {
+				q := result.Questions[i]
+
+				var options []string
+				for _, o := range q.Options {
+					options = append(options, o.Text)
+				}
+
+				got := wantQuestion{
+					kind:        q.Kind,
+					prompt:      q.Prompt,
+					options:     options,
+					correct:     q.Correct,
+					explanation: q.Explanation,
+					tags:        q.Tags,
+				}
+				if want.tags == nil {
+					want.tags = []string{}
+				}
+
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("question %d:\n got %+v\nwant %+v", i, got, want)
+				}
+			}
+
+			if len(result.Errors) != len(tt.errors) {
+				t.Fatalf("got errors %v, want %d", result.Errors, len(tt.errors))
+			}
+
+			for i, want := range tt.errors {
+				got := result.Errors[i]
+				if got.Line != want.line || !strings.Contains(got.Message, want.contains) {
+					t.Errorf("error %d: got %v, want line %d containing %q", i, got, want.line, want.contains)
+				}
+			}
+		})
+	}
+}
+
+func TestParseGIFT(t *testing.T) {
+	runParseTests(t, func(s string) (*Result, error) { return ParseGIFT(strings.NewReader(s)) }, []parseTest{
+		{
+			name:  "multiple choice with title and feedback",
+			input: "::Sum:: What is 2 + 2? {=4 ~3 ~5 ####Count them.}",
+			questions: []wantQuestion{{
+				kind:        quiz.KindMultipleChoice,
+				prompt:      "What is 2 + 2?",
+				options:     []string{"4", "3", "5"},
+				correct:     []string{"a"},
+				explanation: "Count them.",
+			}},
+		},
+		{
+			name:  "answer feedback is dropped",
+			input: "Capital of France? {~Lyon#No, that's a city. =Paris#Right!}",
+			questions: []wantQuestion{{
+				kind:    quiz.KindMultipleChoice,
+				prompt:  "Capital of France?",
+				options: []string{"Lyon", "Paris"},
+				correct: []string{"b"},
+			}},
+		},
+		{
+			name:  "escapes",
+			input: `Which is \{a set\} with a\: b \= c? {=x \~ y ~a \# b ~back\\slash}`,
+			questions: []wantQuestion{{
+				kind:    quiz.KindMultipleChoice,
+				prompt:  "Which is {a set} with a: b = c?",
+				options: []string{"x ~ y", "a # b", `back\slash`},
+				correct: []string{"a"},
+			}},
+		},
+		{
+			name:  "true and false",
+			input: "The sky is blue. {T}\n\nFish can fly. {FALSE}",
+			questions: []wantQuestion{
+				{kind: quiz.KindTrueFalse, prompt: "The sky is blue.", options: []string{"True", "False"}, correct: []string{quiz.OptionTrue}},
+				{kind: quiz.KindTrueFalse, prompt: "Fish can fly.", options: []string{"True", "False"}, correct: []string{quiz.OptionFalse}},
+			},
+		},
+		{
+			name:  "weighted multi-select",
+			input: "Primes? {~%50%2 ~%50%3 ~%-100%4}",
+			questions: []wantQuestion{{
+				kind:    quiz.KindMultiSelect,
+				prompt:  "Primes?",
+				options: []string{"2", "3", "4"},
+				correct: []string{"a", "b"},
+			}},
+		},
+		{
+			name:  "fractional weights",
+			input: "Pick the vowels. {~%33.3%a ~%33.3%e ~%33.3%i ~%-50%k}",
+			questions: []wantQuestion{{
+				kind:    quiz.KindMultiSelect,
+				prompt:  "Pick the vowels.",
+				options: []string{"a", "e", "i", "k"},
+				correct: []string{"a", "b", "c"},
+			}},
+		},
+		{
+			name:  "fill in the blank",
+			input: "The {=sun ~moon} rises in the east.",
+			questions: []wantQuestion{{
+				kind:    quiz.KindMultipleChoice,
+				prompt:  "The _____ rises in the east.",
+				options: []string{"sun", "moon"},
+				correct: []string{"a"},
+			}},
+		},
+		{
+			name: "category tags the questions that follow",
+			input: "Untagged? {T}\n\n" +
+				"$CATEGORY: $course$/top/Algebra\n\n" +
+				"x + 1 = 2? {=1 ~2}\n\n" +
+				"Is 0 even? {T}\n\n" +
+				"$CATEGORY: $course$/top/Geometry\n" +
+				"Sides of a square? {=4 ~3}",
+			questions: []wantQuestion{
+				{kind: quiz.KindTrueFalse, prompt: "Untagged?", options: []string{"True", "False"}, correct: []string{quiz.OptionTrue}},
+				{kind: quiz.KindMultipleChoice, prompt: "x + 1 = 2?", options: []string{"1", "2"}, correct: []string{"a"}, tags: []string{"Algebra"}},
+				{kind: quiz.KindTrueFalse, prompt: "Is 0 even?", options: []string{"True", "False"}, correct: []string{quiz.OptionTrue}, tags: []string{"Algebra"}},
+				{kind: quiz.KindMultipleChoice, prompt: "Sides of a square?", options: []string{"4", "3"}, correct: []string{"a"}, tags: []string{"Geometry"}},
+			},
+		},
+		{
+			name:  "multi-line question and comments",
+			input: "// a comment\n::Long::\nWhich one\nis right? {\n=this\n~that\n}",
+			questions: []wantQuestion{{
+				kind:    quiz.KindMultipleChoice,
+				prompt:  "Which one\nis right?",
+				options: []string{"this", "that"},
+				correct: []string{"a"},
+			}},
+		},
+		{
+			name: "errors carry the line each question starts on",
+			input: "Good? {T}\n" + // 1
+				"\n" +
+				"No answers here\n" + // 3
+				"\n" +
+				"// comment\n" +
+				"How much? {#42}\n" + // 6
+				"\n" +
+				"Name it. {=only}\n" + // 8
+				"\n" +
+				"Two right? {=a =b ~c}\n" + // 10
+				"\n" +
+				"Never closed {=a ~b\n" + // 12
+				"\n" +
+				"Fine too? {=yes ~no}", // 14
+			questions: []wantQuestion{
+				{kind: quiz.KindTrueFalse, prompt: "Good?", options: []string{"True", "False"}, correct: []string{quiz.OptionTrue}},
+				{kind: quiz.KindMultipleChoice, prompt: "Fine too?", options: []string{"yes", "no"}, correct: []string{"a"}},
+			},
+			errors: []wantError{
+				{line: 3, contains: "missing answer block"},
+				{line: 6, contains: "numerical"},
+				{line: 8, contains: "short answer"},
+				{line: 10, contains: "exactly one"},
+				{line: 12, contains: "unterminated"},
+			},
+		},
+		{
+			name:   "no positive weight",
+			input:  "Weights? {~%-50%a ~b}",
+			errors: []wantError{{line: 1, contains: "exactly one"}},
+		},
+	})
+}
+
+func TestParseAiken(t *testing.T) {
+	runParseTests(t, func(s string) (*Result, error) { return ParseAiken(strings.NewReader(s)) }, []parseTest{
+		{
+			name:  "single question",
+			input: "What is 2 + 2?\nA. 3\nB. 4\nANSWER: B",
+			questions: []wantQuestion{{
+				kind:    quiz.KindMultipleChoice,
+				prompt:  "What is 2 + 2?",
+				options: []string{"3", "4"},
+				correct: []string{"b"},
+			}},
+		},
+		{
+			name:  "multi-line prompt and parenthesized options",
+			input: "Read this.\nThen answer:\na) yes\nb) no\nANSWER: a",
+			questions: []wantQuestion{{
+				kind:    quiz.KindMultipleChoice,
+				prompt:  "Read this.\nThen answer:",
+				options: []string{"yes", "no"},
+				correct: []string{"a"},
+			}},
+		},
+		{
+			name: "errors carry the line each question starts on",
+			input: "First?\nA. x\nB. y\nANSWER: A\n" + // 1
+				"\n" +
+				"Second?\nA. x\nB. y\nANSWER: C\n" + // 6
+				"\n" +
+				"Third?\nA. x\nstray text\nB. y\nANSWER: B\n" + // 11
+				"\n" +
+				"Fourth?\nA. x\nB. y\n" + // 17
+				"\n" +
+				"Fifth?\nA. x\nB. y", // 21
+			questions: []wantQuestion{
+				{kind: quiz.KindMultipleChoice, prompt: "First?", options: []string{"x", "y"}, correct: []string{"a"}},
+			},
+			errors: []wantError{
+				{line: 6, contains: "does not match"},
+				{line: 13, contains: "unexpected text"},
+				{line: 17, contains: "missing ANSWER"},
+				{line: 21, contains: "missing ANSWER"},
+			},
+		},
+		{
+			name:   "too few options",
+			input:  "Only one?\nA. yes\nANSWER: A",
+			errors: []wantError{{line: 1, contains: "option"}},
+		},
+	})
+}
+
+func TestParseMoodleXML(t *testing.T) {
+	runParseTests(t, func(s string) (*Result, error) { return ParseMoodleXML(strings.NewReader(s)) }, []parseTest{
+		{
+			name: "multichoice, multi-select and true/false under categories",
+			input: `<?xml version="1.0" encoding="UTF-8"?>
+<quiz>
+  <question type="category">
+    <category><text>$course$/top/Physics</text></category>
+  </question>
+  <question type="multichoice">
+    <name><text>Speed</text></name>
+    <questiontext format="html"><text><![CDATA[<p>Speed of <b>light</b>?</p>]]></text></questiontext>
+    <generalfeedback><text>About 300&#160;000 km/s.</text></generalfeedback>
+    <single>true</single>
+    <answer fraction="100"><text>c</text></answer>
+    <answer fraction="0"><text>Mach 1</text></answer>
+    <tags><tag><text>constants</text></tag></tags>
+  </question>
+  <question type="multichoice">
+    <name><text>Vectors</text></name>
+    <questiontext><text>Which are vectors?</text></questiontext>
+    <single>false</single>
+    <answer fraction="50"><text>velocity</text></answer>
+    <answer fraction="50"><text>force</text></answer>
+    <answer fraction="-100"><text>mass</text></answer>
+  </question>
+  <question type="truefalse">
+    <name><text>Heavy</text></name>
+    <questiontext><text>Heavier objects fall faster.</text></questiontext>
+    <answer fraction="0"><text>true</text></answer>
+    <answer fraction="100"><text>false</text></answer>
+  </question>
+</quiz>`,
+			questions: []wantQuestion{
+				{
+					kind:        quiz.KindMultipleChoice,
+					prompt:      "Speed of light?",
+					options:     []string{"c", "Mach 1"},
+					correct:     []string{"a"},
+					explanation: "About 300\u00a0000 km/s.",
+					tags:        []string{"constants", "Physics"},
+				},
+				{
+					kind:    quiz.KindMultiSelect,
+					prompt:  "Which are vectors?",
+					options: []string{"velocity", "force", "mass"},
+					correct: []string{"a", "b"},
+					tags:    []string{"Physics"},
+				},
+				{
+					kind:    quiz.KindTrueFalse,
+					prompt:  "Heavier objects fall faster.",
+					options: []string{"True", "False"},
+					correct: []string{quiz.OptionFalse},
+					tags:    []string{"Physics"},
+				},
+			},
+		},
+		{
+			name: "partial credit does not make a single answer correct",
+			input: `<quiz><question type="multichoice">
+<questiontext><text>Best answer?</text></questiontext>
+<single>true</single>
+<answer fraction="50"><text>close</text></answer>
+<answer fraction="100"><text>exact</text></answer>
+</question></quiz>`,
+			questions: []wantQuestion{{
+				kind:    quiz.KindMultipleChoice,
+				prompt:  "Best answer?",
+				options: []string{"close", "exact"},
+				correct: []string{"b"},
+			}},
+		},
+		{
+			name: "errors carry the line each question starts on",
+			input: `<quiz>
+  <question type="essay">
+    <questiontext><text>Discuss.</text></questiontext>
+  </question>
+  <question type="multichoice">
+    <questiontext><text>Broken?</text></questiontext>
+    <answer fraction="lots"><text>a</text></answer>
+  </question>
+  <question type="multichoice">
+    <questiontext><text>Nothing right?</text></questiontext>
+    <answer fraction="0"><text>a</text></answer>
+    <answer fraction="0"><text>b</text></answer>
+  </question>
+</quiz>`,
+			errors: []wantError{
+				{line: 2, contains: "essay questions are not supported"},
+				{line: 5, contains: "invalid answer fraction"},
+				{line: 9, contains: "correct"},
+			},
+		},
+	})
+}
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		filename string
+		format   Format
+		ok       bool
+	}{
+		{"questions.gift", FormatGIFT, true},
+		{"export.XML", FormatMoodleXML, true},
+		{"quiz.txt", FormatAiken, true},
+		{"quiz.aiken", FormatAiken, true},
+		{"slides.pdf", "", false},
+	}
+
+	for _, tt := range tests {
+		format, ok := DetectFormat(tt.filename)
+		if format != tt.format || ok != tt.ok {
+			t.Errorf("DetectFormat(%q) = %q, %v; want %q, %v", tt.filename, format, ok, tt.format, tt.ok)
+		}
+	}
+}
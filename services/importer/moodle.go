@@ -0,0 +1,165 @@
+package importer
+
+import (
+	"encoding/xml"
+	"html"
+	"io"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"mnemo/services/quiz"
+	"mnemo/storage"
+)
+
+var htmlTag = regexp.MustCompile(`<[^>]*>`)
+
+type moodleText struct {
+	Text string `xml:"text"`
+}
+
+type moodleAnswer struct {
+	Fraction string `xml:"fraction,attr"`
+	Text     string `xml:"text"`
+}
+
+type moodleQuestion struct {
+	Type            string         `xml:"type,attr"`
+	Name            moodleText     `xml:"name"`
+	QuestionText    moodleText     `xml:"questiontext"`
+	GeneralFeedback moodleText     `xml:"generalfeedback"`
+	Category        moodleText     `xml:"category"`
+	Single          string         `xml:"single"`
+	Answers         []moodleAnswer `xml:"answer"`
+	Tags            []moodleText   `xml:"tags>tag"`
+}
+
+// ParseMoodleXML parses Moodle's XML export format. multichoice and truefalse
+// questions are imported; category entries become a tag on the questions
+// that follow. Other question types are reported as errors.
+func ParseMoodleXML(r io.Reader) (*Result, error) {
+	result := newResult()
+	decoder := xml.NewDecoder(r)
+
+	var category string
+
+	for {
+		line, _ := decoder.InputPos()
+
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid XML")
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "question" {
+			continue
+		}
+
+		var mq moodleQuestion
+		if err := decoder.DecodeElement(&mq, &start); err != nil {
+			return nil, errors.Wrapf(err, "invalid question on line %d", line)
+		}
+
+		switch mq.Type {
+		case "category":
+			category = path.Base(strings.TrimSpace(mq.Category.Text))
+		case "multichoice", "truefalse":
+			q, err := convertMoodleQuestion(&mq)
+			if err != nil {
+				result.addError(line, err.Error())
+				continue
+			}
+
+			if category != "" && category != "." && !contains(q.Tags, category) {
+				q.Tags = append(q.Tags, category)
+			}
+
+			result.addQuestion(line, q)
+		default:
+			result.addError(line, "%s questions are not supported", mq.Type)
+		}
+	}
+
+	return result, nil
+}
+
+func convertMoodleQuestion(mq *moodleQuestion) (*storage.BankQuestion, error) {
+	q := &storage.BankQuestion{
+		Question: quiz.Question{
+			Prompt:      moodlePlainText(mq.QuestionText.Text),
+			Explanation: moodlePlainText(mq.GeneralFeedback.Text),
+		},
+	}
+
+	if q.Prompt == "" {
+		q.Prompt = moodlePlainText(mq.Name.Text)
+	}
+
+	for _, tag := range mq.Tags {
+		if t := strings.TrimSpace(tag.Text); t != "" {
+			q.Tags = append(q.Tags, t)
+		}
+	}
+
+	if mq.Type == "truefalse" {
+		q.Kind = quiz.KindTrueFalse
+
+		for _, a := range mq.Answers {
+			fraction, err := strconv.ParseFloat(a.Fraction, 64)
+			if err != nil {
+				return nil, errors.Errorf("invalid answer fraction '%s'", a.Fraction)
+			}
+
+			if fraction > 0 {
+				q.Correct = []string{strings.ToLower(moodlePlainText(a.Text))}
+			}
+		}
+
+		return q, nil
+	}
+
+	q.Kind = quiz.KindMultipleChoice
+	if strings.TrimSpace(mq.Single) == "false" {
+		q.Kind = quiz.KindMultiSelect
+	}
+
+	for i, a := range mq.Answers {
+		fraction, err := strconv.ParseFloat(a.Fraction, 64)
+		if err != nil {
+			return nil, errors.Errorf("invalid answer fraction '%s'", a.Fraction)
+		}
+
+		id := optionID(i)
+		q.Options = append(q.Options, quiz.Option{ID: id, Text: moodlePlainText(a.Text)})
+
+		// single-answer questions only count the fully correct option;
+		// multi-select ones count every option with positive credit
+		if (q.Kind == quiz.KindMultipleChoice && fraction >= 100) || (q.Kind == quiz.KindMultiSelect && fraction > 0) {
+			q.Correct = append(q.Correct, id)
+		}
+	}
+
+	return q, nil
+}
+
+// moodlePlainText strips the HTML Moodle wraps most text fields in.
+func moodlePlainText(s string) string {
+	return strings.TrimSpace(html.UnescapeString(htmlTag.ReplaceAllString(s, "")))
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// hubCollector reads the hub's state at scrape time instead of tracking every
+// client coming and going.
+type hubCollector struct {
+	source Source
+
+	clients    *prometheus.Desc
+	queueDepth *prometheus.Desc
+	queued     *prometheus.Desc
+	dropped    *prometheus.Desc
+	evicted    *prometheus.Desc
+}
+
+func newHubCollector(source Source) *hubCollector {
+	return &hubCollector{
+		source: source,
+
+		clients: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "ws", "clients"),
+			"Clients connected to this instance, by room and role.",
+			[]string{"room", "role"}, nil,
+		),
+		queueDepth: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "ws", "egress_queue_depth"),
+			"Events waiting in the egress queues of a room's clients, by room and role.",
+			[]string{"room", "role"}, nil,
+		),
+		queued: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "ws", "egress_queued_total"),
+			"Outgoing events queued for clients.",
+			nil, nil,
+		),
+		dropped: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "ws", "egress_dropped_total"),
+			"Outgoing events dropped because a client's queue was full.",
+			nil, nil,
+		),
+		evicted: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "ws", "egress_evicted_total"),
+			"Clients disconnected for falling behind.",
+			nil, nil,
+		),
+	}
+}
+
+func (c *hubCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.clients
+	ch <- c.queueDepth
+	ch <- c.queued
+	ch <- c.dropped
+	ch <- c.evicted
+}
+
+func (c *hubCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, stats := range c.source.ClientStats() {
+		ch <- prometheus.MustNewConstMetric(c.clients, prometheus.GaugeValue, float64(stats.Clients), stats.Room, stats.Role)
+		ch <- prometheus.MustNewConstMetric(c.queueDepth, prometheus.GaugeValue, float64(stats.Queued), stats.Room, stats.Role)
+	}
+
+	egress := c.source.EgressStats()
+	ch <- prometheus.MustNewConstMetric(c.queued, prometheus.CounterValue, float64(egress.Queued))
+	ch <- prometheus.MustNewConstMetric(c.dropped, prometheus.CounterValue, float64(egress.Dropped))
+	ch <- prometheus.MustNewConstMetric(c.evicted, prometheus.CounterValue, float64(egress.Evicted))
+}
@@ -0,0 +1,172 @@
+// Package metrics exports Prometheus metrics about the websocket hub and the
+// HTTP API.
+package metrics
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"mnemo/services/ws"
+)
+
+const namespace = "mnemo"
+
+// Source is what the hub collector reads at scrape time; it is satisfied by
+// *ws.Manager.
+type Source interface {
+	ClientStats() []ws.ClientStats
+	EgressStats() ws.EgressStats
+}
+
+// Metrics owns the registry served on /metrics. It implements ws.Observer.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	eventsRouted  *prometheus.CounterVec
+	eventErrors   *prometheus.CounterVec
+	answerLatency *prometheus.HistogramVec
+
+	httpRequests *prometheus.CounterVec
+	httpDuration *prometheus.HistogramVec
+}
+
+func New(source Source) *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+
+		eventsRouted: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "ws",
+			Name:      "events_routed_total",
+			Help:      "Inbound websocket events handed to their handler, by type.",
+		}, []string{"type"}),
+
+		eventErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "ws",
+			Name:      "event_errors_total",
+			Help:      "Inbound websocket events rejected with an error event, by type and error code.",
+		}, []string{"type", "code"}),
+
+		answerLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "answer_latency_seconds",
+			Help:      "Time from a question being shown to the student's answer, by mode (live or review).",
+			Buckets:   []float64{0.5, 1, 2, 3, 5, 8, 13, 20, 30, 45, 60, 120},
+		}, []string{"mode", "correct"}),
+
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "requests_total",
+			Help:      "HTTP requests by method, route and status code.",
+		}, []string{"method", "route", "code"}),
+
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "request_duration_seconds",
+			Help:      "HTTP request latency by method and route. Websocket routes measure the handshake, event streams the whole connection.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		newHubCollector(source),
+		m.eventsRouted,
+		m.eventErrors,
+		m.answerLatency,
+		m.httpRequests,
+		m.httpDuration,
+	)
+
+	return m
+}
+
+// Handler serves the registry in the Prometheus exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+func (m *Metrics) EventRouted(eventType string) {
+	m.eventsRouted.WithLabelValues(eventType).Inc()
+}
+
+func (m *Metrics) EventFailed(eventType, code string) {
+	m.eventErrors.WithLabelValues(eventType, code).Inc()
+}
+
+func (m *Metrics) AnswerSubmitted(mode string, correct bool, responseTime time.Duration) {
+	m.answerLatency.WithLabelValues(mode, strconv.FormatBool(correct)).Observe(responseTime.Seconds())
+}
+
+// Instrument wraps the handler of route, a router path pattern such as
+// /api/v1/rooms/:id, with the HTTP request metrics. The pattern rather than
+// the request path is used as label, so IDs don't blow up cardinality.
+func (m *Metrics) Instrument(route string, next http.HandlerFunc) http.HandlerFunc {
+	return func(wr http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: wr, status: http.StatusOK}
+
+		next(rec, r)
+
+		m.httpRequests.WithLabelValues(r.Method, route, strconv.Itoa(rec.status)).Inc()
+		m.httpDuration.WithLabelValues(r.Method, route).Observe(time.Since(start).Seconds())
+	}
+}
+
+// statusRecorder captures the response status. Websocket upgrades and event
+// streams need the underlying writer, which it exposes through Hijack and
+// Unwrap.
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	if !s.wroteHeader {
+		s.status = status
+		s.wroteHeader = true
+	}
+
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	s.wroteHeader = true
+	return s.ResponseWriter.Write(b)
+}
+
+func (s *statusRecorder) Flush() {
+	if f, ok := s.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (s *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := s.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer does not support hijacking")
+	}
+
+	// the handshake response is written on the hijacked connection
+	s.status = http.StatusSwitchingProtocols
+	s.wroteHeader = true
+
+	return h.Hijack()
+}
+
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
@@ -0,0 +1,176 @@
+package metrics
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"mnemo/services/ws"
+)
+
+// testSource reports fixed hub statistics.
+type testSource struct {
+	clients []ws.ClientStats
+	egress  ws.EgressStats
+}
+
+func (s *testSource) ClientStats() []ws.ClientStats {
+	return s.clients
+}
+
+func (s *testSource) EgressStats() ws.EgressStats {
+	return s.egress
+}
+
+// scrape returns the exposition lines of the registry, without comments.
+func scrape(t *testing.T, m *Metrics) map[string]bool {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /metrics = %d", rec.Code)
+	}
+
+	body, err := io.ReadAll(rec.Body)
+	if err != nil {
+		t.Fatalf("reading metrics: %v", err)
+	}
+
+	lines := map[string]bool{}
+	for _, line := range strings.Split(string(body), "\n") {
+		if line != "" && !strings.HasPrefix(line, "#") {
+			lines[line] = true
+		}
+	}
+
+	return lines
+}
+
+func TestHandler(t *testing.T) {
+	source := &testSource{
+		clients: []ws.ClientStats{
+			{Room: "ABC123", Role: "student", Clients: 3, Queued: 7},
+			{Room: "ABC123", Role: "professor", Clients: 1},
+		},
+		egress: ws.EgressStats{Queued: 42, Dropped: 2, Evicted: 1},
+	}
+
+	m := New(source)
+
+	m.EventRouted("join_room")
+	m.EventRouted("join_room")
+	m.EventRouted("submit_answer")
+	m.EventFailed("submit_answer", "already_answered")
+	m.AnswerSubmitted("live", true, 1500*time.Millisecond)
+	m.AnswerSubmitted("review", false, 90*time.Second)
+
+	lines := scrape(t, m)
+
+	tests := []struct {
+		name string
+		line string
+	}{
+		{"student clients", `mnemo_ws_clients{role="student",room="ABC123"} 3`},
+		{"professor clients", `mnemo_ws_clients{role="professor",room="ABC123"} 1`},
+		{"student queue depth", `mnemo_ws_egress_queue_depth{role="student",room="ABC123"} 7`},
+		{"professor queue depth", `mnemo_ws_egress_queue_depth{role="professor",room="ABC123"} 0`},
+		{"queued events", `mnemo_ws_egress_queued_total 42`},
+		{"dropped events", `mnemo_ws_egress_dropped_total 2`},
+		{"evicted clients", `mnemo_ws_egress_evicted_total 1`},
+		{"routed events", `mnemo_ws_events_routed_total{type="join_room"} 2`},
+		{"routed events of another type", `mnemo_ws_events_routed_total{type="submit_answer"} 1`},
+		{"event errors", `mnemo_ws_event_errors_total{code="already_answered",type="submit_answer"} 1`},
+		{"live answer in its bucket", `mnemo_answer_latency_seconds_bucket{correct="true",mode="live",le="2"} 1`},
+		{"live answer below its bucket", `mnemo_answer_latency_seconds_bucket{correct="true",mode="live",le="1"} 0`},
+		{"review answer past the last bucket", `mnemo_answer_latency_seconds_bucket{correct="false",mode="review",le="60"} 0`},
+		{"review answer count", `mnemo_answer_latency_seconds_count{correct="false",mode="review"} 1`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !lines[tt.line] {
+				t.Errorf("metrics lack %s", tt.line)
+			}
+		})
+	}
+
+	// rooms that closed since the last scrape disappear with their stats
+	source.clients = nil
+
+	for line := range scrape(t, m) {
+		if strings.HasPrefix(line, "mnemo_ws_clients{") {
+			t.Errorf("closed room still reported: %s", line)
+		}
+	}
+}
+
+func TestInstrument(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		handler  http.HandlerFunc
+		wantLine string
+	}{
+		{
+			name:     "implicit OK",
+			method:   http.MethodGet,
+			handler:  func(wr http.ResponseWriter, r *http.Request) { io.WriteString(wr, "ok") },
+			wantLine: `mnemo_http_requests_total{code="200",method="GET",route="/api/v1/rooms/:id"} 1`,
+		},
+		{
+			name:     "explicit status",
+			method:   http.MethodGet,
+			handler:  func(wr http.ResponseWriter, r *http.Request) { wr.WriteHeader(http.StatusNotFound) },
+			wantLine: `mnemo_http_requests_total{code="404",method="GET",route="/api/v1/rooms/:id"} 1`,
+		},
+		{
+			name:   "first status wins",
+			method: http.MethodDelete,
+			handler: func(wr http.ResponseWriter, r *http.Request) {
+				wr.WriteHeader(http.StatusNoContent)
+				wr.WriteHeader(http.StatusInternalServerError)
+			},
+			wantLine: `mnemo_http_requests_total{code="204",method="DELETE",route="/api/v1/rooms/:id"} 1`,
+		},
+		{
+			name:   "status after the body is ignored",
+			method: http.MethodGet,
+			handler: func(wr http.ResponseWriter, r *http.Request) {
+				io.WriteString(wr, "ok")
+				wr.WriteHeader(http.StatusBadGateway)
+			},
+			wantLine: `mnemo_http_requests_total{code="200",method="GET",route="/api/v1/rooms/:id"} 1`,
+		},
+		{
+			name:   "event streams can flush",
+			method: http.MethodGet,
+			handler: func(wr http.ResponseWriter, r *http.Request) {
+				if err := http.NewResponseController(wr).Flush(); err != nil {
+					wr.WriteHeader(http.StatusInternalServerError)
+				}
+			},
+			wantLine: `mnemo_http_requests_total{code="200",method="GET",route="/api/v1/rooms/:id"} 1`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := New(&testSource{})
+
+			handler := m.Instrument("/api/v1/rooms/:id", tt.handler)
+			handler(httptest.NewRecorder(), httptest.NewRequest(tt.method, "/api/v1/rooms/ABC123", nil))
+
+			lines := scrape(t, m)
+			if !lines[tt.wantLine] {
+				t.Errorf("metrics lack %s", tt.wantLine)
+			}
+			if !lines[`mnemo_http_request_duration_seconds_count{method="`+tt.method+`",route="/api/v1/rooms/:id"} 1`] {
+				t.Error("request duration not observed")
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package poll
+
+import (
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type Kind string
+
+const (
+	KindSingleChoice   Kind = "single_choice"
+	KindMultipleChoice Kind = "multiple_choice"
+	KindWordCloud      Kind = "word_cloud"
+)
+
+// MaxResponseWords is how many words of a word cloud response are counted.
+const MaxResponseWords = 10
+
+var (
+	ErrInvalidPoll     = errors.New("invalid poll")
+	ErrInvalidResponse = errors.New("invalid response")
+	ErrClosed          = errors.New("poll is closed")
+)
+
+type Option struct {
+	ID   string `json:"id"`
+	Text string `json:"text"`
+}
+
+// Poll is an ungraded question: there is no correct answer, only the spread
+// of what the room thinks.
+type Poll struct {
+	ID      string   `json:"id"`
+	Kind    Kind     `json:"kind"`
+	Prompt  string   `json:"prompt"`
+	Options []Option `json:"options,omitempty"` // empty for word clouds
+}
+
+// Validate checks that the poll is well-formed.
+func (p *Poll) Validate() error {
+	if strings.TrimSpace(p.Prompt) == "" {
+		return errors.Wrap(ErrInvalidPoll, "prompt cannot be empty")
+	}
+
+	switch p.Kind {
+	case KindSingleChoice, KindMultipleChoice:
+		if len(p.Options) < 2 {
+			return errors.Wrap(ErrInvalidPoll, "at least two options are required")
+		}
+	case KindWordCloud:
+		if len(p.Options) > 0 {
+			return errors.Wrap(ErrInvalidPoll, "word clouds have no options")
+		}
+	default:
+		return errors.Wrapf(ErrInvalidPoll, "unknown poll kind '%s'", p.Kind)
+	}
+
+	ids := make(map[string]bool, len(p.Options))
+	for _, o := range p.Options {
+		if o.ID == "" {
+			return errors.Wrap(ErrInvalidPoll, "option id cannot be empty")
+		}
+		if ids[o.ID] {
+			return errors.Wrapf(ErrInvalidPoll, "duplicate option id '%s'", o.ID)
+		}
+		ids[o.ID] = true
+	}
+
+	return nil
+}
+
+// Response is what one participant answered to a poll. Choice polls fill
+// Choices, word clouds Text.
+type Response struct {
+	ParticipantID string    `json:"participant_id"`
+	PollID        string    `json:"poll_id"`
+	Choices       []string  `json:"choices,omitempty"`
+	Text          string    `json:"text,omitempty"`
+	SubmittedAt   time.Time `json:"submitted_at"`
+}
+
+// Check validates the response against the poll and drops duplicate
+// choices.
+func (p *Poll) Check(r *Response) error {
+	if p.Kind == KindWordCloud {
+		if len(r.Choices) > 0 {
+			return errors.Wrap(ErrInvalidResponse, "word clouds take text, not choices")
+		}
+		if len(Words(r.Text)) == 0 {
+			return errors.Wrap(ErrInvalidResponse, "no words to count")
+		}
+		return nil
+	}
+
+	if r.Text != "" {
+		return errors.Wrap(ErrInvalidResponse, "choice polls take choices, not text")
+	}
+
+	if len(r.Choices) == 0 {
+		return errors.Wrap(ErrInvalidResponse, "no option chosen")
+	}
+
+	seen := make(map[string]bool, len(r.Choices))
+	choices := r.Choices[:0]
+	for _, id := range r.Choices {
+		if !p.hasOption(id) {
+			return errors.Wrapf(ErrInvalidResponse, "unknown option '%s'", id)
+		}
+		if !seen[id] {
+			seen[id] = true
+			choices = append(choices, id)
+		}
+	}
+	r.Choices = choices
+
+	if p.Kind == KindSingleChoice && len(r.Choices) != 1 {
+		return errors.Wrap(ErrInvalidResponse, "exactly one option must be chosen")
+	}
+
+	return nil
+}
+
+func (p *Poll) hasOption(id string) bool {
+	for _, o := range p.Options {
+		if o.ID == id {
+			return true
+		}
+	}
+
+	return false
+}
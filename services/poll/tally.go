@@ -0,0 +1,217 @@
+package poll
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Results aggregates the responses to one poll. Choice polls fill Counts and
+// Percentages, word clouds Terms.
+type Results struct {
+	PollID    string `json:"poll_id"`
+	Kind      Kind   `json:"kind"`
+	Responses int    `json:"responses"`
+
+	Counts map[string]int `json:"counts,omitempty"` // option ID -> times chosen
+
+	// Percentages are the share of respondents that chose each option, to
+	// one decimal. They add up to more than 100 for multiple choice polls.
+	Percentages map[string]float64 `json:"percentages,omitempty"`
+
+	// Terms are the most frequent words, most frequent first
+	Terms []Term `json:"terms,omitempty"`
+}
+
+// Term is one word of a word cloud. Every form of the word counts towards
+// it; Text is the form used most.
+type Term struct {
+	Text  string `json:"text"`
+	Count int    `json:"count"`
+}
+
+// Tally is one poll being run in a room, together with the responses it has
+// collected so far. A participant's later response replaces the earlier one.
+type Tally struct {
+	Poll      *Poll
+	StartedAt time.Time
+
+	responses map[string]*Response // keyed by participant ID
+	closed    bool
+	closedAt  time.Time
+	sync      sync.RWMutex
+}
+
+func NewTally(p *Poll, startedAt time.Time) *Tally {
+	return &Tally{
+		Poll:      p,
+		StartedAt: startedAt,
+		responses: make(map[string]*Response),
+	}
+}
+
+// Submit records the response, replacing the participant's earlier one.
+func (t *Tally) Submit(r *Response) error {
+	if err := t.Poll.Check(r); err != nil {
+		return err
+	}
+
+	t.sync.Lock()
+	defer t.sync.Unlock()
+
+	if t.closed {
+		return ErrClosed
+	}
+
+	t.responses[r.ParticipantID] = r
+
+	return nil
+}
+
+// Restore records a response loaded from storage without checking it.
+func (t *Tally) Restore(r *Response) {
+	t.sync.Lock()
+	defer t.sync.Unlock()
+
+	t.responses[r.ParticipantID] = r
+}
+
+// Response returns the response a participant gave, if any.
+func (t *Tally) Response(participantID string) (*Response, bool) {
+	t.sync.RLock()
+	defer t.sync.RUnlock()
+
+	r, ok := t.responses[participantID]
+	return r, ok
+}
+
+// Close stops accepting responses. It reports false if the poll was
+// already closed.
+func (t *Tally) Close() bool {
+	return t.CloseAt(time.Now())
+}
+
+func (t *Tally) CloseAt(at time.Time) bool {
+	t.sync.Lock()
+	defer t.sync.Unlock()
+
+	if t.closed {
+		return false
+	}
+
+	t.closed = true
+	t.closedAt = at
+
+	return true
+}
+
+func (t *Tally) Closed() bool {
+	t.sync.RLock()
+	defer t.sync.RUnlock()
+
+	return t.closed
+}
+
+// ClosedAt is zero while the poll is open.
+func (t *Tally) ClosedAt() time.Time {
+	t.sync.RLock()
+	defer t.sync.RUnlock()
+
+	return t.closedAt
+}
+
+// Results counts the responses. Word clouds report at most maxTerms terms;
+// maxTerms <= 0 means all of them.
+func (t *Tally) Results(maxTerms int) Results {
+	t.sync.RLock()
+	defer t.sync.RUnlock()
+
+	results := Results{
+		PollID:    t.Poll.ID,
+		Kind:      t.Poll.Kind,
+		Responses: len(t.responses),
+	}
+
+	if t.Poll.Kind == KindWordCloud {
+		results.Terms = t.terms(maxTerms)
+		return results
+	}
+
+	results.Counts = make(map[string]int, len(t.Poll.Options))
+	results.Percentages = make(map[string]float64, len(t.Poll.Options))
+
+	for _, o := range t.Poll.Options {
+		results.Counts[o.ID] = 0
+	}
+
+	for _, r := range t.responses {
+		for _, id := range r.Choices {
+			results.Counts[id]++
+		}
+	}
+
+	for id, count := range results.Counts {
+		results.Percentages[id] = 0
+		if results.Responses > 0 {
+			results.Percentages[id] = math.Round(float64(count)*1000/float64(results.Responses)) / 10
+		}
+	}
+
+	return results
+}
+
+// terms counts every stem once per response, so repeating a word does not
+// make it bigger. The caller must hold the lock.
+func (t *Tally) terms(maxTerms int) []Term {
+	counts := make(map[string]int)
+	forms := make(map[string]map[string]int) // stem -> form -> uses
+
+	for _, r := range t.responses {
+		seen := make(map[string]bool)
+
+		for _, w := range Words(r.Text) {
+			stem := Stem(w)
+
+			if forms[stem] == nil {
+				forms[stem] = make(map[string]int)
+			}
+			forms[stem][w]++
+
+			if !seen[stem] {
+				seen[stem] = true
+				counts[stem]++
+			}
+		}
+	}
+
+	terms := make([]Term, 0, len(counts))
+	for stem, count := range counts {
+		terms = append(terms, Term{Text: mostUsed(forms[stem]), Count: count})
+	}
+
+	sort.Slice(terms, func(i, j int) bool {
+		if terms[i].Count != terms[j].Count {
+			return terms[i].Count > terms[j].Count
+		}
+		return terms[i].Text < terms[j].Text
+	})
+
+	if maxTerms > 0 && len(terms) > maxTerms {
+		terms = terms[:maxTerms]
+	}
+
+	return terms
+}
+
+// mostUsed picks the form used most, the alphabetically first among equals.
+func mostUsed(forms map[string]int) string {
+	var best string
+	for form, uses := range forms {
+		if best == "" || uses > forms[best] || (uses == forms[best] && form < best) {
+			best = form
+		}
+	}
+
+	return best
+}
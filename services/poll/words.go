@@ -0,0 +1,103 @@
+package poll
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// stopwords carry no meaning of their own in a word cloud. Apostrophes are
+// dropped before the lookup, so contractions are listed without them.
+var stopwords = toSet(`a about above after again against all am an and any are as at be because been
+before being below between both but by can cannot could did do does doing dont down during each few
+for from further had has have having he her here hers herself him himself his how i if im in into is
+isnt it its itself ive just lets me more most my myself no nor not of off on once only or other our
+ours ourselves out over own same she should so some such than that thats the their theirs them
+themselves then there these they this those through to too under until up very was we were what
+when where which while who whom why will with would you your yours yourself yourselves`)
+
+func toSet(words string) map[string]bool {
+	set := make(map[string]bool)
+	for _, w := range strings.Fields(words) {
+		set[w] = true
+	}
+
+	return set
+}
+
+// Words splits a word cloud response into the words worth counting:
+// lowercased, stripped of punctuation and stopwords, at most
+// MaxResponseWords of them.
+func Words(text string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r) && r != '\'' && r != '’'
+	})
+
+	words := make([]string, 0, len(fields))
+	for _, w := range fields {
+		w = strings.TrimSuffix(strings.ReplaceAll(w, "’", "'"), "'s")
+		w = strings.ReplaceAll(w, "'", "")
+
+		if utf8.RuneCountInString(w) < 2 || stopwords[w] {
+			continue
+		}
+
+		words = append(words, w)
+		if len(words) == MaxResponseWords {
+			break
+		}
+	}
+
+	return words
+}
+
+// Stem reduces an English word to a stem by stripping common inflections,
+// so "caching", "cached" and "caches" count as one term. It is deliberately
+// light: words it does not recognize are returned unchanged.
+func Stem(word string) string {
+	if utf8.RuneCountInString(word) <= 3 {
+		return word
+	}
+
+	switch {
+	case strings.HasSuffix(word, "sses"):
+		word = strings.TrimSuffix(word, "es")
+	case strings.HasSuffix(word, "ies"):
+		word = strings.TrimSuffix(word, "ies") + "y"
+	case strings.HasSuffix(word, "ss"), strings.HasSuffix(word, "us"), strings.HasSuffix(word, "is"):
+	case strings.HasSuffix(word, "s"):
+		word = strings.TrimSuffix(word, "s")
+	}
+
+	for _, suffix := range []string{"ing", "ed"} {
+		if suffix == "ed" && strings.HasSuffix(word, "eed") {
+			break
+		}
+
+		stem, ok := strings.CutSuffix(word, suffix)
+		if ok && utf8.RuneCountInString(stem) >= 3 && hasVowel(stem) {
+			word = undouble(stem)
+			break
+		}
+	}
+
+	if utf8.RuneCountInString(word) >= 4 && strings.HasSuffix(word, "e") && !strings.HasSuffix(word, "ee") {
+		word = strings.TrimSuffix(word, "e")
+	}
+
+	return word
+}
+
+func hasVowel(word string) bool {
+	return strings.ContainsAny(word, "aeiouy")
+}
+
+// undouble turns "runn" (from "running") back into "run".
+func undouble(stem string) string {
+	n := len(stem)
+	if n < 2 || stem[n-1] != stem[n-2] || strings.IndexByte("bdfgmnprt", stem[n-1]) < 0 {
+		return stem
+	}
+
+	return stem[:n-1]
+}
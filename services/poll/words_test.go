@@ -0,0 +1,90 @@
+package poll
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWords(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []string
+	}{
+		{"lowercases", "Cache CACHE", []string{"cache", "cache"}},
+		{"strips punctuation", "graph-theory/trees, (heaps)!", []string{"graph", "theory", "trees", "heaps"}},
+		{"drops stopwords", "the cache and the queue", []string{"cache", "queue"}},
+		{"drops contracted stopwords", "don't stop, it's fine", []string{"stop", "fine"}},
+		{"drops possessives", "the compiler's output", []string{"compiler", "output"}},
+		{"handles typographic apostrophes", "Go’s GC isn’t slow", []string{"go", "gc", "slow"}},
+		{"drops single characters", "x y z 2", []string{}},
+		{"keeps numbers in words", "http 2 http2", []string{"http", "http2"}},
+		{"keeps non ascii letters", "Café naïve", []string{"café", "naïve"}},
+		{"only stopwords", "it is what it is", []string{}},
+		{"empty", "  ", []string{}},
+		{
+			"limits the number of words",
+			strings.Repeat("word ", MaxResponseWords+5),
+			strings.Fields(strings.Repeat("word ", MaxResponseWords)),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Words(tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Words(%q) = %q, want %q", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStem(t *testing.T) {
+	tests := []struct {
+		word string
+		want string
+	}{
+		// inflections of one word share a stem
+		{"cache", "cach"},
+		{"caches", "cach"},
+		{"cached", "cach"},
+		{"caching", "cach"},
+		{"make", "mak"},
+		{"making", "mak"},
+
+		// plurals
+		{"lists", "list"},
+		{"queries", "query"},
+		{"classes", "class"},
+		{"trees", "tree"},
+
+		// words that only look like plurals
+		{"glass", "glass"},
+		{"status", "status"},
+		{"analysis", "analysis"},
+
+		// doubled consonants
+		{"running", "run"},
+		{"stopped", "stop"},
+		{"programming", "program"},
+		{"falling", "fall"},
+
+		// stems too short or without a vowel are kept
+		{"sing", "sing"},
+		{"bring", "bring"},
+		{"string", "string"},
+		{"agreed", "agreed"},
+		{"free", "free"},
+
+		// short words are returned unchanged
+		{"bed", "bed"},
+		{"use", "use"},
+		{"is", "is"},
+	}
+
+	for _, tt := range tests {
+		if got := Stem(tt.word); got != tt.want {
+			t.Errorf("Stem(%q) = %q, want %q", tt.word, got, tt.want)
+		}
+	}
+}
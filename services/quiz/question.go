@@ -0,0 +1,147 @@
+package quiz
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type Kind string
+
+const (
+	KindMultipleChoice Kind = "multiple_choice"
+	KindTrueFalse      Kind = "true_false"
+	KindMultiSelect    Kind = "multi_select"
+
+	OptionTrue  = "true"
+	OptionFalse = "false"
+)
+
+// MaxDuration is the longest time limit a question can have.
+const MaxDuration = time.Hour
+
+var (
+	ErrInvalidQuestion = errors.New("invalid question")
+	ErrInvalidAnswer   = errors.New("invalid answer")
+)
+
+type Option struct {
+	ID   string `json:"id"`
+	Text string `json:"text"`
+}
+
+type Question struct {
+	ID      string   `json:"id"`
+	Kind    Kind     `json:"kind"`
+	Prompt  string   `json:"prompt"`
+	Options []Option `json:"options"`
+
+	// Correct holds the IDs of the correct options. Like Explanation it is
+	// never sent to students before the question closes; see Public.
+	Correct     []string `json:"correct,omitempty"`
+	Explanation string   `json:"explanation,omitempty"`
+}
+
+// Validate checks that the question is well-formed. True/false questions
+// without options get the canonical "true"/"false" options filled in.
+func (q *Question) Validate() error {
+	if strings.TrimSpace(q.Prompt) == "" {
+		return errors.Wrap(ErrInvalidQuestion, "prompt cannot be empty")
+	}
+
+	switch q.Kind {
+	case KindTrueFalse:
+		if len(q.Options) == 0 {
+			q.Options = []Option{{ID: OptionTrue, Text: "True"}, {ID: OptionFalse, Text: "False"}}
+		}
+		if len(q.Options) != 2 {
+			return errors.Wrap(ErrInvalidQuestion, "true/false questions have exactly two options")
+		}
+	case KindMultipleChoice, KindMultiSelect:
+		if len(q.Options) < 2 {
+			return errors.Wrap(ErrInvalidQuestion, "at least two options are required")
+		}
+	default:
+		return errors.Wrapf(ErrInvalidQuestion, "unknown question kind '%s'", q.Kind)
+	}
+
+	ids := make(map[string]bool, len(q.Options))
+	for _, o := range q.Options {
+		if o.ID == "" {
+			return errors.Wrap(ErrInvalidQuestion, "option id cannot be empty")
+		}
+		if ids[o.ID] {
+			return errors.Wrapf(ErrInvalidQuestion, "duplicate option id '%s'", o.ID)
+		}
+		ids[o.ID] = true
+	}
+
+	if len(q.Correct) == 0 {
+		return errors.Wrap(ErrInvalidQuestion, "correct answer is required")
+	}
+
+	if q.Kind != KindMultiSelect && len(q.Correct) != 1 {
+		return errors.Wrapf(ErrInvalidQuestion, "%s questions have exactly one correct option", q.Kind)
+	}
+
+	for _, id := range q.Correct {
+		if !ids[id] {
+			return errors.Wrapf(ErrInvalidQuestion, "correct option '%s' does not exist", id)
+		}
+	}
+
+	return nil
+}
+
+// Public returns a copy of the question that is safe to send to students.
+func (q *Question) Public() *Question {
+	public := *q
+	public.Options = append([]Option(nil), q.Options...)
+	public.Correct = nil
+	public.Explanation = ""
+
+	return &public
+}
+
+// Grade reports whether the chosen option IDs are exactly the correct set.
+func (q *Question) Grade(choices []string) (bool, error) {
+	if len(choices) == 0 {
+		return false, errors.Wrap(ErrInvalidAnswer, "no option chosen")
+	}
+
+	if q.Kind != KindMultiSelect && len(choices) != 1 {
+		return false, errors.Wrap(ErrInvalidAnswer, "exactly one option must be chosen")
+	}
+
+	chosen := make(map[string]bool, len(choices))
+	for _, id := range choices {
+		if !q.hasOption(id) {
+			return false, errors.Wrap(ErrInvalidAnswer, fmt.Sprintf("unknown option '%s'", id))
+		}
+		chosen[id] = true
+	}
+
+	if len(chosen) != len(q.Correct) {
+		return false, nil
+	}
+
+	for _, id := range q.Correct {
+		if !chosen[id] {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+func (q *Question) hasOption(id string) bool {
+	for _, o := range q.Options {
+		if o.ID == id {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,213 @@
+package quiz
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrAlreadyAnswered = errors.New("question already answered")
+	ErrClosed          = errors.New("question is closed")
+)
+
+type Answer struct {
+	ParticipantID string        `json:"participant_id"`
+	QuestionID    string        `json:"question_id"`
+	Choices       []string      `json:"choices"`
+	Correct       bool          `json:"correct"`
+	SubmittedAt   time.Time     `json:"submitted_at"`
+	ResponseTime  time.Duration `json:"response_time_ms"`
+
+	// Points is awarded when the question closes
+	Points int `json:"points"`
+}
+
+// MarshalJSON reports the response time in milliseconds rather than the
+// nanoseconds time.Duration would produce.
+func (a *Answer) MarshalJSON() ([]byte, error) {
+	type answer Answer
+
+	return json.Marshal(struct {
+		*answer
+		ResponseTime int64 `json:"response_time_ms"`
+	}{
+		answer:       (*answer)(a),
+		ResponseTime: a.ResponseTime.Milliseconds(),
+	})
+}
+
+// Results aggregates all answers given to one question.
+type Results struct {
+	QuestionID string         `json:"question_id"`
+	Answers    int            `json:"answers"`
+	Correct    int            `json:"correct"`
+	Counts     map[string]int `json:"counts"` // option ID -> times chosen
+}
+
+// Round is one question being asked in a room, together with the answers it
+// has collected so far.
+type Round struct {
+	Question    *Question
+	PublishedAt time.Time
+	Deadline    time.Time // zero for questions without a time limit
+
+	answers  map[string]*Answer // keyed by participant ID
+	closed   bool
+	closedAt time.Time
+	sync     sync.RWMutex
+}
+
+// NewRound starts asking q at publishedAt. A positive duration makes the
+// round stop accepting answers once it has elapsed.
+func NewRound(q *Question, publishedAt time.Time, duration time.Duration) *Round {
+	r := &Round{
+		Question:    q,
+		PublishedAt: publishedAt,
+		answers:     make(map[string]*Answer),
+	}
+
+	if duration > 0 {
+		r.Deadline = publishedAt.Add(duration)
+	}
+
+	return r
+}
+
+func (r *Round) Timed() bool {
+	return !r.Deadline.IsZero()
+}
+
+// Remaining returns how long the round keeps accepting answers.
+func (r *Round) Remaining(now time.Time) time.Duration {
+	if !r.Timed() {
+		return 0
+	}
+
+	return max(r.Deadline.Sub(now), 0)
+}
+
+// Submit grades and records a participant's answer. Each participant can only
+// answer once.
+func (r *Round) Submit(participantID string, choices []string, at time.Time) (*Answer, error) {
+	r.sync.Lock()
+	defer r.sync.Unlock()
+
+	// answers are judged against the server clock, never the client's
+	if r.closed || (r.Timed() && at.After(r.Deadline)) {
+		return nil, ErrClosed
+	}
+
+	if _, ok := r.answers[participantID]; ok {
+		return nil, ErrAlreadyAnswered
+	}
+
+	correct, err := r.Question.Grade(choices)
+	if err != nil {
+		return nil, err
+	}
+
+	answer := &Answer{
+		ParticipantID: participantID,
+		QuestionID:    r.Question.ID,
+		Choices:       choices,
+		Correct:       correct,
+		SubmittedAt:   at,
+		ResponseTime:  at.Sub(r.PublishedAt),
+	}
+
+	r.answers[participantID] = answer
+
+	return answer, nil
+}
+
+// Restore records an answer that was already graded, e.g. one loaded from
+// storage after a restart.
+func (r *Round) Restore(answer *Answer) {
+	r.sync.Lock()
+	defer r.sync.Unlock()
+
+	r.answers[answer.ParticipantID] = answer
+}
+
+// Close stops the round from accepting answers. It reports whether this call
+// closed the round, so concurrent closers can tell who won.
+func (r *Round) Close() bool {
+	return r.CloseAt(time.Now())
+}
+
+// CloseAt is Close with an explicit closing time.
+func (r *Round) CloseAt(at time.Time) bool {
+	r.sync.Lock()
+	defer r.sync.Unlock()
+
+	if r.closed {
+		return false
+	}
+
+	r.closed = true
+	r.closedAt = at
+	return true
+}
+
+// ClosedAt returns when the round was closed, or the zero time if it is
+// still open.
+func (r *Round) ClosedAt() time.Time {
+	r.sync.RLock()
+	defer r.sync.RUnlock()
+
+	return r.closedAt
+}
+
+func (r *Round) Closed() bool {
+	r.sync.RLock()
+	defer r.sync.RUnlock()
+
+	return r.closed
+}
+
+// Duration is how long the round was open for answers, or 0 if untimed.
+func (r *Round) Duration() time.Duration {
+	if !r.Timed() {
+		return 0
+	}
+
+	return r.Deadline.Sub(r.PublishedAt)
+}
+
+// Answer returns the answer a participant gave, if any.
+func (r *Round) Answer(participantID string) (*Answer, bool) {
+	r.sync.RLock()
+	defer r.sync.RUnlock()
+
+	answer, ok := r.answers[participantID]
+	return answer, ok
+}
+
+func (r *Round) Results() Results {
+	r.sync.RLock()
+	defer r.sync.RUnlock()
+
+	results := Results{
+		QuestionID: r.Question.ID,
+		Answers:    len(r.answers),
+		Counts:     make(map[string]int, len(r.Question.Options)),
+	}
+
+	for _, o := range r.Question.Options {
+		results.Counts[o.ID] = 0
+	}
+
+	for _, a := range r.answers {
+		if a.Correct {
+			results.Correct++
+		}
+		for _, id := range a.Choices {
+			results.Counts[id]++
+		}
+	}
+
+	return results
+}
@@ -0,0 +1,60 @@
+package quiz
+
+import (
+	"math"
+	"time"
+)
+
+// Scoring turns graded answers into points. A correct answer earns
+// BasePoints plus up to SpeedBonus depending on how quickly it came in, and
+// the total is multiplied by the participant's streak multiplier.
+type Scoring struct {
+	BasePoints int
+	SpeedBonus int
+
+	// SpeedWindow is the time over which the speed bonus decays for
+	// questions without a time limit; timed questions use their duration.
+	SpeedWindow time.Duration
+
+	// StreakBonus is added to the multiplier for every consecutive correct
+	// answer after the first, up to MaxStreakMultiplier.
+	StreakBonus         float64
+	MaxStreakMultiplier float64
+}
+
+// Points returns the points earned by answer for a question open for
+// duration (0 if untimed). streak is the number of consecutive correct
+// answers including this one.
+func (s Scoring) Points(answer *Answer, duration time.Duration, streak int) int {
+	if answer == nil || !answer.Correct {
+		return 0
+	}
+
+	window := duration
+	if window <= 0 {
+		window = s.SpeedWindow
+	}
+
+	points := float64(s.BasePoints)
+
+	if window > 0 && answer.ResponseTime < window {
+		remaining := 1 - float64(max(answer.ResponseTime, 0))/float64(window)
+		points += float64(s.SpeedBonus) * remaining
+	}
+
+	return int(math.Round(points * s.Multiplier(streak)))
+}
+
+// Multiplier returns the streak multiplier for the given streak length.
+func (s Scoring) Multiplier(streak int) float64 {
+	if streak <= 1 {
+		return 1
+	}
+
+	multiplier := 1 + s.StreakBonus*float64(streak-1)
+	if s.MaxStreakMultiplier > 0 {
+		multiplier = min(multiplier, s.MaxStreakMultiplier)
+	}
+
+	return multiplier
+}
@@ -0,0 +1,89 @@
+package quiz
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// defaultScoring matches the server's default configuration.
+var defaultScoring = Scoring{
+	BasePoints:          500,
+	SpeedBonus:          500,
+	SpeedWindow:         30 * time.Second,
+	StreakBonus:         0.1,
+	MaxStreakMultiplier: 2,
+}
+
+func TestPoints(t *testing.T) {
+	correct := func(responseTime time.Duration) *Answer {
+		return &Answer{Correct: true, ResponseTime: responseTime}
+	}
+
+	uncapped := defaultScoring
+	uncapped.MaxStreakMultiplier = 0
+
+	noWindow := defaultScoring
+	noWindow.SpeedWindow = 0
+
+	tests := []struct {
+		name     string
+		scoring  Scoring
+		answer   *Answer
+		duration time.Duration
+		streak   int
+		want     int
+	}{
+		{"no answer", defaultScoring, nil, 0, 1, 0},
+		{"wrong answer", defaultScoring, &Answer{Correct: false}, 0, 1, 0},
+		{"wrong answers get no streak", defaultScoring, &Answer{Correct: false}, 0, 5, 0},
+
+		// speed bonus
+		{"instant answer", defaultScoring, correct(0), 0, 1, 1000},
+		{"half the speed window", defaultScoring, correct(15 * time.Second), 0, 1, 750},
+		{"a third of the speed window rounds", defaultScoring, correct(10 * time.Second), 0, 1, 833},
+		{"end of the speed window", defaultScoring, correct(30 * time.Second), 0, 1, 500},
+		{"past the speed window", defaultScoring, correct(45 * time.Second), 0, 1, 500},
+		{"negative response time counts as instant", defaultScoring, correct(-time.Second), 0, 1, 1000},
+		{"timed questions decay over their duration", defaultScoring, correct(5 * time.Second), 10 * time.Second, 1, 750},
+		{"timed questions ignore the speed window", defaultScoring, correct(20 * time.Second), 10 * time.Second, 1, 500},
+		{"untimed without speed window", noWindow, correct(0), 0, 1, 500},
+
+		// streaks
+		{"second in a row", defaultScoring, correct(0), 0, 2, 1100},
+		{"third in a row", defaultScoring, correct(0), 0, 3, 1200},
+		{"streak and speed round once", defaultScoring, correct(10 * time.Second), 0, 2, 917},
+		{"streak reaches the cap", defaultScoring, correct(0), 0, 11, 2000},
+		{"streak beyond the cap", defaultScoring, correct(0), 0, 20, 2000},
+		{"no cap", uncapped, correct(0), 0, 20, 2900},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.scoring.Points(tt.answer, tt.duration, tt.streak); got != tt.want {
+				t.Fatalf("Points = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMultiplier(t *testing.T) {
+	tests := []struct {
+		streak int
+		want   float64
+	}{
+		{0, 1},
+		{1, 1},
+		{2, 1.1},
+		{5, 1.4},
+		{10, 1.9},
+		{11, 2},
+		{50, 2},
+	}
+
+	for _, tt := range tests {
+		if got := defaultScoring.Multiplier(tt.streak); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("Multiplier(%d) = %v, want %v", tt.streak, got, tt.want)
+		}
+	}
+}
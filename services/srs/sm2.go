@@ -0,0 +1,90 @@
+// Package srs schedules spaced-repetition reviews with the SM-2 algorithm
+// (https://super-memory.com/english/ol/sm2.htm).
+package srs
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	DefaultEase = 2.5
+	MinEase     = 1.3
+
+	// PassingQuality is the lowest quality that counts as remembered
+	PassingQuality = 3
+	MaxQuality     = 5
+
+	Day = 24 * time.Hour
+)
+
+// Card is the scheduling state of one question for one student.
+type Card struct {
+	// Repetitions is the number of consecutive successful reviews
+	Repetitions  int        `json:"repetitions"`
+	IntervalDays int        `json:"interval_days"`
+	Ease         float64    `json:"ease"`
+	Due          time.Time  `json:"due_at"`
+	LastReviewed *time.Time `json:"last_reviewed_at,omitempty"`
+}
+
+// NewCard returns the state of a question that was never reviewed; it is due
+// immediately.
+func NewCard(now time.Time) Card {
+	return Card{
+		Ease: DefaultEase,
+		Due:  now,
+	}
+}
+
+// Review returns the card's state after a review graded with quality (0-5).
+// A failed review restarts the repetition sequence; the ease factor is
+// adjusted either way and never drops below MinEase.
+func (c Card) Review(quality int, now time.Time) Card {
+	quality = max(0, min(MaxQuality, quality))
+
+	if quality < PassingQuality {
+		c.Repetitions = 0
+		c.IntervalDays = 1
+	} else {
+		switch c.Repetitions {
+		case 0:
+			c.IntervalDays = 1
+		case 1:
+			c.IntervalDays = 6
+		default:
+			c.IntervalDays = int(math.Round(float64(c.IntervalDays) * c.Ease))
+		}
+		c.Repetitions++
+	}
+
+	miss := float64(MaxQuality - quality)
+	c.Ease = max(MinEase, c.Ease+0.1-miss*(0.08+miss*0.02))
+
+	c.Due = now.Add(time.Duration(c.IntervalDays) * Day)
+	c.LastReviewed = &now
+
+	return c
+}
+
+// Quality maps a graded answer onto the SM-2 0-5 scale. Wrong answers score
+// 1; correct ones score 5, 4 or 3 depending on which third of window the
+// student took to answer. A zero window rates every correct answer 4.
+func Quality(correct bool, responseTime, window time.Duration) int {
+	if !correct {
+		return 1
+	}
+
+	if window <= 0 {
+		return 4
+	}
+
+	switch ratio := float64(responseTime) / float64(window); {
+	case ratio < 1.0/3:
+		return 5
+	case ratio < 2.0/3:
+		return 4
+	default:
+		return 3
+	}
+}
@@ -0,0 +1,157 @@
+package srs
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestReview(t *testing.T) {
+	type step struct {
+		quality     int
+		repetitions int
+		interval    int // days
+		ease        float64
+	}
+
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name: "perfect recall grows interval and ease",
+			steps: []step{
+				{quality: 5, repetitions: 1, interval: 1, ease: 2.6},
+				{quality: 5, repetitions: 2, interval: 6, ease: 2.7},
+				{quality: 5, repetitions: 3, interval: 16, ease: 2.8}, // 6 * 2.7
+				{quality: 5, repetitions: 4, interval: 45, ease: 2.9}, // 16 * 2.8
+			},
+		},
+		{
+			name: "quality 4 keeps the ease",
+			steps: []step{
+				{quality: 4, repetitions: 1, interval: 1, ease: 2.5},
+				{quality: 4, repetitions: 2, interval: 6, ease: 2.5},
+				{quality: 4, repetitions: 3, interval: 15, ease: 2.5},
+				{quality: 4, repetitions: 4, interval: 38, ease: 2.5}, // 37.5 rounds up
+			},
+		},
+		{
+			name: "hesitant recall lowers the ease",
+			steps: []step{
+				{quality: 3, repetitions: 1, interval: 1, ease: 2.36},
+				{quality: 3, repetitions: 2, interval: 6, ease: 2.22},
+				{quality: 3, repetitions: 3, interval: 13, ease: 2.08}, // 6 * 2.22
+				{quality: 3, repetitions: 4, interval: 27, ease: 1.94}, // 13 * 2.08
+			},
+		},
+		{
+			name: "a lapse restarts the sequence but keeps the lowered ease",
+			steps: []step{
+				{quality: 5, repetitions: 1, interval: 1, ease: 2.6},
+				{quality: 5, repetitions: 2, interval: 6, ease: 2.7},
+				{quality: 5, repetitions: 3, interval: 16, ease: 2.8},
+				{quality: 1, repetitions: 0, interval: 1, ease: 2.26},
+				{quality: 5, repetitions: 1, interval: 1, ease: 2.36},
+				{quality: 5, repetitions: 2, interval: 6, ease: 2.46},
+				{quality: 5, repetitions: 3, interval: 15, ease: 2.56}, // 6 * 2.46
+			},
+		},
+		{
+			name: "failing with quality 2 is a lapse",
+			steps: []step{
+				{quality: 4, repetitions: 1, interval: 1, ease: 2.5},
+				{quality: 2, repetitions: 0, interval: 1, ease: 2.18},
+			},
+		},
+		{
+			name: "ease never drops below the minimum",
+			steps: []step{
+				{quality: 0, repetitions: 0, interval: 1, ease: 1.7},
+				{quality: 0, repetitions: 0, interval: 1, ease: MinEase},
+				{quality: 0, repetitions: 0, interval: 1, ease: MinEase},
+				{quality: 3, repetitions: 1, interval: 1, ease: MinEase},
+			},
+		},
+		{
+			name: "out of range qualities are clamped",
+			steps: []step{
+				{quality: 9, repetitions: 1, interval: 1, ease: 2.6},
+				{quality: -3, repetitions: 0, interval: 1, ease: 1.8},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+			card := NewCard(now)
+
+			if !card.Due.Equal(now) || card.Ease != DefaultEase || card.LastReviewed != nil {
+				t.Fatalf("new card: %+v", card)
+			}
+
+			for i, s := range tt.steps {
+				card = card.Review(s.quality, now)
+
+				if card.Repetitions != s.repetitions || card.IntervalDays != s.interval || math.Abs(card.Ease-s.ease) > 1e-9 {
+					t.Fatalf("step %d (quality %d): got repetitions %d, interval %d, ease %.4f; want %d, %d, %.4f",
+						i, s.quality, card.Repetitions, card.IntervalDays, card.Ease, s.repetitions, s.interval, s.ease)
+				}
+
+				if want := now.Add(time.Duration(s.interval) * Day); !card.Due.Equal(want) {
+					t.Fatalf("step %d: due %v, want %v", i, card.Due, want)
+				}
+
+				if card.LastReviewed == nil || !card.LastReviewed.Equal(now) {
+					t.Fatalf("step %d: last reviewed %v, want %v", i, card.LastReviewed, now)
+				}
+
+				// the student reviews the card when it is due
+				now = card.Due
+			}
+		})
+	}
+}
+
+func TestReviewDoesNotModifyCard(t *testing.T) {
+	now := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+	card := NewCard(now)
+
+	card.Review(5, now)
+
+	if card.Repetitions != 0 || card.LastReviewed != nil {
+		t.Fatalf("Review modified the card: %+v", card)
+	}
+}
+
+func TestQuality(t *testing.T) {
+	window := 30 * time.Second
+
+	tests := []struct {
+		name         string
+		correct      bool
+		responseTime time.Duration
+		window       time.Duration
+		want         int
+	}{
+		{"wrong", false, time.Second, window, 1},
+		{"wrong and slow", false, time.Minute, window, 1},
+		{"fast", true, 5 * time.Second, window, 5},
+		{"a third of the window", true, 10 * time.Second, window, 4},
+		{"middle third", true, 15 * time.Second, window, 4},
+		{"two thirds of the window", true, 20 * time.Second, window, 3},
+		{"last third", true, 25 * time.Second, window, 3},
+		{"past the window", true, 40 * time.Second, window, 3},
+		{"no window", true, time.Hour, 0, 4},
+		{"wrong without window", false, time.Second, 0, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Quality(tt.correct, tt.responseTime, tt.window); got != tt.want {
+				t.Fatalf("Quality(%v, %v, %v) = %d, want %d", tt.correct, tt.responseTime, tt.window, got, tt.want)
+			}
+		})
+	}
+}
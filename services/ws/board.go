@@ -0,0 +1,369 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"mnemo/storage"
+)
+
+// States of a question on the Q&A board.
+const (
+	BoardOpen      = "open"
+	BoardAnswered  = "answered"
+	BoardDismissed = "dismissed"
+)
+
+// Actions a professor takes on a question on the Q&A board.
+const (
+	ModerateAnswer  = "answer"
+	ModeratePin     = "pin"
+	ModerateUnpin   = "unpin"
+	ModerateDismiss = "dismiss"
+)
+
+const (
+	boardQuestionIDBytes = 8
+
+	// maxOpenBoardQuestions is how many unanswered questions one student
+	// can have on the board, so nobody floods it
+	maxOpenBoardQuestions = 5
+)
+
+// boardQuestion is a question a student asked on the room's Q&A board.
+type boardQuestion struct {
+	ID            string
+	ParticipantID string
+	Text          string
+	Anonymous     bool
+	Status        string
+	Pinned        bool
+	AskedAt       time.Time
+
+	upvoters map[string]bool // participant IDs
+}
+
+// AskQuestion puts a student's question on the room's Q&A board.
+func AskQuestion(event Event, c *Client) error {
+	if c.role != RoleStudent {
+		return newProtocolError(CodeForbidden, "only students can ask questions")
+	}
+
+	var askEvent AskQuestionEvent
+	if err := json.Unmarshal(event.Payload, &askEvent); err != nil {
+		return badPayload(err)
+	}
+
+	text := strings.TrimSpace(askEvent.Text)
+	if text == "" {
+		return newProtocolError(CodeInvalidPayload, "question cannot be empty")
+	}
+
+	id, err := newToken(boardQuestionIDBytes)
+	if err != nil {
+		return errors.Wrap(err, "unable to generate question id")
+	}
+
+	q := &boardQuestion{
+		ID:            id,
+		ParticipantID: c.participant.ID,
+		Text:          text,
+		Anonymous:     askEvent.Anonymous,
+		Status:        BoardOpen,
+		AskedAt:       time.Now(),
+		upvoters:      make(map[string]bool),
+	}
+
+	room := c.room
+
+	room.boardWrites.Lock()
+	defer room.boardWrites.Unlock()
+
+	room.sync.RLock()
+	open := room.openBoardQuestions(c.participant.ID)
+	room.sync.RUnlock()
+
+	if open >= maxOpenBoardQuestions {
+		return newProtocolError(CodeInvalidState, "at most %d of your questions can be open at a time", maxOpenBoardQuestions)
+	}
+
+	if err := room.saveBoardQuestion(q); err != nil {
+		return err
+	}
+
+	room.sync.Lock()
+	room.board[q.ID] = q
+	own := room.boardView(q, c.participant.ID)
+	room.sync.Unlock()
+
+	if err := c.sendEvent(EventQuestionAsked, QuestionAskedEvent{Question: own}); err != nil {
+		return err
+	}
+
+	return room.publishBoardQuestion(q)
+}
+
+// UpvoteQuestion adds or, with retract, takes back a student's upvote on an
+// open question of another student.
+func UpvoteQuestion(event Event, c *Client) error {
+	if c.role != RoleStudent {
+		return newProtocolError(CodeForbidden, "only students can upvote questions")
+	}
+
+	var upvoteEvent UpvoteQuestionEvent
+	if err := json.Unmarshal(event.Payload, &upvoteEvent); err != nil {
+		return badPayload(err)
+	}
+
+	room := c.room
+	voter := c.participant.ID
+
+	room.boardWrites.Lock()
+	defer room.boardWrites.Unlock()
+
+	room.sync.RLock()
+	q, ok := room.board[upvoteEvent.QuestionID]
+	var status string
+	var upvoted bool
+	if ok {
+		status, upvoted = q.Status, q.upvoters[voter]
+	}
+	room.sync.RUnlock()
+
+	switch {
+	case !ok:
+		return newProtocolError(CodeNotFound, "unknown question '%s'", upvoteEvent.QuestionID)
+	case q.ParticipantID == voter:
+		return newProtocolError(CodeForbidden, "you cannot upvote your own question")
+	case status != BoardOpen:
+		return newProtocolError(CodeInvalidState, "question is %s", status)
+	case upvoted != upvoteEvent.Retract:
+		// already upvoted, or not upvoted to begin with
+		return nil
+	}
+
+	if err := room.store.SetUpvote(context.Background(), q.ID, voter, !upvoteEvent.Retract); err != nil {
+		return err
+	}
+
+	room.sync.Lock()
+	if upvoteEvent.Retract {
+		delete(q.upvoters, voter)
+	} else {
+		q.upvoters[voter] = true
+	}
+	room.sync.Unlock()
+
+	return room.publishBoardQuestion(q)
+}
+
+// ModerateQuestion lets the professor mark a question answered, pin or unpin
+// it, or dismiss it from the board.
+func ModerateQuestion(event Event, c *Client) error {
+	if c.role != RoleProfessor {
+		return newProtocolError(CodeForbidden, "only professors can moderate questions")
+	}
+
+	var moderateEvent ModerateQuestionEvent
+	if err := json.Unmarshal(event.Payload, &moderateEvent); err != nil {
+		return badPayload(err)
+	}
+
+	room := c.room
+
+	room.boardWrites.Lock()
+	defer room.boardWrites.Unlock()
+
+	room.sync.RLock()
+	q, ok := room.board[moderateEvent.QuestionID]
+	var updated boardQuestion
+	if ok {
+		updated = *q
+	}
+	room.sync.RUnlock()
+
+	if !ok {
+		return newProtocolError(CodeNotFound, "unknown question '%s'", moderateEvent.QuestionID)
+	}
+
+	if updated.Status == BoardDismissed {
+		return newProtocolError(CodeInvalidState, "question is dismissed")
+	}
+
+	switch moderateEvent.Action {
+	case ModerateAnswer:
+		updated.Status = BoardAnswered
+		updated.Pinned = false
+	case ModeratePin:
+		updated.Pinned = true
+	case ModerateUnpin:
+		updated.Pinned = false
+	case ModerateDismiss:
+		updated.Status = BoardDismissed
+		updated.Pinned = false
+	default:
+		return newProtocolError(CodeInvalidPayload, "unknown action '%s'", moderateEvent.Action)
+	}
+
+	if err := room.saveBoardQuestion(&updated); err != nil {
+		return err
+	}
+
+	room.sync.Lock()
+	q.Status, q.Pinned = updated.Status, updated.Pinned
+	room.sync.Unlock()
+
+	return room.publishBoardQuestion(q)
+}
+
+// saveBoardQuestion stores the question. The caller must hold boardWrites,
+// but not the room lock.
+func (r *Room) saveBoardQuestion(q *boardQuestion) error {
+	return r.store.SaveStudentQuestion(context.Background(), &storage.StudentQuestion{
+		ID:            q.ID,
+		RoomID:        r.ID,
+		ParticipantID: q.ParticipantID,
+		Text:          q.Text,
+		Anonymous:     q.Anonymous,
+		Status:        q.Status,
+		Pinned:        q.Pinned,
+		AskedAt:       q.AskedAt,
+	})
+}
+
+// openBoardQuestions counts the participant's unanswered questions. The
+// caller must hold the room lock.
+func (r *Room) openBoardQuestions(participantID string) int {
+	open := 0
+	for _, q := range r.board {
+		if q.ParticipantID == participantID && q.Status == BoardOpen {
+			open++
+		}
+	}
+
+	return open
+}
+
+// publishBoardQuestion sends the changed question to the students and the
+// whole board to the professors. The snapshots are queued under the same
+// lock they are taken under: a change can't slip in between, so clients get
+// the snapshots in the order of the changes.
+func (r *Room) publishBoardQuestion(q *boardQuestion) error {
+	r.sync.RLock()
+	defer r.sync.RUnlock()
+
+	if err := r.notifyRoleLocked(RoleStudent, EventBoardQuestionUpdated, BoardQuestionUpdatedEvent{Question: r.boardView(q, "")}); err != nil {
+		return err
+	}
+
+	return r.notifyRoleLocked(RoleProfessor, EventBoardUpdated, BoardUpdatedEvent{Questions: r.professorBoard()})
+}
+
+// sendBoard sends the board to a professor that just connected.
+func (r *Room) sendBoard(client *Client) {
+	r.sync.RLock()
+	board := r.professorBoard()
+	r.sync.RUnlock()
+
+	if len(board) == 0 {
+		return
+	}
+
+	if err := client.sendEvent(EventBoardUpdated, BoardUpdatedEvent{Questions: board}); err != nil {
+		client.log.Error("unable to send board", zap.Error(err))
+	}
+}
+
+// boardView is the question as seen by the given student; an empty
+// participantID is the view every student may see. The caller must hold the
+// room lock.
+func (r *Room) boardView(q *boardQuestion, participantID string) BoardQuestion {
+	view := BoardQuestion{
+		ID:        q.ID,
+		Text:      q.Text,
+		Anonymous: q.Anonymous,
+		Status:    q.Status,
+		Pinned:    q.Pinned,
+		Upvotes:   len(q.upvoters),
+		AskedAt:   q.AskedAt,
+	}
+
+	if !q.Anonymous {
+		view.Author = r.participantName(q.ParticipantID)
+	}
+
+	if participantID != "" {
+		view.Mine = q.ParticipantID == participantID
+		view.Upvoted = q.upvoters[participantID]
+	}
+
+	return view
+}
+
+// studentBoard is the sorted board as seen by the given student. The caller
+// must hold the room lock.
+func (r *Room) studentBoard(participantID string) []BoardQuestion {
+	board := make([]BoardQuestion, 0, len(r.board))
+	for _, q := range r.board {
+		if q.Status != BoardDismissed {
+			board = append(board, r.boardView(q, participantID))
+		}
+	}
+
+	sortBoard(board)
+	return board
+}
+
+// professorBoard is the sorted board with every author named. The caller
+// must hold the room lock.
+func (r *Room) professorBoard() []BoardQuestion {
+	board := make([]BoardQuestion, 0, len(r.board))
+	for _, q := range r.board {
+		if q.Status == BoardDismissed {
+			continue
+		}
+
+		view := r.boardView(q, "")
+		view.Author = r.participantName(q.ParticipantID)
+		view.AuthorID = q.ParticipantID
+		board = append(board, view)
+	}
+
+	sortBoard(board)
+	return board
+}
+
+// participantName returns the display name of a participant. The caller must
+// hold the room lock.
+func (r *Room) participantName(id string) string {
+	if p, ok := r.participants[id]; ok {
+		return p.Name
+	}
+
+	return ""
+}
+
+// sortBoard puts pinned questions first, answered ones last and the rest by
+// upvotes, oldest first among equals.
+func sortBoard(board []BoardQuestion) {
+	sort.Slice(board, func(i, j int) bool {
+		a, b := board[i], board[j]
+
+		if a.Pinned != b.Pinned {
+			return a.Pinned
+		}
+		if (a.Status == BoardAnswered) != (b.Status == BoardAnswered) {
+			return b.Status == BoardAnswered
+		}
+		if a.Upvotes != b.Upvotes {
+			return a.Upvotes > b.Upvotes
+		}
+		return a.AskedAt.Before(b.AskedAt)
+	})
+}
@@ -0,0 +1,393 @@
+package ws
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"mnemo/storage"
+)
+
+// student connects a new student to the room and joins it under the given
+// name.
+func student(t *testing.T, m *Manager, room *Room, name string) *Client {
+	t.Helper()
+
+	client := connect(t, m, room, RoleStudent)
+	if err := send(t, client, EventJoinRoom, JoinRoomEvent{Name: name}); err != nil {
+		t.Fatalf("join_room: %v", err)
+	}
+	received(client)
+
+	return client
+}
+
+// ask puts a question on the board and returns it as its author sees it.
+func ask(t *testing.T, c *Client, text string) BoardQuestion {
+	t.Helper()
+
+	if err := send(t, c, EventAskQuestion, AskQuestionEvent{Text: text}); err != nil {
+		t.Fatalf("ask_question: %v", err)
+	}
+
+	var asked QuestionAskedEvent
+	if !lastEvent(t, received(c), EventQuestionAsked, &asked) {
+		t.Fatal("question was not confirmed")
+	}
+
+	return asked.Question
+}
+
+// errorCode is the code of a protocol error, or "" for any other error.
+func errorCode(err error) string {
+	var protocolErr *ProtocolError
+	if errors.As(err, &protocolErr) {
+		return protocolErr.Code
+	}
+
+	return ""
+}
+
+// storedQuestion loads the question from the store.
+func storedQuestion(t *testing.T, m *Manager, room *Room, id string) *storage.StudentQuestion {
+	t.Helper()
+
+	questions, err := m.store.ListStudentQuestions(context.Background(), room.ID)
+	if err != nil {
+		t.Fatalf("ListStudentQuestions: %v", err)
+	}
+
+	for _, q := range questions {
+		if q.ID == id {
+			return q
+		}
+	}
+
+	t.Fatalf("question %s was not stored", id)
+	return nil
+}
+
+func newBoardRoom(t *testing.T) (*Manager, *Room) {
+	t.Helper()
+
+	m := newTestManager(t)
+
+	room, err := m.CreateRoom(context.Background(), testProfessor, "")
+	if err != nil {
+		t.Fatalf("CreateRoom: %v", err)
+	}
+
+	return m, room
+}
+
+func TestAskQuestion(t *testing.T) {
+	m, room := newBoardRoom(t)
+
+	ada := student(t, m, room, "Ada")
+	professor := connect(t, m, room, RoleProfessor)
+
+	tests := []struct {
+		name     string
+		client   *Client
+		text     string
+		wantCode string
+	}{
+		{"question", ada, " What is a monad? ", ""},
+		{"empty question", ada, "  ", CodeInvalidPayload},
+		{"professor", professor, "Any questions?", CodeForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := send(t, tt.client, EventAskQuestion, AskQuestionEvent{Text: tt.text})
+			if code := errorCode(err); code != tt.wantCode || (tt.wantCode == "" && err != nil) {
+				t.Fatalf("ask_question = %v, want code %q", err, tt.wantCode)
+			}
+			if tt.wantCode != "" {
+				return
+			}
+
+			var asked QuestionAskedEvent
+			if !lastEvent(t, received(tt.client), EventQuestionAsked, &asked) {
+				t.Fatal("question was not confirmed")
+			}
+			if q := asked.Question; q.Text != "What is a monad?" || q.Status != BoardOpen || !q.Mine || q.Author != "Ada" {
+				t.Errorf("confirmed question = %+v", q)
+			}
+
+			var board BoardUpdatedEvent
+			if !lastEvent(t, received(professor), EventBoardUpdated, &board) {
+				t.Fatal("professor got no board")
+			}
+			if len(board.Questions) != 1 || board.Questions[0].AuthorID != ada.participant.ID {
+				t.Errorf("board = %+v", board.Questions)
+			}
+
+			if stored := storedQuestion(t, m, room, asked.Question.ID); stored.Status != BoardOpen {
+				t.Errorf("stored status = %s, want %s", stored.Status, BoardOpen)
+			}
+		})
+	}
+}
+
+func TestAskQuestionLimit(t *testing.T) {
+	m, room := newBoardRoom(t)
+
+	ada := student(t, m, room, "Ada")
+	bob := student(t, m, room, "Bob")
+	professor := connect(t, m, room, RoleProfessor)
+
+	var first BoardQuestion
+	for i := 0; i < maxOpenBoardQuestions; i++ {
+		q := ask(t, ada, "question")
+		if i == 0 {
+			first = q
+		}
+	}
+
+	err := send(t, ada, EventAskQuestion, AskQuestionEvent{Text: "one too many"})
+	if code := errorCode(err); code != CodeInvalidState {
+		t.Fatalf("question over the limit = %v, want code %s", err, CodeInvalidState)
+	}
+
+	// the limit is per student
+	ask(t, bob, "my question")
+
+	// answered questions don't count
+	if err := send(t, professor, EventModerateQuestion, ModerateQuestionEvent{QuestionID: first.ID, Action: ModerateAnswer}); err != nil {
+		t.Fatalf("moderate_question: %v", err)
+	}
+	ask(t, ada, "another question")
+
+	questions, err := m.store.ListStudentQuestions(context.Background(), room.ID)
+	if err != nil {
+		t.Fatalf("ListStudentQuestions: %v", err)
+	}
+	if len(questions) != maxOpenBoardQuestions+2 {
+		t.Errorf("stored %d questions, want %d", len(questions), maxOpenBoardQuestions+2)
+	}
+}
+
+func TestUpvoteQuestion(t *testing.T) {
+	tests := []struct {
+		name string
+		// votes are sent by Bob on Ada's question before the tested one
+		votes    []UpvoteQuestionEvent
+		action   string // moderation applied before the vote
+		self     bool   // Ada votes on her own question
+		unknown  bool
+		retract  bool
+		wantCode string
+		want     int // upvotes after the vote
+	}{
+		{name: "upvote", want: 1},
+		{name: "upvote twice", votes: []UpvoteQuestionEvent{{}}, want: 1},
+		{name: "retract", votes: []UpvoteQuestionEvent{{}}, retract: true, want: 0},
+		{name: "retract without upvote", retract: true, want: 0},
+		{name: "upvote again after retract", votes: []UpvoteQuestionEvent{{}, {Retract: true}}, want: 1},
+		{name: "own question", self: true, wantCode: CodeForbidden},
+		{name: "unknown question", unknown: true, wantCode: CodeNotFound},
+		{name: "answered question", action: ModerateAnswer, wantCode: CodeInvalidState},
+		{name: "dismissed question", action: ModerateDismiss, wantCode: CodeInvalidState},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, room := newBoardRoom(t)
+
+			ada := student(t, m, room, "Ada")
+			bob := student(t, m, room, "Bob")
+			professor := connect(t, m, room, RoleProfessor)
+
+			q := ask(t, ada, "What is a monad?")
+
+			for _, vote := range tt.votes {
+				vote.QuestionID = q.ID
+				if err := send(t, bob, EventUpvoteQuestion, vote); err != nil {
+					t.Fatalf("upvote_question: %v", err)
+				}
+			}
+
+			if tt.action != "" {
+				if err := send(t, professor, EventModerateQuestion, ModerateQuestionEvent{QuestionID: q.ID, Action: tt.action}); err != nil {
+					t.Fatalf("moderate_question: %v", err)
+				}
+			}
+
+			voter, vote := bob, UpvoteQuestionEvent{QuestionID: q.ID, Retract: tt.retract}
+			if tt.self {
+				voter = ada
+			}
+			if tt.unknown {
+				vote.QuestionID = "unknown"
+			}
+
+			err := send(t, voter, EventUpvoteQuestion, vote)
+			if code := errorCode(err); code != tt.wantCode || (tt.wantCode == "" && err != nil) {
+				t.Fatalf("upvote_question = %v, want code %q", err, tt.wantCode)
+			}
+			if tt.wantCode != "" {
+				return
+			}
+
+			room.sync.RLock()
+			upvotes := len(room.board[q.ID].upvoters)
+			room.sync.RUnlock()
+
+			if upvotes != tt.want {
+				t.Errorf("upvotes = %d, want %d", upvotes, tt.want)
+			}
+
+			if stored := storedQuestion(t, m, room, q.ID); len(stored.Upvoters) != tt.want {
+				t.Errorf("stored upvoters = %v, want %d", stored.Upvoters, tt.want)
+			}
+		})
+	}
+}
+
+func TestModerateQuestion(t *testing.T) {
+	tests := []struct {
+		name    string
+		before  []string // actions applied first
+		action  string
+		student bool // a student moderates
+
+		wantCode   string
+		wantStatus string
+		wantPinned bool
+	}{
+		{name: "answer", action: ModerateAnswer, wantStatus: BoardAnswered},
+		{name: "pin", action: ModeratePin, wantStatus: BoardOpen, wantPinned: true},
+		{name: "unpin", before: []string{ModeratePin}, action: ModerateUnpin, wantStatus: BoardOpen},
+		{name: "answer unpins", before: []string{ModeratePin}, action: ModerateAnswer, wantStatus: BoardAnswered},
+		{name: "pin answered question", before: []string{ModerateAnswer}, action: ModeratePin, wantStatus: BoardAnswered, wantPinned: true},
+		{name: "dismiss", before: []string{ModeratePin}, action: ModerateDismiss, wantStatus: BoardDismissed},
+		{name: "dismiss answered question", before: []string{ModerateAnswer}, action: ModerateDismiss, wantStatus: BoardDismissed},
+		{name: "moderate dismissed question", before: []string{ModerateDismiss}, action: ModeratePin, wantCode: CodeInvalidState, wantStatus: BoardDismissed},
+		{name: "unknown action", action: "delete", wantCode: CodeInvalidPayload, wantStatus: BoardOpen},
+		{name: "student", action: ModerateDismiss, student: true, wantCode: CodeForbidden, wantStatus: BoardOpen},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, room := newBoardRoom(t)
+
+			ada := student(t, m, room, "Ada")
+			bob := student(t, m, room, "Bob")
+			professor := connect(t, m, room, RoleProfessor)
+
+			q := ask(t, ada, "What is a monad?")
+
+			for _, action := range tt.before {
+				if err := send(t, professor, EventModerateQuestion, ModerateQuestionEvent{QuestionID: q.ID, Action: action}); err != nil {
+					t.Fatalf("moderate_question %s: %v", action, err)
+				}
+			}
+			received(bob)
+
+			moderator := professor
+			if tt.student {
+				moderator = bob
+			}
+
+			err := send(t, moderator, EventModerateQuestion, ModerateQuestionEvent{QuestionID: q.ID, Action: tt.action})
+			if code := errorCode(err); code != tt.wantCode || (tt.wantCode == "" && err != nil) {
+				t.Fatalf("moderate_question = %v, want code %q", err, tt.wantCode)
+			}
+
+			stored := storedQuestion(t, m, room, q.ID)
+			if stored.Status != tt.wantStatus || stored.Pinned != tt.wantPinned {
+				t.Errorf("stored status %s, pinned %v, want %s, %v", stored.Status, stored.Pinned, tt.wantStatus, tt.wantPinned)
+			}
+
+			if tt.wantCode != "" {
+				return
+			}
+
+			// students get the change, dismissals included, so they can drop it
+			var updated BoardQuestionUpdatedEvent
+			if !lastEvent(t, received(bob), EventBoardQuestionUpdated, &updated) {
+				t.Fatal("students got no update")
+			}
+			if updated.Question.Status != tt.wantStatus || updated.Question.Pinned != tt.wantPinned {
+				t.Errorf("update = %+v", updated.Question)
+			}
+
+			var board BoardUpdatedEvent
+			if !lastEvent(t, received(professor), EventBoardUpdated, &board) {
+				t.Fatal("professor got no board")
+			}
+			if want := tt.wantStatus != BoardDismissed; (len(board.Questions) == 1) != want {
+				t.Errorf("board = %+v, want question on it: %v", board.Questions, want)
+			}
+		})
+	}
+}
+
+func TestSortBoard(t *testing.T) {
+	now := time.Now()
+
+	board := []BoardQuestion{
+		{ID: "answered-popular", Status: BoardAnswered, Upvotes: 9, AskedAt: now},
+		{ID: "open-new", Status: BoardOpen, Upvotes: 2, AskedAt: now.Add(time.Minute)},
+		{ID: "open-old", Status: BoardOpen, Upvotes: 2, AskedAt: now},
+		{ID: "pinned", Status: BoardOpen, Pinned: true, AskedAt: now.Add(2 * time.Minute)},
+		{ID: "open-popular", Status: BoardOpen, Upvotes: 5, AskedAt: now.Add(3 * time.Minute)},
+		{ID: "answered", Status: BoardAnswered, AskedAt: now},
+		{ID: "pinned-answered", Status: BoardAnswered, Pinned: true, AskedAt: now},
+	}
+
+	want := []string{"pinned", "pinned-answered", "open-popular", "open-old", "open-new", "answered-popular", "answered"}
+
+	sortBoard(board)
+
+	for i, q := range board {
+		if q.ID != want[i] {
+			got := make([]string, len(board))
+			for j := range board {
+				got[j] = board[j].ID
+			}
+			t.Fatalf("order = %v, want %v", got, want)
+		}
+	}
+}
+
+func (s *lockProbeStore) SaveStudentQuestion(ctx context.Context, q *storage.StudentQuestion) error {
+	s.probe()
+	return s.Repository.SaveStudentQuestion(ctx, q)
+}
+
+func (s *lockProbeStore) SetUpvote(ctx context.Context, questionID, participantID string, up bool) error {
+	s.probe()
+	return s.Repository.SetUpvote(ctx, questionID, participantID, up)
+}
+
+func TestBoardSavesOutsideRoomLock(t *testing.T) {
+	m, room := newBoardRoom(t)
+
+	ada := student(t, m, room, "Ada")
+	bob := student(t, m, room, "Bob")
+	professor := connect(t, m, room, RoleProfessor)
+
+	probe := &lockProbeStore{Repository: room.store, room: room}
+	room.store = probe
+
+	q := ask(t, ada, "What is a monad?")
+
+	if err := send(t, bob, EventUpvoteQuestion, UpvoteQuestionEvent{QuestionID: q.ID}); err != nil {
+		t.Fatalf("upvote_question: %v", err)
+	}
+	if err := send(t, professor, EventModerateQuestion, ModerateQuestionEvent{QuestionID: q.ID, Action: ModerateAnswer}); err != nil {
+		t.Fatalf("moderate_question: %v", err)
+	}
+
+	if probe.locked.Load() {
+		t.Fatal("board was saved while the room was locked")
+	}
+
+	stored := storedQuestion(t, m, room, q.ID)
+	if stored.Status != BoardAnswered || len(stored.Upvoters) != 1 {
+		t.Fatalf("stored question = %+v", stored)
+	}
+}
@@ -2,17 +2,41 @@ package ws
 
 import (
 	"encoding/json"
-	"github.com/gorilla/websocket"
-	"log"
+	"fmt"
+	"sync"
+	"sync/atomic"
 	"time"
+
+	"go.uber.org/zap"
+
+	"mnemo/clog"
 )
 
 var (
+	// writeWait bounds a single write, so a stalled connection fills its
+	// egress queue instead of hanging the writer forever
+	writeWait = 10 * time.Second
+
 	pongWait = 10 * time.Second
 	// 90% of pongWait
 	pingInterval = (pongWait * 9) / 10
 )
 
+// maxMessageSize limits a single inbound message on every transport. The
+// largest payload the schemas allow is a publish_question with about 16800
+// characters of strings. JSON escapes a character in up to 12 bytes (one
+// outside the BMP as two \uXXXX escapes), so compactly encoded it stays below
+// 200 KiB plus the JSON around the strings.
+const maxMessageSize = 256 << 10
+
+// Transport is the connection a client is served over: a websocket, or an
+// event stream with posted messages for networks that break websockets.
+type Transport interface {
+	// Close ends the connection. The transport's loops notice and remove the
+	// client from its room.
+	Close() error
+}
+
 type ClientList map[*Client]bool
 
 const (
@@ -21,114 +45,156 @@ const (
 )
 
 type Client struct {
-	connection *websocket.Conn
+	// id is unique across instances; a client relayed to another instance
+	// keeps its id there, so their logs correlate
+	id string
+
+	connection Transport
 	manager    *Manager
+	room       *Room
 	role       string // "professor" or "student"
+	name       string // display name of a professor
+
+	// participant is the student's identity, set once the join handshake
+	// succeeds
+	participant *Participant
+
+	// review is set for self-paced review connections, which have no room
+	review *reviewSession
+
+	// upstream is set for clients of rooms hosted by another instance; they
+	// have no local room either and their messages are relayed
+	upstream *upstream
+
+	// egress is used to avoid concurrent writes on the ws connection. It is
+	// bounded; see send for what happens when it fills up.
+	egress  chan Event
+	evicted atomic.Bool
+	removed atomic.Bool
 
-	// egress is used to avoid concurrent writes on the ws connection
-	egress chan Event
+	// closing is closed to have the transport flush the egress queue and
+	// close the connection
+	closing     chan struct{}
+	closingOnce sync.Once
+
+	log clog.ICustomLog
 }
 
-func NewClient(conn *websocket.Conn, manager *Manager, role string) *Client {
+// NewClient creates a client connected to this instance over conn.
+func NewClient(conn Transport, manager *Manager, room *Room, role string) *Client {
+	client := newClient(manager.newClientID(), conn, manager, room, role)
+	manager.track(client)
+
+	return client
+}
+
+func newClient(id string, conn Transport, manager *Manager, room *Room, role string) *Client {
+	fields := []zap.Field{zap.String("client", id), zap.String("role", role)}
+	if room != nil {
+		fields = append(fields, zap.String("room", room.ID))
+	}
+
 	return &Client{
+		id:         id,
 		connection: conn,
 		manager:    manager,
+		room:       room,
 		role:       role,
-		egress:     make(chan Event),
+		egress:     make(chan Event, manager.config.EgressQueueSize),
+		closing:    make(chan struct{}),
+		log:        manager.log.With(fields...),
 	}
 }
 
-func (c *Client) readMessages() {
-	defer func() {
-		// cleanup connection
-		c.manager.removeClient(c)
-	}()
-
-	if err := c.connection.SetReadDeadline(time.Now().Add(pongWait)); err != nil {
-		log.Println(err)
+// handleMessage decodes one inbound message and routes it to its handler.
+// Failures are reported back to the client as error events. Transports call
+// it for one message at a time.
+func (c *Client) handleMessage(payload []byte) {
+	if c.upstream != nil {
+		c.forward(payload)
 		return
 	}
 
-	// 512 bytes limit
-	c.connection.SetReadLimit(512)
-
-	c.connection.SetPongHandler(c.pongHandler)
-
-	for {
-		_, payload, err := c.connection.ReadMessage()
-		if err != nil {
-			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				log.Printf("error reading message: %v", err)
-			}
-			break
+	request, err := decodeEvent(payload)
+	if err != nil {
+		// only known types make it into metrics
+		eventType := ""
+		if _, ok := schemas[request.Type]; ok && request.Type != envelopeSchema {
+			eventType = request.Type
 		}
 
-		var request Event
+		c.manager.observer.EventFailed(eventType, c.reportError(err, request.ID))
+		return
+	}
 
-		if err := json.Unmarshal(payload, &request); err != nil {
-			log.Printf("error marshalling event: %v", err)
+	/**
+	Possible payload here:
 
-			//TODO: possible break
-		}
+	{
+	    "version": 1,
+	    "id": "c-17",
+	    "type": "send_message",
+	    "payload": {
+	        "message": "hello"
+	    }
+	}
+	*/
+	if err := c.manager.routeEvent(request, c); err != nil {
+		c.manager.observer.EventFailed(request.Type, c.reportError(err, request.ID))
+	}
+}
 
-		/**
-		Possible payload here:
+// sendEvent marshals the payload and queues it for this client only. Events
+// to professors and joined students are sequenced like broadcasts, so they
+// are replayed if the client reconnects.
+func (c *Client) sendEvent(eventType string, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s event: %v", eventType, err)
+	}
 
-		{
-		    "type": "send_message",
-		    "payload": {
-		        "from": "student",
-		        "message": "hello"
-		    }
-		}
-		*/
-		if err := c.manager.routeEvent(request, c); err != nil {
-			log.Printf("error handling event: %v", err)
-		}
+	event := Event{
+		Type:    eventType,
+		Payload: data,
+	}
 
+	switch {
+	case c.room == nil || volatileEvents[eventType]:
+		c.send(event)
+	case c.role == RoleProfessor:
+		c.room.deliver(event, RoleProfessor, "", []*Client{c})
+	case c.participant != nil:
+		c.room.deliver(event, RoleStudent, c.participant.ID, []*Client{c})
+	default:
+		c.send(event)
 	}
+
+	return nil
 }
 
-func (c *Client) writeMessages() {
-	defer func() {
-		// cleanup connection
+// shutdown closes the connection once the events queued so far are sent.
+// Clients of other instances are closed there, after the events relayed
+// before.
+func (c *Client) shutdown() {
+	if _, ok := c.connection.(*remoteConn); ok {
 		c.manager.removeClient(c)
-	}()
-
-	ticker := time.NewTicker(pingInterval)
-
-	for {
-		select {
-		case message, ok := <-c.egress:
-			if !ok {
-				if err := c.connection.WriteMessage(websocket.CloseMessage, nil); err != nil {
-					log.Println("connection closed:", err)
-				}
-				return
-			}
-
-			data, err := json.Marshal(message)
-			if err != nil {
-				log.Println("error marshalling message:", err)
-				return
-			}
-
-			if err := c.connection.WriteMessage(websocket.TextMessage, data); err != nil {
-				log.Println("failed send message:", err)
-			}
-			log.Println("message sent")
-		case <-ticker.C:
-			log.Println("ping")
-			// send ping to client to keep connection alive
-			if err := c.connection.WriteMessage(websocket.PingMessage, []byte(``)); err != nil {
-				log.Println("failed sending ping:", err)
-				return
-			}
-		}
+		return
 	}
+
+	c.closingOnce.Do(func() {
+		close(c.closing)
+	})
 }
 
-func (c *Client) pongHandler(pongMsg string) error {
-	log.Println("pong")
-	return c.connection.SetReadDeadline(time.Now().Add(pongWait))
+// displayName is the name other room members see for this client.
+func (c *Client) displayName() string {
+	if c.participant != nil {
+		return c.participant.Name
+	}
+
+	if c.name != "" {
+		return c.name
+	}
+
+	return c.role
 }
@@ -0,0 +1,113 @@
+package ws
+
+import (
+	"sync/atomic"
+
+	"go.uber.org/zap"
+)
+
+// Policies for a client whose egress queue is full, i.e. that reads slower
+// than the room produces events.
+const (
+	// EgressDropNewest discards the event that did not fit
+	EgressDropNewest = "drop-newest"
+	// EgressDropOldest discards the oldest queued event to make room
+	EgressDropOldest = "drop-oldest"
+	// EgressEvict disconnects the client; it resumes with its token and gets
+	// the room state from room_joined
+	EgressEvict = "evict"
+)
+
+// EgressStats counts what happened to outgoing events.
+type EgressStats struct {
+	Queued  uint64 `json:"queued"`
+	Dropped uint64 `json:"dropped"`
+	Evicted uint64 `json:"evicted"` // clients disconnected for being too slow
+}
+
+type egressCounters struct {
+	queued  atomic.Uint64
+	dropped atomic.Uint64
+	evicted atomic.Uint64
+}
+
+func (e *egressCounters) snapshot() EgressStats {
+	return EgressStats{
+		Queued:  e.queued.Load(),
+		Dropped: e.dropped.Load(),
+		Evicted: e.evicted.Load(),
+	}
+}
+
+// send queues the event for the client without ever blocking, so one slow
+// client cannot hold up a broadcast to the rest of the room. If the queue is
+// full the configured policy decides what gives. It reports whether the
+// event was queued.
+func (c *Client) send(event Event) bool {
+	stamp(&event)
+
+	// clients of other instances queue there
+	if conn, ok := c.connection.(*remoteConn); ok {
+		conn.link.deliver([]string{conn.id}, event)
+		return true
+	}
+
+	if c.evicted.Load() {
+		c.dropped()
+		return false
+	}
+
+	if c.tryQueue(event) {
+		return true
+	}
+
+	switch c.manager.config.EgressPolicy {
+	case EgressDropOldest:
+		select {
+		case <-c.egress:
+			c.dropped()
+		default:
+		}
+
+		if c.tryQueue(event) {
+			return true
+		}
+	case EgressEvict:
+		c.evict()
+	}
+
+	c.dropped()
+	return false
+}
+
+func (c *Client) tryQueue(event Event) bool {
+	select {
+	case c.egress <- event:
+		c.manager.egress.queued.Add(1)
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *Client) dropped() {
+	c.manager.egress.dropped.Add(1)
+
+	if c.room != nil {
+		c.room.dropped.Add(1)
+	}
+}
+
+// evict disconnects the client. Closing the connection makes the read loop
+// exit, which removes the client from its room; removal can't happen here as
+// senders usually hold the room lock.
+func (c *Client) evict() {
+	if !c.evicted.CompareAndSwap(false, true) {
+		return
+	}
+
+	c.manager.egress.evicted.Add(1)
+	c.log.Warn("evicting slow client: egress queue full", zap.String("name", c.displayName()))
+
+	c.connection.Close()
+}
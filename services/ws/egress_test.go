@@ -0,0 +1,101 @@
+package ws
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestSend(t *testing.T) {
+	tests := []struct {
+		policy string
+
+		wantQueued  []string // event types left in the queue
+		wantSent    []bool   // send's result for each event
+		wantStats   EgressStats
+		wantEvicted bool
+	}{
+		{
+			policy:     EgressDropNewest,
+			wantQueued: []string{"first", "second"},
+			wantSent:   []bool{true, true, false, false},
+			wantStats:  EgressStats{Queued: 2, Dropped: 2},
+		},
+		{
+			policy:     EgressDropOldest,
+			wantQueued: []string{"third", "fourth"},
+			wantSent:   []bool{true, true, true, true},
+			wantStats:  EgressStats{Queued: 4, Dropped: 2},
+		},
+		{
+			policy:      EgressEvict,
+			wantQueued:  []string{"first", "second"},
+			wantSent:    []bool{true, true, false, false},
+			wantStats:   EgressStats{Queued: 2, Dropped: 2, Evicted: 1},
+			wantEvicted: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			cfg := testConfig()
+			cfg.EgressQueueSize = 2
+			cfg.EgressPolicy = tt.policy
+
+			m := newTestManagerConfig(t, cfg)
+
+			room, err := m.CreateRoom(context.Background(), testProfessor, "")
+			if err != nil {
+				t.Fatalf("CreateRoom: %v", err)
+			}
+
+			conn := &testConn{}
+			client := NewClient(conn, m, room, RoleStudent)
+
+			var sent []bool
+			for _, eventType := range []string{"first", "second", "third", "fourth"} {
+				sent = append(sent, client.send(Event{Type: eventType}))
+			}
+
+			var queued []string
+			for _, event := range received(client) {
+				queued = append(queued, event.Type)
+			}
+
+			if !reflect.DeepEqual(queued, tt.wantQueued) {
+				t.Errorf("queued = %v, want %v", queued, tt.wantQueued)
+			}
+			if !reflect.DeepEqual(sent, tt.wantSent) {
+				t.Errorf("sent = %v, want %v", sent, tt.wantSent)
+			}
+			if stats := m.EgressStats(); stats != tt.wantStats {
+				t.Errorf("stats = %+v, want %+v", stats, tt.wantStats)
+			}
+			if dropped := room.dropped.Load(); dropped != tt.wantStats.Dropped {
+				t.Errorf("room dropped %d, want %d", dropped, tt.wantStats.Dropped)
+			}
+			if conn.closed.Load() != tt.wantEvicted || client.evicted.Load() != tt.wantEvicted {
+				t.Errorf("closed = %v, evicted = %v, want %v", conn.closed.Load(), client.evicted.Load(), tt.wantEvicted)
+			}
+		})
+	}
+}
+
+func TestSendAfterEvictionDrops(t *testing.T) {
+	cfg := testConfig()
+	cfg.EgressQueueSize = 1
+
+	m := newTestManagerConfig(t, cfg)
+
+	client := NewClient(&testConn{}, m, nil, RoleStudent)
+	client.evict()
+	client.evict()
+
+	if client.send(Event{Type: "late"}) {
+		t.Fatal("an evicted client got an event queued")
+	}
+
+	if stats := m.EgressStats(); stats != (EgressStats{Dropped: 1, Evicted: 1}) {
+		t.Errorf("stats = %+v, want one drop and one eviction", stats)
+	}
+}
@@ -3,11 +3,26 @@ package ws
 import (
 	"encoding/json"
 	"time"
+
+	"mnemo/services/poll"
+	"mnemo/services/quiz"
+	"mnemo/services/srs"
 )
 
 type Event struct {
+	// Version is the envelope's protocol version; clients may omit it
+	Version int `json:"version"`
+
+	// ID identifies the event; inbound IDs are echoed back as the
+	// correlation_id of errors the event caused
+	ID string `json:"id,omitempty"`
+
 	Type    string          `json:"type"`
 	Payload json.RawMessage `json:"payload"`
+
+	// Seq is the event's position in the room's event stream; 0 for
+	// events that are not replayable
+	Seq uint64 `json:"seq,omitempty"`
 }
 
 type EventHandler func(event Event, c *Client) error
@@ -15,8 +30,82 @@ type EventHandler func(event Event, c *Client) error
 const (
 	EventSendMessage = "send_message"
 	EventNewMessage  = "new_message"
+
+	EventJoinRoom          = "join_room"
+	EventRoomJoined        = "room_joined"
+	EventJoinRejected      = "join_rejected"
+	EventParticipantJoined = "participant_joined"
+	EventParticipantLeft   = "participant_left"
+
+	EventPublishQuestion   = "publish_question"
+	EventQuestionPublished = "question_published"
+	EventAnswerSubmitted   = "answer_submitted"
+	EventAnswerAccepted    = "answer_accepted"
+	EventAnswerRejected    = "answer_rejected"
+	EventQuestionResults   = "question_results"
+	EventCloseQuestion     = "close_question"
+	EventQuestionClosed    = "question_closed"
+	EventTimerTick         = "timer_tick"
+	EventNextQuestion      = "next_question"
+
+	EventLeaderboardUpdated = "leaderboard_updated"
+
+	EventAck              = "ack"
+	EventReplayIncomplete = "replay_incomplete"
+
+	EventError = "error"
+
+	EventStreamOpened = "stream_opened"
+
+	EventServerShuttingDown = "server_shutting_down"
+
+	EventAskQuestion          = "ask_question"
+	EventQuestionAsked        = "question_asked"
+	EventUpvoteQuestion       = "upvote_question"
+	EventModerateQuestion     = "moderate_question"
+	EventBoardQuestionUpdated = "board_question_updated"
+	EventBoardUpdated         = "board_updated"
+
+	EventStartPoll            = "start_poll"
+	EventPollStarted          = "poll_started"
+	EventPollResponse         = "poll_response"
+	EventPollResponseAccepted = "poll_response_accepted"
+	EventPollResults          = "poll_results"
+	EventClosePoll            = "close_poll"
+	EventPollClosed           = "poll_closed"
+
+	EventReact       = "react"
+	EventMoodUpdated = "mood_updated"
+
+	EventStartReview    = "start_review"
+	EventReviewQuestion = "review_question"
+	EventReviewAnswer   = "review_answer"
+	EventReviewResult   = "review_result"
+	EventReviewRejected = "review_rejected"
+	EventReviewFinished = "review_finished"
 )
 
+// ErrorEvent reports a rejected inbound event. CorrelationID is the ID of the
+// event that caused it, if the client set one.
+type ErrorEvent struct {
+	Code          string `json:"code"`
+	Message       string `json:"message"`
+	CorrelationID string `json:"correlation_id,omitempty"`
+}
+
+// StreamOpenedEvent is the first event on an event stream. The client posts
+// its own events with the session ID.
+type StreamOpenedEvent struct {
+	Session string `json:"session"`
+}
+
+// ServerShuttingDownEvent is the last event before the server closes the
+// connection. Clients wait ReconnectAfterMs before reconnecting with their
+// token, so they don't all hit the remaining instances at once.
+type ServerShuttingDownEvent struct {
+	ReconnectAfterMs int64 `json:"reconnect_after_ms"`
+}
+
 type SendMessageEvent struct {
 	Message string `json:"message"`
 	From    string `json:"from"`
@@ -26,3 +115,257 @@ type NewMessageEvent struct {
 	SendMessageEvent
 	Sent time.Time `json:"sent"`
 }
+
+type JoinRoomEvent struct {
+	Name  string `json:"name,omitempty"`
+	Token string `json:"token,omitempty"` // resume an earlier session
+
+	// StudentToken links a new participant to the student's identity from
+	// earlier rooms; without it a new student is created
+	StudentToken string `json:"student_token,omitempty"`
+
+	// LastSeq is the last sequence number seen before reconnecting; events
+	// after it are replayed. Without it the last acked one is used.
+	LastSeq *uint64 `json:"last_seq,omitempty"`
+}
+
+type RoomJoinedEvent struct {
+	Room          string `json:"room"`
+	ParticipantID string `json:"participant_id"`
+	StudentID     string `json:"student_id,omitempty"`
+	Name          string `json:"name"`
+	Token         string `json:"token"`
+	StudentToken  string `json:"student_token,omitempty"` // only when a new student was created
+	Score         int    `json:"score"`
+	Streak        int    `json:"streak"`
+	Resumed       bool   `json:"resumed"`
+	Seq           uint64 `json:"seq"` // the room's latest sequence number
+
+	// Question is the question currently open for answers, if any
+	Question *quiz.Question `json:"question,omitempty"`
+	Deadline *time.Time     `json:"deadline,omitempty"`
+	Answers  []*quiz.Answer `json:"answers"`
+
+	// Board is the Q&A board as the student sees it
+	Board []BoardQuestion `json:"board"`
+
+	// Poll is the poll currently open, if any, with the student's response
+	// to it if they gave one
+	Poll         *poll.Poll     `json:"poll,omitempty"`
+	PollResponse *poll.Response `json:"poll_response,omitempty"`
+}
+
+type AckEvent struct {
+	Seq uint64 `json:"seq"`
+}
+
+// ReplayIncompleteEvent tells a reconnecting client that events after
+// LastSeq are gone, so it has to rebuild its state (room_joined for students,
+// the rooms API for professors).
+type ReplayIncompleteEvent struct {
+	LastSeq uint64 `json:"last_seq"`
+	Seq     uint64 `json:"seq"`
+}
+
+type JoinRejectedEvent struct {
+	Reason string `json:"reason"`
+}
+
+type ParticipantEvent struct {
+	ParticipantID string `json:"participant_id"`
+	Name          string `json:"name"`
+}
+
+type PublishQuestionEvent struct {
+	quiz.Question
+
+	// DurationSecs limits how long answers are accepted; 0 means the
+	// question stays open until the professor closes it
+	DurationSecs int `json:"duration_secs,omitempty"`
+}
+
+type QuestionPublishedEvent struct {
+	Question    *quiz.Question `json:"question"`
+	PublishedAt time.Time      `json:"published_at"`
+	Deadline    *time.Time     `json:"deadline,omitempty"`
+
+	// Position and Total are set when stepping through a question bank
+	Position int `json:"position,omitempty"`
+	Total    int `json:"total,omitempty"`
+}
+
+type AnswerSubmittedEvent struct {
+	QuestionID string   `json:"question_id"`
+	Choices    []string `json:"choices"`
+}
+
+type AnswerAcceptedEvent struct {
+	Answer *quiz.Answer `json:"answer"`
+}
+
+type AnswerRejectedEvent struct {
+	QuestionID string `json:"question_id"`
+	Reason     string `json:"reason"`
+}
+
+type QuestionResultsEvent struct {
+	Results quiz.Results `json:"results"`
+}
+
+type CloseQuestionEvent struct {
+	QuestionID string `json:"question_id"`
+}
+
+type QuestionClosedEvent struct {
+	QuestionID  string       `json:"question_id"`
+	Correct     []string     `json:"correct"`
+	Explanation string       `json:"explanation,omitempty"`
+	Results     quiz.Results `json:"results"`
+}
+
+type TimerTickEvent struct {
+	QuestionID  string    `json:"question_id"`
+	RemainingMs int64     `json:"remaining_ms"`
+	Deadline    time.Time `json:"deadline"`
+}
+
+type LeaderboardUpdatedEvent struct {
+	QuestionID string             `json:"question_id"`
+	Entries    []LeaderboardEntry `json:"entries"`
+
+	// Own is the receiving student's entry; not set for professors
+	Own *LeaderboardEntry `json:"own,omitempty"`
+}
+
+type AskQuestionEvent struct {
+	Text      string `json:"text"`
+	Anonymous bool   `json:"anonymous,omitempty"`
+}
+
+type UpvoteQuestionEvent struct {
+	QuestionID string `json:"question_id"`
+	Retract    bool   `json:"retract,omitempty"`
+}
+
+type ModerateQuestionEvent struct {
+	QuestionID string `json:"question_id"`
+	Action     string `json:"action"`
+}
+
+// BoardQuestion is a question on the Q&A board as one client sees it.
+// Students don't see who asked anonymous questions; professors always do.
+type BoardQuestion struct {
+	ID        string    `json:"id"`
+	Text      string    `json:"text"`
+	Anonymous bool      `json:"anonymous"`
+	Author    string    `json:"author,omitempty"`
+	AuthorID  string    `json:"author_id,omitempty"`
+	Status    string    `json:"status"`
+	Pinned    bool      `json:"pinned"`
+	Upvotes   int       `json:"upvotes"`
+	AskedAt   time.Time `json:"asked_at"`
+
+	// Mine and Upvoted are only set in views for the student they concern
+	Mine    bool `json:"mine,omitempty"`
+	Upvoted bool `json:"upvoted,omitempty"`
+}
+
+// QuestionAskedEvent confirms a question to the student who asked it.
+type QuestionAskedEvent struct {
+	Question BoardQuestion `json:"question"`
+}
+
+// BoardQuestionUpdatedEvent tells students about a new or changed question.
+// Dismissed questions are sent once more so clients can drop them.
+type BoardQuestionUpdatedEvent struct {
+	Question BoardQuestion `json:"question"`
+}
+
+// BoardUpdatedEvent is the professor's sorted board, without dismissed
+// questions.
+type BoardUpdatedEvent struct {
+	Questions []BoardQuestion `json:"questions"`
+}
+
+type StartPollEvent struct {
+	poll.Poll
+}
+
+type PollStartedEvent struct {
+	Poll      *poll.Poll `json:"poll"`
+	StartedAt time.Time  `json:"started_at"`
+}
+
+// PollResponseEvent answers a poll: choice polls take Choices, word clouds
+// Text. Responding again replaces the earlier response.
+type PollResponseEvent struct {
+	PollID  string   `json:"poll_id"`
+	Choices []string `json:"choices,omitempty"`
+	Text    string   `json:"text,omitempty"`
+}
+
+type PollResponseAcceptedEvent struct {
+	Response *poll.Response `json:"response"`
+}
+
+// PollResultsEvent is pushed to the professor after every response.
+type PollResultsEvent struct {
+	Results poll.Results `json:"results"`
+}
+
+type ClosePollEvent struct {
+	PollID string `json:"poll_id"`
+}
+
+// PollClosedEvent goes to everybody; polls are ungraded, so students see the
+// results too.
+type PollClosedEvent struct {
+	PollID  string       `json:"poll_id"`
+	Results poll.Results `json:"results"`
+}
+
+// ReactEvent is a student's reaction to the lecture; Emoji is only set for
+// emoji reactions.
+type ReactEvent struct {
+	Reaction string `json:"reaction"`
+	Emoji    string `json:"emoji,omitempty"`
+}
+
+// MoodUpdatedEvent is the room's mood over the last WindowSecs. Counts are of
+// students, not reactions: a student reacting twice the same way counts once,
+// and only with their latest emoji.
+type MoodUpdatedEvent struct {
+	WindowSecs int            `json:"window_secs"`
+	Students   int            `json:"students"` // connected right now
+	Reactions  map[string]int `json:"reactions"`
+	Emoji      map[string]int `json:"emoji"`
+
+	// Confusion is the share of connected students that are confused, in
+	// percent to one decimal
+	Confusion float64 `json:"confusion"`
+}
+
+type StartReviewEvent struct {
+	// Limit caps the number of questions in this session; it cannot exceed
+	// the server's review session size
+	Limit int `json:"limit,omitempty"`
+}
+
+type ReviewQuestionEvent struct {
+	Question  *quiz.Question `json:"question"`
+	DueAt     time.Time      `json:"due_at"`
+	Remaining int            `json:"remaining"` // including this question
+}
+
+type ReviewResultEvent struct {
+	QuestionID  string   `json:"question_id"`
+	Correct     bool     `json:"correct"`
+	Answer      []string `json:"answer"` // the correct option IDs
+	Explanation string   `json:"explanation,omitempty"`
+	Card        srs.Card `json:"schedule"`
+}
+
+type ReviewFinishedEvent struct {
+	Reviewed int `json:"reviewed"`
+	Correct  int `json:"correct"`
+}
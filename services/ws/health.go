@@ -0,0 +1,43 @@
+package ws
+
+import (
+	"runtime"
+
+	"github.com/pkg/errors"
+)
+
+// ManagerHealth is reported by the manager's health check.
+type ManagerHealth struct {
+	Goroutines int `json:"goroutines"`
+	Clients    int `json:"clients"`
+
+	// Queued is the number of events waiting in the clients' egress queues,
+	// out of a total Capacity
+	Queued   int `json:"queued"`
+	Capacity int `json:"capacity"`
+}
+
+// Status satisfies the go-health.ICheckable interface. It fails when the
+// process runs more goroutines than configured, or when the egress queues of
+// the connected clients are, all together, close to full: either way the hub
+// no longer keeps up with its clients.
+func (m *Manager) Status() (interface{}, error) {
+	health := ManagerHealth{Goroutines: runtime.NumGoroutine()}
+
+	for _, stats := range m.ClientStats() {
+		health.Clients += stats.Clients
+		health.Queued += stats.Queued
+	}
+
+	health.Capacity = health.Clients * m.config.EgressQueueSize
+
+	if limit := m.config.HealthMaxGoroutines; limit > 0 && health.Goroutines > limit {
+		return health, errors.Errorf("%d goroutines running, limit is %d", health.Goroutines, limit)
+	}
+
+	if health.Capacity > 0 && float64(health.Queued) >= m.config.HealthMaxQueueFill*float64(health.Capacity) {
+		return health, errors.Errorf("egress queues are %d%% full", health.Queued*100/health.Capacity)
+	}
+
+	return health, nil
+}
@@ -0,0 +1,69 @@
+package ws
+
+import (
+	"context"
+	"testing"
+)
+
+func TestManagerStatus(t *testing.T) {
+	tests := []struct {
+		name          string
+		maxGoroutines int
+		clients       int
+		queued        int // events queued for the first client
+
+		wantQueued int
+		wantErr    bool
+	}{
+		{name: "no clients"},
+		{name: "idle clients", clients: 2},
+		{name: "queues below the limit", clients: 2, queued: 9, wantQueued: 9},
+		{name: "queues at the limit", clients: 2, queued: 10, wantQueued: 10, wantErr: true},
+		{name: "too many goroutines", maxGoroutines: 1, wantErr: true},
+		{name: "goroutine check disabled", maxGoroutines: 0, clients: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig()
+			cfg.EgressQueueSize = 10
+			cfg.EgressPolicy = EgressDropNewest
+			cfg.HealthMaxGoroutines = tt.maxGoroutines
+			cfg.HealthMaxQueueFill = 0.5
+
+			m := newTestManagerConfig(t, cfg)
+
+			room, err := m.CreateRoom(context.Background(), testProfessor, "")
+			if err != nil {
+				t.Fatalf("CreateRoom: %v", err)
+			}
+
+			var clients []*Client
+			for i := 0; i < tt.clients; i++ {
+				client := connect(t, m, room, RoleStudent)
+				received(client)
+				clients = append(clients, client)
+			}
+
+			for i := 0; i < tt.queued; i++ {
+				clients[0].send(Event{Type: "backlog"})
+			}
+
+			status, err := m.Status()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Status = %v, want error %v", err, tt.wantErr)
+			}
+
+			health, ok := status.(ManagerHealth)
+			if !ok {
+				t.Fatalf("status is a %T", status)
+			}
+			if health.Clients != tt.clients || health.Queued != tt.wantQueued || health.Capacity != tt.clients*cfg.EgressQueueSize {
+				t.Errorf("health = %+v", health)
+			}
+			if health.Goroutines == 0 {
+				t.Error("goroutines not reported")
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package ws
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"go.uber.org/zap"
+
+	"mnemo/services/quiz"
+	"mnemo/storage"
+)
+
+type LeaderboardEntry struct {
+	Rank          int    `json:"rank"`
+	ParticipantID string `json:"participant_id"`
+	Name          string `json:"name"`
+	Score         int    `json:"score"`
+	Streak        int    `json:"streak"`
+}
+
+// scoreRound awards points for a closed round. Participants that answered
+// wrong or not at all lose their streak. Answers to bank questions also
+// reschedule the student's spaced-repetition review of that question. The
+// results are saved after the room is unlocked, in one transaction.
+func (r *Room) scoreRound(round *quiz.Round) {
+	results := &storage.RoundResults{}
+	var reviews []*storage.ReviewLog
+
+	r.sync.Lock()
+	source := r.sources[round.Question.ID]
+
+	for _, p := range r.participants {
+		answer, ok := round.Answer(p.ID)
+
+		if ok && source != "" && p.StudentID != "" {
+			reviews = append(reviews, &storage.ReviewLog{
+				StudentID:      p.StudentID,
+				BankQuestionID: source,
+				RoomID:         r.ID,
+				Correct:        answer.Correct,
+				ResponseTime:   answer.ResponseTime,
+				ReviewedAt:     answer.SubmittedAt,
+			})
+		}
+
+		if !ok || !answer.Correct {
+			p.Streak = 0
+		} else {
+			p.Streak++
+			answer.Points = r.options.Scoring.Points(answer, round.Duration(), p.Streak)
+			p.Score += answer.Points
+
+			results.Answers = append(results.Answers, &storage.Answer{Answer: *answer, RoomID: r.ID})
+		}
+
+		results.Participants = append(results.Participants, r.storedParticipant(p))
+	}
+	r.sync.Unlock()
+
+	window := round.Duration()
+	if window <= 0 {
+		window = r.options.Scoring.SpeedWindow
+	}
+
+	for _, entry := range reviews {
+		review, err := scheduleReview(r.store, entry, window)
+		if err != nil {
+			r.log.Error("unable to schedule review", zap.String("student", entry.StudentID), zap.Error(err))
+			continue
+		}
+
+		results.Reviews = append(results.Reviews, &storage.ReviewUpdate{Review: review, Entry: entry})
+	}
+
+	if err := r.store.SaveRoundResults(context.Background(), results); err != nil {
+		r.log.Error("unable to save round results", zap.String("question", round.Question.ID), zap.Error(err))
+	}
+}
+
+// leaderboard ranks all participants by score. Participants with equal
+// scores share a rank. The caller must hold the room lock.
+func (r *Room) leaderboard() []LeaderboardEntry {
+	entries := make([]LeaderboardEntry, 0, len(r.participants))
+	for _, p := range r.participants {
+		entries = append(entries, LeaderboardEntry{
+			ParticipantID: p.ID,
+			Name:          p.Name,
+			Score:         p.Score,
+			Streak:        p.Streak,
+		})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Score != entries[j].Score {
+			return entries[i].Score > entries[j].Score
+		}
+		return strings.ToLower(entries[i].Name) < strings.ToLower(entries[j].Name)
+	})
+
+	for i := range entries {
+		if i > 0 && entries[i].Score == entries[i-1].Score {
+			entries[i].Rank = entries[i-1].Rank
+		} else {
+			entries[i].Rank = i + 1
+		}
+	}
+
+	return entries
+}
+
+// publishLeaderboard sends the full leaderboard to professors and, if
+// enabled, the top N plus their own entry to every student.
+func (r *Room) publishLeaderboard(questionID string) {
+	r.sync.RLock()
+	entries := r.leaderboard()
+	r.sync.RUnlock()
+
+	if err := r.notifyProfessors(EventLeaderboardUpdated, LeaderboardUpdatedEvent{
+		QuestionID: questionID,
+		Entries:    entries,
+	}); err != nil {
+		r.log.Error("unable to send leaderboard", zap.Error(err))
+	}
+
+	if !r.options.LeaderboardToStudents {
+		return
+	}
+
+	top := entries[:min(len(entries), r.options.LeaderboardTopN)]
+
+	byParticipant := make(map[string]LeaderboardEntry, len(entries))
+	for _, entry := range entries {
+		byParticipant[entry.ParticipantID] = entry
+	}
+
+	r.sync.RLock()
+	defer r.sync.RUnlock()
+
+	for client := range r.clients {
+		if client.role != RoleStudent || client.participant == nil {
+			continue
+		}
+
+		own := byParticipant[client.participant.ID]
+
+		if err := client.sendEvent(EventLeaderboardUpdated, LeaderboardUpdatedEvent{
+			QuestionID: questionID,
+			Entries:    top,
+			Own:        &own,
+		}); err != nil {
+			client.log.Error("unable to send leaderboard", zap.Error(err))
+		}
+	}
+}
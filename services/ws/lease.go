@@ -0,0 +1,248 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"mnemo/storage"
+)
+
+// Which instance hosts a room is recorded in a lease in the broker. The host
+// renews it while it runs; once an instance dies its leases expire and the
+// next request for one of its rooms adopts the room: the instance serving the
+// request takes the lease and restores the room from storage. Adopting needs
+// the instances to share their storage.
+
+var ErrRoomNotFound = errors.New("room not found")
+
+// errHostedElsewhere is returned when another instance took the lease first.
+var errHostedElsewhere = errors.New("room is hosted by another instance")
+
+// RoomHost is the lease record of the instance hosting a room.
+type RoomHost struct {
+	Instance  string `json:"instance"`
+	Professor string `json:"professor"`
+
+	// Addr is the host's API address, for requests only the host can serve
+	Addr string `json:"addr"`
+}
+
+func leaseKey(roomID string) string {
+	return "mnemo:host:" + roomID
+}
+
+// hostRecord is what this instance holds the lease on a room with. It has to
+// stay the same for as long as the lease is held.
+func (m *Manager) hostRecord(roomID, professor string) string {
+	m.relay.sync.Lock()
+	addr := m.relay.addr
+	m.relay.sync.Unlock()
+
+	data, _ := json.Marshal(RoomHost{Instance: m.instance, Professor: professor, Addr: addr})
+	return string(data)
+}
+
+// lease acquires the lease on the room, or renews it. It returns false if
+// another instance holds it.
+func (m *Manager) lease(ctx context.Context, roomID, professor string) (bool, error) {
+	return m.broker.Acquire(ctx, leaseKey(roomID), m.hostRecord(roomID, professor), m.config.RoomLeaseTTL)
+}
+
+func (m *Manager) releaseLease(ctx context.Context, roomID, professor string) {
+	if err := m.broker.Release(ctx, leaseKey(roomID), m.hostRecord(roomID, professor)); err != nil {
+		m.log.Error("unable to release room lease", zap.String("room", roomID), zap.Error(err))
+	}
+}
+
+// roomHost returns the instance holding the lease on the room, or nil if
+// nobody does.
+func (m *Manager) roomHost(ctx context.Context, roomID string) (*RoomHost, error) {
+	record, err := m.broker.Holder(ctx, leaseKey(roomID))
+	if err != nil || record == "" {
+		return nil, err
+	}
+
+	var host RoomHost
+	if err := json.Unmarshal([]byte(record), &host); err != nil {
+		return nil, errors.Wrapf(err, "invalid lease on room %s", roomID)
+	}
+
+	return &host, nil
+}
+
+// LocateRoom finds the instance hosting the room with the given join code.
+// Rooms hosted here are returned as is, for rooms hosted elsewhere the host
+// is returned. A room nobody hosts, e.g. because its host died, is adopted.
+// It returns ErrRoomNotFound if there is no open room with that code.
+func (m *Manager) LocateRoom(ctx context.Context, id string) (*Room, *RoomHost, error) {
+	id = NormalizeRoomCode(id)
+
+	if room, ok := m.GetRoom(id); ok {
+		return room, nil, nil
+	}
+
+	host, err := m.roomHost(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if host != nil && host.Instance != m.instance {
+		return nil, host, nil
+	}
+
+	stored, err := m.store.GetRoom(ctx, id)
+	if errors.Is(err, storage.ErrNotFound) || (err == nil && stored.ClosedAt != nil) {
+		return nil, nil, ErrRoomNotFound
+	}
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "unable to look up room %s", id)
+	}
+
+	room, err := m.adopt(ctx, stored)
+	if errors.Is(err, errHostedElsewhere) {
+		// another instance adopted it first
+		if host, err = m.roomHost(ctx, id); err == nil && host == nil {
+			err = ErrRoomNotHosted
+		}
+		return nil, host, err
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return room, nil, nil
+}
+
+// adopt takes the lease on a stored room and hosts it here. It returns
+// errHostedElsewhere if another instance holds the lease.
+func (m *Manager) adopt(ctx context.Context, stored *storage.Room) (*Room, error) {
+	m.adopting.Lock()
+	defer m.adopting.Unlock()
+
+	if room, ok := m.GetRoom(stored.ID); ok {
+		return room, nil
+	}
+
+	ok, err := m.lease(ctx, stored.ID, stored.Professor)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to lease room %s", stored.ID)
+	}
+	if !ok {
+		return nil, errHostedElsewhere
+	}
+
+	room, err := m.restoreRoom(ctx, stored)
+	if err != nil {
+		m.releaseLease(ctx, stored.ID, stored.Professor)
+		return nil, errors.Wrapf(err, "unable to restore room %s", stored.ID)
+	}
+
+	m.sync.Lock()
+	m.rooms[room.ID] = room
+	m.sync.Unlock()
+
+	if err := m.subscribe(room); err != nil {
+		m.abandon(room)
+		m.releaseLease(ctx, room.ID, room.Professor)
+		return nil, err
+	}
+
+	if err := room.resumeTimer(m.config.QuestionTickInterval); err != nil {
+		m.abandon(room)
+		m.releaseLease(ctx, room.ID, room.Professor)
+		return nil, errors.Wrapf(err, "unable to resume question in room %s", room.ID)
+	}
+
+	room.log.Info("room adopted")
+
+	return room, nil
+}
+
+// holdLeases renews the leases on the hosted rooms until ctx is done. Rooms
+// whose lease went to another instance, e.g. after this one lost its broker
+// for too long, are given up. Local clients of rooms whose host changed are
+// sent away, so they reconnect to the new one.
+func (m *Manager) holdLeases(ctx context.Context) {
+	ticker := time.NewTicker(m.config.RoomLeaseTTL / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		for _, room := range m.Rooms() {
+			ok, err := m.lease(ctx, room.ID, room.Professor)
+			if err != nil {
+				room.log.Error("unable to renew room lease", zap.Error(err))
+				continue
+			}
+
+			if !ok {
+				room.log.Warn("room lease taken by another instance, giving up the room")
+				m.abandon(room)
+			}
+		}
+
+		m.checkUpstreams(ctx)
+	}
+}
+
+// abandon stops hosting the room without closing it, sending its clients
+// away.
+func (m *Manager) abandon(room *Room) {
+	m.sync.Lock()
+	if m.rooms[room.ID] != room {
+		m.sync.Unlock()
+		return
+	}
+	delete(m.rooms, room.ID)
+	m.sync.Unlock()
+
+	close(room.done)
+	m.unsubscribe(room.ID)
+
+	room.sync.RLock()
+	clients := make([]*Client, 0, len(room.clients))
+	for client := range room.clients {
+		clients = append(clients, client)
+	}
+	room.sync.RUnlock()
+
+	for _, client := range clients {
+		m.sendAway(client)
+	}
+}
+
+// checkUpstreams sends away the local clients of rooms that are no longer
+// hosted where they were connected to.
+func (m *Manager) checkUpstreams(ctx context.Context) {
+	m.relay.sync.Lock()
+	rooms := make(map[string][]*Client)
+	for _, client := range m.relay.upstreams {
+		rooms[client.upstream.roomID] = append(rooms[client.upstream.roomID], client)
+	}
+	m.relay.sync.Unlock()
+
+	for roomID, clients := range rooms {
+		host, err := m.roomHost(ctx, roomID)
+		if err != nil {
+			m.log.Error("unable to look up room host", zap.String("room", roomID), zap.Error(err))
+			continue
+		}
+
+		for _, client := range clients {
+			if host != nil && host.Instance == client.upstream.host {
+				continue
+			}
+
+			client.log.Info("room host gone, sending client away", zap.String("room", roomID))
+			m.sendAway(client)
+		}
+	}
+}
@@ -1,141 +1,497 @@
 package ws
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"github.com/gorilla/websocket"
 	"github.com/pkg/errors"
-	"log"
+	"go.uber.org/zap"
+	"mnemo/clog"
+	"mnemo/config"
+	"mnemo/services/auth"
+	"mnemo/services/broker"
+	"mnemo/services/quiz"
+	"mnemo/storage"
 	"net/http"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
-// note: hack for now (this is stupid)
-const professorAPIKey = "my_secret_key"
+var ErrEmptyBank = errors.New("question bank has no questions")
 
-var upgrader = websocket.Upgrader{
-	ReadBufferSize:  1024,
-	WriteBufferSize: 1024,
-	//CheckOrigin: checkOrigin
+const instanceIDBytes = 8
+
+// Authenticator resolves an instructor session token; it is satisfied by
+// *auth.Service.
+type Authenticator interface {
+	Authenticate(token string) (*auth.Instructor, error)
 }
 
 type Manager struct {
-	clients  ClientList
-	sync     sync.RWMutex
-	handlers map[string]EventHandler
+	rooms          RoomList
+	sync           sync.RWMutex
+	handlers       map[string]EventHandler
+	reviewHandlers map[string]EventHandler
+	auth           Authenticator
+	store          storage.Repository
+	config         *config.Config
+
+	// streams are the open event stream sessions, keyed by session ID
+	streams map[string]*eventStream
+
+	// broker connects hosted rooms with clients connected to other
+	// instances; instance identifies this one
+	broker   broker.Broker
+	instance string
+	relay    *relayState
+
+	// adopting serializes adopting rooms from storage
+	adopting sync.Mutex
+
+	observer Observer
+
+	// drainer knows the local clients to send away on shutdown
+	drainer *drainState
+
+	log       clog.ICustomLog
+	clientIDs atomic.Uint64
+
+	egress egressCounters
 }
 
-func NewManager() *Manager {
+func NewManager(cfg *config.Config, logger clog.ICustomLog, authenticator Authenticator, store storage.Repository, bus broker.Broker) (*Manager, error) {
+	instance, err := newToken(instanceIDBytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to generate instance id")
+	}
+
 	m := &Manager{
-		clients:  make(ClientList),
-		handlers: make(map[string]EventHandler),
+		rooms:          make(RoomList),
+		handlers:       make(map[string]EventHandler),
+		reviewHandlers: make(map[string]EventHandler),
+		streams:        make(map[string]*eventStream),
+		broker:         bus,
+		instance:       instance,
+		relay:          newRelayState(),
+		drainer:        newDrainState(),
+		observer:       nopObserver{},
+		log:            logger.With(zap.String("pkg", "ws"), zap.String("instance", instance)),
+		auth:           authenticator,
+		store:          store,
+		config:         cfg,
 	}
 
 	m.setupEventHandlers()
-	return m
+	return m, nil
+}
+
+// EgressStats reports how many outgoing events were queued and dropped over
+// all clients.
+func (m *Manager) EgressStats() EgressStats {
+	return m.egress.snapshot()
 }
 
 func (m *Manager) setupEventHandlers() {
 	m.handlers[EventSendMessage] = SendMessage
+	m.handlers[EventJoinRoom] = JoinRoom
+	m.handlers[EventPublishQuestion] = PublishQuestion
+	m.handlers[EventAnswerSubmitted] = SubmitAnswer
+	m.handlers[EventCloseQuestion] = CloseQuestion
+	m.handlers[EventNextQuestion] = NextQuestion
+	m.handlers[EventAck] = Ack
+	m.handlers[EventAskQuestion] = AskQuestion
+	m.handlers[EventUpvoteQuestion] = UpvoteQuestion
+	m.handlers[EventModerateQuestion] = ModerateQuestion
+	m.handlers[EventStartPoll] = StartPoll
+	m.handlers[EventPollResponse] = RespondPoll
+	m.handlers[EventClosePoll] = ClosePoll
+	m.handlers[EventReact] = React
+
+	m.reviewHandlers[EventStartReview] = StartReview
+	m.reviewHandlers[EventReviewAnswer] = ReviewAnswer
 }
 
 func SendMessage(event Event, c *Client) error {
 	var chatEvent SendMessageEvent
 
 	if err := json.Unmarshal(event.Payload, &chatEvent); err != nil {
-		return fmt.Errorf("bad payload in request: %v", err)
+		return badPayload(err)
 	}
 
 	var broadcastMessage NewMessageEvent
 	broadcastMessage.Sent = time.Now()
 	broadcastMessage.Message = chatEvent.Message
-	broadcastMessage.From = chatEvent.From
+	// never trust the sender to say who they are
+	broadcastMessage.From = c.displayName()
 
-	data, err := json.Marshal(broadcastMessage)
-	if err != nil {
-		return fmt.Errorf("failed to marshal broadcast message: %v", err)
-	}
-
-	outgoingEvent := Event{
-		Payload: data,
-		Type:    EventNewMessage,
+	// professors stream to students, students only talk back to professors
+	switch c.role {
+	case RoleProfessor:
+		return c.room.notifyStudents(EventNewMessage, broadcastMessage)
+	case RoleStudent:
+		return c.room.notifyProfessors(EventNewMessage, broadcastMessage)
+	default:
+		return fmt.Errorf("unknown client role: %s", c.role)
 	}
+}
 
-	// acquire read lock to safely iterate over the clients map.
-	c.manager.sync.RLock()
-	defer c.manager.sync.RUnlock()
-
-	if c.role == RoleProfessor {
-		// case 1: professor should be able to stream questions to students
-		for client := range c.manager.clients {
-			if client.role == RoleStudent {
-				sendWithRetry(client.egress, outgoingEvent)
-			}
-		}
-	} else if c.role == RoleStudent {
-		// students should only stream back responses to professor
-		for client := range c.manager.clients {
-			if client.role == RoleProfessor {
-				sendWithRetry(client.egress, outgoingEvent)
-			}
+func (m *Manager) routeEvent(event Event, c *Client) error {
+	// review connections have no room and only speak the review events
+	if c.review != nil {
+		handler, ok := m.reviewHandlers[event.Type]
+		if !ok {
+			return newProtocolError(CodeUnknownEvent, "unknown review event type '%s'", event.Type)
 		}
-	} else {
-		return fmt.Errorf("unknown client role: %s", c.role)
+
+		m.observer.EventRouted(event.Type)
+		return handler(event, c)
 	}
 
-	return nil
-}
+	// students have to complete the join handshake before anything else
+	if c.role == RoleStudent && c.participant == nil && event.Type != EventJoinRoom {
+		return newProtocolError(CodeNotJoined, "join the room first")
+	}
 
-func (m *Manager) routeEvent(event Event, c *Client) error {
 	// check if the event type is part of the handlers
 	if handler, ok := m.handlers[event.Type]; ok {
+		m.observer.EventRouted(event.Type)
+
 		if err := handler(event, c); err != nil {
 			return err
 		}
 
 		return nil
 	} else {
-		return errors.New("there is no such event type")
+		return newProtocolError(CodeUnknownEvent, "unknown event type '%s'", event.Type)
 	}
 }
 
+// ServeWs upgrades the request and joins the client to the room given by the
+// "room" query parameter, e.g. /ws?room=lecture-1
 func (m *Manager) ServeWs(w http.ResponseWriter, r *http.Request) {
+	req, ok := m.roomRequest(w, r)
+	if !ok {
+		return
+	}
+
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		log.Println(err)
+		// the upgrader already wrote the error response
+		m.log.Debug("websocket upgrade failed", zap.Error(err))
 		return
 	}
 
-	// Check for x-api-key header. If it matches, assign professor role.
-	apiKey := r.Header.Get("x-api-key")
-	role := RoleStudent
-	if apiKey == professorAPIKey {
-		role = RoleProfessor
+	client := NewClient(conn, m, req.room, req.role)
+	client.name = req.name
+
+	if err := m.connect(client, req); err != nil {
+		client.log.Error("unable to connect client", zap.String("room", req.roomID), zap.Error(err))
+		conn.Close()
+		return
 	}
 
-	client := NewClient(conn, m, role)
+	go client.readMessages(conn)
+	go client.writeMessages(conn)
+}
 
-	m.addClient(client)
-	fmt.Printf("%s connected\n", client.role)
+// connect adds the client to its room, or relays it to the instance hosting
+// the room.
+func (m *Manager) connect(client *Client, req *roomConnection) error {
+	if req.room == nil {
+		if err := m.relayClient(client, req); err != nil {
+			return err
+		}
+
+		client.log.Info("client connected", zap.String("room", req.roomID), zap.Bool("relayed", true))
+		return nil
+	}
+
+	if err := m.addClient(client, req.lastSeq); err != nil {
+		client.log.Error("unable to replay events", zap.Error(err))
+	}
+
+	// students get the board and the open poll with room_joined
+	if client.role == RoleProfessor {
+		req.room.sendBoard(client)
+		req.room.sendPoll(client)
+		req.room.sendMood(client)
+	}
 
-	go client.readMessages()
-	go client.writeMessages()
+	client.log.Info("client connected")
+
+	return nil
 }
 
-func (m *Manager) addClient(client *Client) {
+// roomConnection is who a request to connect to a room comes from.
+type roomConnection struct {
+	room    *Room     // nil if the room is hosted by another instance
+	host    *RoomHost // set if the room is hosted by another instance
+	roomID  string
+	role    string
+	name    string  // display name of a professor
+	lastSeq *uint64 // set if a professor resumes its event stream
+}
+
+// roomRequest resolves the room given by the "room" query parameter and the
+// role the client joins it as. On failure it writes the error response and
+// returns false.
+func (m *Manager) roomRequest(w http.ResponseWriter, r *http.Request) (*roomConnection, bool) {
+	if m.refuseDraining(w) {
+		return nil, false
+	}
+
+	roomID := r.URL.Query().Get("room")
+	if roomID == "" {
+		http.Error(w, "missing room", http.StatusBadRequest)
+		return nil, false
+	}
+
+	req := &roomConnection{roomID: NormalizeRoomCode(roomID), role: RoleStudent}
+
+	room, host, err := m.LocateRoom(r.Context(), req.roomID)
+	switch {
+	case errors.Is(err, ErrRoomNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return nil, false
+	case errors.Is(err, ErrRoomNotHosted):
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return nil, false
+	case err != nil:
+		m.log.Error("unable to locate room", zap.String("room", req.roomID), zap.Error(err))
+		http.Error(w, "unable to locate room", http.StatusInternalServerError)
+		return nil, false
+	}
+
+	req.room = room
+	req.host = host
+
+	professor := ""
+	if room != nil {
+		professor = room.Professor
+	} else {
+		professor = host.Professor
+	}
+
+	// Clients presenting an instructor session token join as professor, but
+	// only in rooms that instructor owns. Everybody else is a student.
+	if token := auth.TokenFromRequest(r); token != "" {
+		instructor, err := m.auth.Authenticate(token)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return nil, false
+		}
+
+		if instructor.ID != professor {
+			http.Error(w, "room belongs to another instructor", http.StatusForbidden)
+			return nil, false
+		}
+
+		req.role = RoleProfessor
+		req.name = instructor.Name
+	}
+
+	// professors resume their event stream here; students do it when they
+	// join with their token. Event streams that reconnect on their own send
+	// the last sequence number they saw as Last-Event-ID.
+	v := r.URL.Query().Get("last_seq")
+	if v == "" {
+		v = r.Header.Get("Last-Event-ID")
+	}
+
+	if v != "" && req.role == RoleProfessor {
+		seq, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			http.Error(w, "invalid last_seq", http.StatusBadRequest)
+			return nil, false
+		}
+		req.lastSeq = &seq
+	}
+
+	return req, true
+}
+
+// CreateRoom registers a new room owned by the given professor under a
+// freshly generated join code. If bankID is set the room steps through that
+// bank's questions; the caller is responsible for checking the professor may
+// use the bank.
+func (m *Manager) CreateRoom(ctx context.Context, professor, bankID string) (*Room, error) {
+	var playlist []*storage.BankQuestion
+
+	if bankID != "" {
+		var err error
+		if playlist, err = m.store.ListBankQuestions(ctx, bankID); err != nil {
+			return nil, errors.Wrap(err, "unable to load bank questions")
+		}
+
+		if len(playlist) == 0 {
+			return nil, ErrEmptyBank
+		}
+	}
+
+	room, err := m.registerRoom(ctx, professor, bankID, playlist)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := m.host(room); err != nil {
+		m.RemoveRoom(room.ID)
+		return nil, err
+	}
+
+	return room, nil
+}
+
+func (m *Manager) registerRoom(ctx context.Context, professor, bankID string, playlist []*storage.BankQuestion) (*Room, error) {
 	m.sync.Lock()
 	defer m.sync.Unlock()
 
-	m.clients[client] = true
+	for i := 0; i < maxRoomCodeAttempts; i++ {
+		code, err := newRoomCode()
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to generate room code")
+		}
+
+		if _, ok := m.rooms[code]; ok {
+			continue
+		}
+
+		room := NewRoom(code, professor, m.roomOptions(), m.store, m.log)
+		room.bankID = bankID
+		room.playlist = playlist
+
+		// codes of closed rooms stay taken so their data remains addressable
+		err = m.store.CreateRoom(ctx, &storage.Room{
+			ID:        room.ID,
+			Professor: room.Professor,
+			CreatedAt: room.CreatedAt,
+			BankID:    bankID,
+		})
+		if errors.Is(err, storage.ErrConflict) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		m.rooms[code] = room
+
+		return room, nil
+	}
+
+	return nil, errors.New("unable to find a free room code")
 }
 
-func (m *Manager) removeClient(client *Client) {
+func (m *Manager) roomOptions() RoomOptions {
+	return RoomOptions{
+		Scoring: quiz.Scoring{
+			BasePoints:          m.config.ScoreBasePoints,
+			SpeedBonus:          m.config.ScoreSpeedBonus,
+			SpeedWindow:         m.config.ScoreSpeedWindow,
+			StreakBonus:         m.config.ScoreStreakBonus,
+			MaxStreakMultiplier: m.config.ScoreMaxStreakMultiplier,
+		},
+		LeaderboardTopN:       m.config.LeaderboardTopN,
+		LeaderboardToStudents: m.config.LeaderboardToStudents,
+		ReplayBufferSize:      m.config.ReplayBufferSize,
+		WordCloudTerms:        m.config.WordCloudTerms,
+		MoodWindow:            m.config.MoodWindow,
+		MoodUpdateInterval:    m.config.MoodUpdateInterval,
+		MoodReactionRate:      m.config.MoodReactionRate,
+	}
+}
+
+// GetRoom looks up a room by its join code. Codes are case-insensitive so
+// students can type them however they like.
+func (m *Manager) GetRoom(id string) (*Room, bool) {
+	m.sync.RLock()
+	defer m.sync.RUnlock()
+
+	room, ok := m.rooms[NormalizeRoomCode(id)]
+	return room, ok
+}
+
+// Rooms returns a snapshot of all open rooms.
+func (m *Manager) Rooms() []*Room {
+	m.sync.RLock()
+	defer m.sync.RUnlock()
+
+	rooms := make([]*Room, 0, len(m.rooms))
+	for _, room := range m.rooms {
+		rooms = append(rooms, room)
+	}
+
+	return rooms
+}
+
+// RemoveRoom unregisters the room and disconnects all of its clients.
+func (m *Manager) RemoveRoom(id string) bool {
+	id = NormalizeRoomCode(id)
+
 	m.sync.Lock()
-	defer m.sync.Unlock()
+	room, ok := m.rooms[id]
+	delete(m.rooms, id)
+	m.sync.Unlock()
+
+	if !ok {
+		return false
+	}
+
+	close(room.done)
+	m.unhost(room)
+
+	if err := m.store.CloseRoom(context.Background(), room.ID, time.Now()); err != nil {
+		room.log.Error("unable to close room in storage", zap.Error(err))
+	}
+
+	room.sync.Lock()
+	defer room.sync.Unlock()
 
-	if _, ok := m.clients[client]; ok {
+	for client := range room.clients {
 		client.connection.Close()
-		delete(m.clients, client)
+		delete(room.clients, client)
 	}
+
+	return true
+}
+
+func (m *Manager) addClient(client *Client, lastSeq *uint64) error {
+	return client.room.addClient(client, lastSeq)
+}
+
+// removeClient cleans up after a client's connection ended. Transports may
+// call it more than once.
+func (m *Manager) removeClient(client *Client) {
+	if !client.removed.CompareAndSwap(false, true) {
+		return
+	}
+
+	client.log.Info("client disconnected")
+	m.untrack(client)
+
+	if client.upstream != nil {
+		m.detachUpstream(client)
+	}
+
+	if client.room == nil {
+		client.connection.Close()
+		return
+	}
+
+	if !client.room.removeClient(client) {
+		return
+	}
+
+	client.connection.Close()
+
+	if p := client.participant; p != nil {
+		if err := client.room.notifyProfessors(EventParticipantLeft, ParticipantEvent{ParticipantID: p.ID, Name: p.Name}); err != nil {
+			client.log.Error("unable to notify professors", zap.Error(err))
+		}
+	}
+}
+
+func (m *Manager) newClientID() string {
+	return m.instance + "-" + strconv.FormatUint(m.clientIDs.Add(1), 36)
 }
@@ -0,0 +1,298 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+
+	"mnemo/clog"
+	"mnemo/config"
+	"mnemo/services/auth"
+	"mnemo/services/broker"
+	"mnemo/storage"
+)
+
+const testProfessor = "prof@example.com"
+
+// testConfig is the server's default configuration.
+func testConfig() *config.Config {
+	return &config.Config{
+		RoomLeaseTTL:             10 * time.Second,
+		EgressQueueSize:          256,
+		EgressPolicy:             "evict",
+		ReplayBufferSize:         512,
+		ShutdownReconnectWindow:  5 * time.Second,
+		QuestionTickInterval:     time.Second,
+		ScoreBasePoints:          500,
+		ScoreSpeedBonus:          500,
+		ScoreSpeedWindow:         30 * time.Second,
+		ScoreStreakBonus:         0.1,
+		ScoreMaxStreakMultiplier: 2,
+		LeaderboardTopN:          5,
+		LeaderboardToStudents:    true,
+		WordCloudTerms:           50,
+		MoodWindow:               60 * time.Second,
+		MoodUpdateInterval:       2 * time.Second,
+		MoodReactionRate:         5,
+		ReviewSessionSize:        20,
+	}
+}
+
+// testAuthenticator accepts the tokens it was given.
+type testAuthenticator map[string]*auth.Instructor
+
+func (a testAuthenticator) Authenticate(token string) (*auth.Instructor, error) {
+	instructor, ok := a[token]
+	if !ok {
+		return nil, auth.ErrInvalidToken
+	}
+
+	return instructor, nil
+}
+
+// newTestManager starts a manager on a fresh SQLite database and an
+// in-memory broker. The token "prof-token" authenticates testProfessor,
+// "other-token" another instructor.
+func newTestManager(t *testing.T) *Manager {
+	t.Helper()
+
+	return newTestManagerConfig(t, testConfig())
+}
+
+// newTestManagerConfig is newTestManager with the given configuration.
+func newTestManagerConfig(t *testing.T, cfg *config.Config) *Manager {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	store, err := storage.NewSQLite(ctx, filepath.Join(t.TempDir(), "mnemo.db"))
+	if err != nil {
+		t.Fatalf("NewSQLite: %v", err)
+	}
+
+	authenticator := testAuthenticator{
+		"prof-token":  {ID: testProfessor, Email: testProfessor, Name: "Prof"},
+		"other-token": {ID: "other@example.com", Email: "other@example.com"},
+	}
+
+	m, err := NewManager(cfg, clog.New(zap.NewNop()), authenticator, store, broker.NewMemory())
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+
+	if err := m.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	t.Cleanup(func() {
+		cancel()
+
+		// test clients have no transport loops that would disconnect them,
+		// so Stop must not wait for them
+		stopped, stop := context.WithCancel(context.Background())
+		stop()
+		m.Stop(stopped)
+
+		store.Close()
+	})
+
+	return m
+}
+
+// testConn is a transport that only records whether it was closed.
+type testConn struct {
+	closed atomic.Bool
+}
+
+func (c *testConn) Close() error {
+	c.closed.Store(true)
+	return nil
+}
+
+// connect adds a client with the given role to the room.
+func connect(t *testing.T, m *Manager, room *Room, role string) *Client {
+	t.Helper()
+
+	client := NewClient(&testConn{}, m, room, role)
+	if err := m.addClient(client, nil); err != nil {
+		t.Fatalf("addClient: %v", err)
+	}
+
+	return client
+}
+
+// send handles an event from the client as its transport would.
+func send(t *testing.T, c *Client, eventType string, payload any) error {
+	t.Helper()
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatalf("marshal %s: %v", eventType, err)
+	}
+
+	return c.manager.routeEvent(Event{Version: ProtocolVersion, Type: eventType, Payload: data}, c)
+}
+
+// received drains the events queued for the client.
+func received(c *Client) []Event {
+	var events []Event
+	for {
+		select {
+		case event := <-c.egress:
+			events = append(events, event)
+		default:
+			return events
+		}
+	}
+}
+
+// lastEvent decodes the payload of the last queued event of the given type.
+func lastEvent(t *testing.T, events []Event, eventType string, payload any) bool {
+	t.Helper()
+
+	for i := len(events) - 1; i >= 0; i-- {
+		if events[i].Type != eventType {
+			continue
+		}
+
+		if err := json.Unmarshal(events[i].Payload, payload); err != nil {
+			t.Fatalf("decode %s: %v", eventType, err)
+		}
+		return true
+	}
+
+	return false
+}
+
+func TestRoomRequestTokens(t *testing.T) {
+	m := newTestManager(t)
+
+	room, err := m.CreateRoom(context.Background(), testProfessor, "")
+	if err != nil {
+		t.Fatalf("CreateRoom: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		query    string
+		header   http.Header
+		cookie   string
+		wantRole string
+		wantCode int // set if the request is rejected
+	}{
+		{
+			name:     "no token joins as student",
+			wantRole: RoleStudent,
+		},
+		{
+			name:     "bearer token",
+			header:   http.Header{"Authorization": {"Bearer prof-token"}},
+			wantRole: RoleProfessor,
+		},
+		{
+			name:     "session cookie",
+			cookie:   "prof-token",
+			wantRole: RoleProfessor,
+		},
+		{
+			name:     "session query parameter",
+			query:    "&session=prof-token",
+			wantRole: RoleProfessor,
+		},
+		{
+			name:     "student token header is no instructor token",
+			header:   http.Header{StudentTokenHeader: {"student-token"}},
+			wantRole: RoleStudent,
+		},
+		{
+			name:     "student token query parameter is no instructor token",
+			query:    "&student_token=student-token",
+			wantRole: RoleStudent,
+		},
+		{
+			name:     "token query parameter is no instructor token",
+			query:    "&token=student-token",
+			wantRole: RoleStudent,
+		},
+		{
+			name:     "invalid session token",
+			header:   http.Header{"Authorization": {"Bearer forged"}},
+			wantCode: http.StatusUnauthorized,
+		},
+		{
+			name:     "room of another instructor",
+			cookie:   "other-token",
+			wantCode: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/ws?room="+room.ID+tt.query, nil)
+			for key, values := range tt.header {
+				r.Header[key] = values
+			}
+			if tt.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: auth.SessionCookie, Value: tt.cookie})
+			}
+
+			w := httptest.NewRecorder()
+			req, ok := m.roomRequest(w, r)
+
+			if tt.wantCode != 0 {
+				if ok || w.Code != tt.wantCode {
+					t.Fatalf("roomRequest = %v with status %d, want status %d", ok, w.Code, tt.wantCode)
+				}
+				return
+			}
+
+			if !ok {
+				t.Fatalf("roomRequest failed with status %d: %s", w.Code, w.Body)
+			}
+			if req.role != tt.wantRole {
+				t.Errorf("role = %s, want %s", req.role, tt.wantRole)
+			}
+		})
+	}
+}
+
+func TestStudentTokenFromRequest(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		header http.Header
+		cookie string
+		want   string
+	}{
+		{"none", "/", nil, "", ""},
+		{"header", "/", http.Header{StudentTokenHeader: {" abc "}}, "", "abc"},
+		{"query parameter", "/?student_token=abc", nil, "", "abc"},
+		{"header wins", "/?student_token=query", http.Header{StudentTokenHeader: {"header"}}, "", "header"},
+		{"bearer token is an instructor token", "/", http.Header{"Authorization": {"Bearer abc"}}, "", ""},
+		{"session cookie is an instructor token", "/", nil, "abc", ""},
+		{"session query parameter is an instructor token", "/?session=abc", nil, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			for key, values := range tt.header {
+				r.Header[key] = values
+			}
+			if tt.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: auth.SessionCookie, Value: tt.cookie})
+			}
+
+			if got := StudentTokenFromRequest(r); got != tt.want {
+				t.Errorf("StudentTokenFromRequest = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
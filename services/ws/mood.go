@@ -0,0 +1,260 @@
+package ws
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"go.uber.org/zap"
+)
+
+// Reactions a student can send about the lecture.
+const (
+	ReactionConfused = "confused"
+	ReactionGotIt    = "got_it"
+	ReactionSlowDown = "slow_down"
+	ReactionEmoji    = "emoji"
+)
+
+// reactionKey is a student's reaction of one kind. A student's later
+// reaction replaces their earlier one of the same kind.
+type reactionKey struct {
+	participantID string
+	kind          string
+}
+
+// reaction is the latest reaction of its kind in the room's sliding window.
+type reaction struct {
+	at    time.Time
+	emoji string // only for ReactionEmoji
+}
+
+// reactionLimit counts a student's reactions in the second starting at start.
+type reactionLimit struct {
+	start time.Time
+	count int
+}
+
+// moodState collects the room's recent reactions. Reactions are never
+// stored: the mood is only meaningful while the lecture is happening.
+type moodState struct {
+	// reactions holds at most one reaction per student and kind, so it
+	// stays bounded however often students react
+	reactions map[reactionKey]reaction
+
+	// limits rate limits the students' reactions
+	limits map[string]*reactionLimit
+
+	// running is set while runMood pushes updates to the professors
+	running bool
+
+	sync sync.Mutex
+}
+
+func newMoodState() *moodState {
+	return &moodState{
+		reactions: make(map[reactionKey]reaction),
+		limits:    make(map[string]*reactionLimit),
+	}
+}
+
+// allow counts a reaction of the student and reports whether it stays within
+// rate reactions per second. The caller must hold the mood lock.
+func (m *moodState) allow(participantID string, now time.Time, rate int) bool {
+	limit, ok := m.limits[participantID]
+	if !ok || now.Sub(limit.start) >= time.Second {
+		m.limits[participantID] = &reactionLimit{start: now, count: 1}
+		return true
+	}
+
+	if limit.count >= rate {
+		return false
+	}
+
+	limit.count++
+	return true
+}
+
+// React records a student's reaction. Reactions are not relayed one by one:
+// the professor gets them aggregated over a sliding window, see runMood.
+func React(event Event, c *Client) error {
+	if c.role != RoleStudent {
+		return newProtocolError(CodeForbidden, "only students can react")
+	}
+
+	var reactEvent ReactEvent
+	if err := json.Unmarshal(event.Payload, &reactEvent); err != nil {
+		return badPayload(err)
+	}
+
+	switch reactEvent.Reaction {
+	case ReactionConfused, ReactionGotIt, ReactionSlowDown:
+		if reactEvent.Emoji != "" {
+			return newProtocolError(CodeInvalidPayload, "only emoji reactions take an emoji")
+		}
+	case ReactionEmoji:
+		if !isEmoji(reactEvent.Emoji) {
+			return newProtocolError(CodeInvalidPayload, "'%s' is not an emoji", reactEvent.Emoji)
+		}
+	default:
+		return newProtocolError(CodeInvalidPayload, "unknown reaction '%s'", reactEvent.Reaction)
+	}
+
+	room := c.room
+	mood := room.mood
+	now := time.Now()
+
+	mood.sync.Lock()
+	if !mood.allow(c.participant.ID, now, room.options.MoodReactionRate) {
+		mood.sync.Unlock()
+		return newProtocolError(CodeRateLimited, "at most %d reactions per second", room.options.MoodReactionRate)
+	}
+
+	mood.reactions[reactionKey{participantID: c.participant.ID, kind: reactEvent.Reaction}] = reaction{
+		at:    now,
+		emoji: reactEvent.Emoji,
+	}
+
+	start := !mood.running
+	mood.running = true
+	mood.sync.Unlock()
+
+	if start {
+		go room.runMood(room.options.MoodUpdateInterval, room.options.MoodWindow)
+	}
+
+	return nil
+}
+
+// isEmoji accepts short strings of symbols, so emoji reactions can't be
+// used to send text.
+func isEmoji(s string) bool {
+	if len(s) > 32 || !strings.ContainsFunc(s, func(r rune) bool { return unicode.Is(unicode.So, r) }) {
+		return false
+	}
+
+	return !strings.ContainsFunc(s, func(r rune) bool {
+		return unicode.IsLetter(r) || unicode.IsNumber(r) || unicode.IsSpace(r) || unicode.IsPunct(r)
+	})
+}
+
+// runMood pushes the room's mood to the professors right away and then at
+// most once per interval, whenever it changed. It returns once the window
+// holds no more reactions, after telling the professors so, or when the
+// room goes away.
+func (r *Room) runMood(interval, window time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var last *MoodUpdatedEvent
+
+	for {
+		mood, idle := r.moodSnapshot(time.Now(), window)
+
+		if last == nil || !reflect.DeepEqual(*last, mood) {
+			if err := r.notifyProfessors(EventMoodUpdated, mood); err != nil {
+				r.log.Error("unable to send mood", zap.Error(err))
+			}
+			last = &mood
+		}
+
+		if idle && r.stopMood() {
+			return
+		}
+
+		select {
+		case <-r.done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// moodSnapshot drops the reactions that left the window and aggregates the
+// rest. It reports idle once there are none left.
+func (r *Room) moodSnapshot(now time.Time, window time.Duration) (MoodUpdatedEvent, bool) {
+	r.sync.RLock()
+	students := 0
+	for _, p := range r.participants {
+		if p.Connected() {
+			students++
+		}
+	}
+	r.sync.RUnlock()
+
+	mood := r.mood
+
+	mood.sync.Lock()
+	defer mood.sync.Unlock()
+
+	cutoff := now.Add(-window)
+	for key, reaction := range mood.reactions {
+		if !reaction.at.After(cutoff) {
+			delete(mood.reactions, key)
+		}
+	}
+
+	for participantID, limit := range mood.limits {
+		if now.Sub(limit.start) >= time.Second {
+			delete(mood.limits, participantID)
+		}
+	}
+
+	event := MoodUpdatedEvent{
+		WindowSecs: int(window.Seconds()),
+		Students:   students,
+		Reactions:  map[string]int{ReactionConfused: 0, ReactionGotIt: 0, ReactionSlowDown: 0},
+		Emoji:      make(map[string]int),
+	}
+
+	for key, reaction := range mood.reactions {
+		if key.kind == ReactionEmoji {
+			event.Emoji[reaction.emoji]++
+		} else {
+			event.Reactions[key.kind]++
+		}
+	}
+
+	if students > 0 {
+		event.Confusion = math.Min(100, math.Round(float64(event.Reactions[ReactionConfused])*1000/float64(students))/10)
+	}
+
+	return event, len(mood.reactions) == 0
+}
+
+// stopMood marks runMood as stopped unless a reaction came in since the last
+// snapshot, in which case it has to keep going.
+func (r *Room) stopMood() bool {
+	r.mood.sync.Lock()
+	defer r.mood.sync.Unlock()
+
+	if len(r.mood.reactions) > 0 {
+		return false
+	}
+
+	r.mood.running = false
+
+	return true
+}
+
+// sendMood sends the current mood to a professor that just connected, if
+// students reacted recently.
+func (r *Room) sendMood(client *Client) {
+	r.mood.sync.Lock()
+	running := r.mood.running
+	r.mood.sync.Unlock()
+
+	if !running {
+		return
+	}
+
+	mood, _ := r.moodSnapshot(time.Now(), r.options.MoodWindow)
+
+	if err := client.sendEvent(EventMoodUpdated, mood); err != nil {
+		client.log.Error("unable to send mood", zap.Error(err))
+	}
+}
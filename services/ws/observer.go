@@ -0,0 +1,91 @@
+package ws
+
+import "time"
+
+// Answer modes reported to the Observer.
+const (
+	AnswerLive   = "live"
+	AnswerReview = "review"
+)
+
+// Observer is told what the manager does, e.g. to export metrics. Calls
+// happen on the hot path and must not block.
+type Observer interface {
+	// EventRouted is called for every inbound event handed to its handler.
+	EventRouted(eventType string)
+
+	// EventFailed is called for every inbound event rejected with an error
+	// event. eventType is empty if the message could not be decoded.
+	EventFailed(eventType, code string)
+
+	// AnswerSubmitted is called for every accepted answer, live or in a
+	// self-paced review.
+	AnswerSubmitted(mode string, correct bool, responseTime time.Duration)
+}
+
+type nopObserver struct{}
+
+func (nopObserver) EventRouted(string)                          {}
+func (nopObserver) EventFailed(string, string)                  {}
+func (nopObserver) AnswerSubmitted(string, bool, time.Duration) {}
+
+// SetObserver installs the observer. It must be called before Start.
+func (m *Manager) SetObserver(observer Observer) {
+	m.observer = observer
+}
+
+// ClientStats describes the clients of one role connected to a room through
+// this instance.
+type ClientStats struct {
+	Room    string
+	Role    string
+	Clients int
+
+	// Queued is the number of events waiting in the clients' egress queues
+	Queued int
+}
+
+// ClientStats reports the clients connected to this instance by room and
+// role. Clients of rooms hosted elsewhere are included under their room;
+// clients relayed here from other instances are left to those instances, so
+// summing over instances counts every client once. Self-paced review clients
+// are not in a room and not included.
+func (m *Manager) ClientStats() []ClientStats {
+	type key struct{ room, role string }
+	counts := make(map[key]*ClientStats)
+
+	add := func(room string, c *Client) {
+		k := key{room, c.role}
+		stats, ok := counts[k]
+		if !ok {
+			stats = &ClientStats{Room: room, Role: c.role}
+			counts[k] = stats
+		}
+
+		stats.Clients++
+		stats.Queued += len(c.egress)
+	}
+
+	for _, room := range m.Rooms() {
+		room.sync.RLock()
+		for c := range room.clients {
+			if _, remote := c.connection.(*remoteConn); !remote {
+				add(room.ID, c)
+			}
+		}
+		room.sync.RUnlock()
+	}
+
+	m.relay.sync.Lock()
+	for _, c := range m.relay.upstreams {
+		add(c.upstream.roomID, c)
+	}
+	m.relay.sync.Unlock()
+
+	stats := make([]ClientStats, 0, len(counts))
+	for _, s := range counts {
+		stats = append(stats, *s)
+	}
+
+	return stats
+}
@@ -0,0 +1,248 @@
+package ws
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+
+	"mnemo/services/quiz"
+)
+
+const (
+	maxNameLength = 32
+
+	participantIDBytes    = 8
+	participantTokenBytes = 32
+)
+
+var (
+	ErrNameTaken    = errors.New("name is already taken in this room")
+	ErrInvalidName  = errors.New("name must be between 1 and 32 characters")
+	ErrUnknownToken = errors.New("unknown participant token")
+)
+
+// joinResult is the outcome of a successful join.
+type joinResult struct {
+	participant *Participant
+	token       string
+	resumed     bool
+
+	// studentToken is only set when the join created a new student
+	studentToken string
+}
+
+// Participant is a student's identity within a room. It outlives the
+// websocket connection, so a student reconnecting with their token gets back
+// the same identity and score instead of starting over.
+type Participant struct {
+	ID        string
+	StudentID string // student identity spanning rooms
+	Name      string
+	Score     int
+	Streak    int // consecutive correct answers
+	JoinedAt  time.Time
+
+	// Answers holds every answer the participant gave, keyed by question ID
+	Answers map[string]*quiz.Answer
+
+	tokenHash string
+	client    *Client // current connection, nil while disconnected
+	acked     uint64  // highest sequence number the student acknowledged
+}
+
+// Connected reports whether the participant currently has a live connection.
+func (p *Participant) Connected() bool {
+	return p.client != nil
+}
+
+// join registers a new participant under the given display name, or resumes
+// the participant owning the request's token. The returned tokens are only
+// known to the student. The caller must hold the room's write lock. While a
+// new participant is saved the lock is released, with its name reserved.
+func (r *Room) join(client *Client, req JoinRoomEvent) (*joinResult, error) {
+	if req.Token != "" {
+		p, ok := r.tokens[hashToken(req.Token)]
+		if !ok {
+			return nil, ErrUnknownToken
+		}
+
+		// the student may be reconnecting before the old connection noticed
+		// it was gone; the newest connection wins
+		if p.client != nil && p.client != client {
+			delete(r.clients, p.client)
+			p.client.connection.Close()
+		}
+
+		p.client = client
+		return &joinResult{participant: p, token: req.Token, resumed: true}, nil
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" || utf8.RuneCountInString(name) > maxNameLength {
+		return nil, ErrInvalidName
+	}
+
+	key := strings.ToLower(name)
+	if _, ok := r.names[key]; ok {
+		return nil, ErrNameTaken
+	}
+
+	r.names[key] = nil
+	r.sync.Unlock()
+
+	result, err := r.newParticipant(client, name, req.StudentToken)
+
+	r.sync.Lock()
+	if err != nil {
+		delete(r.names, key)
+		return nil, err
+	}
+
+	// the client may have disconnected while the lock was released
+	if _, ok := r.clients[client]; !ok {
+		result.participant.client = nil
+	}
+
+	r.addParticipant(result.participant)
+
+	return result, nil
+}
+
+// newParticipant creates and saves a participant linked to the student owning
+// the student token, or to a freshly created student. It does not touch the
+// room state, so the room lock need not be held.
+func (r *Room) newParticipant(client *Client, name, studentToken string) (*joinResult, error) {
+	student, newStudentToken, err := r.resolveStudent(name, studentToken)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := newToken(participantIDBytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to generate participant id")
+	}
+
+	token, err := newToken(participantTokenBytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to generate participant token")
+	}
+
+	p := &Participant{
+		ID:        id,
+		StudentID: student.ID,
+		Name:      name,
+		JoinedAt:  time.Now(),
+		Answers:   make(map[string]*quiz.Answer),
+		tokenHash: hashToken(token),
+		client:    client,
+	}
+
+	if err := r.saveParticipant(p); err != nil {
+		return nil, err
+	}
+
+	return &joinResult{participant: p, token: token, studentToken: newStudentToken}, nil
+}
+
+// addParticipant indexes the participant. The caller must hold the room's
+// write lock.
+func (r *Room) addParticipant(p *Participant) {
+	r.participants[p.ID] = p
+	r.tokens[p.tokenHash] = p
+	r.names[strings.ToLower(p.Name)] = p
+}
+
+// JoinRoom is the student handshake: a student either picks a display name or
+// presents the token from an earlier session to resume it. Until this
+// succeeds the student cannot send any other event.
+func JoinRoom(event Event, c *Client) error {
+	if c.role != RoleStudent {
+		return newProtocolError(CodeForbidden, "only students can join a room")
+	}
+
+	var joinEvent JoinRoomEvent
+	if err := json.Unmarshal(event.Payload, &joinEvent); err != nil {
+		return badPayload(err)
+	}
+
+	room := c.room
+
+	room.sync.Lock()
+	if c.participant != nil {
+		room.sync.Unlock()
+		return newProtocolError(CodeInvalidState, "already joined")
+	}
+
+	result, err := room.join(c, joinEvent)
+	if err != nil {
+		room.sync.Unlock()
+
+		if errors.Is(err, ErrNameTaken) || errors.Is(err, ErrInvalidName) || errors.Is(err, ErrUnknownToken) || errors.Is(err, ErrUnknownStudent) {
+			return c.sendEvent(EventJoinRejected, JoinRejectedEvent{Reason: err.Error()})
+		}
+		return err
+	}
+
+	p := result.participant
+
+	joined := RoomJoinedEvent{
+		Room:          room.ID,
+		ParticipantID: p.ID,
+		StudentID:     p.StudentID,
+		Name:          p.Name,
+		Token:         result.token,
+		StudentToken:  result.studentToken,
+		Score:         p.Score,
+		Streak:        p.Streak,
+		Resumed:       result.resumed,
+		Seq:           room.currentSeq(),
+		Answers:       make([]*quiz.Answer, 0, len(p.Answers)),
+		Board:         room.studentBoard(p.ID),
+	}
+
+	if joined.Question = room.openQuestion(); joined.Question != nil {
+		joined.Deadline = roundDeadline(room.current)
+	}
+
+	if tally := room.openPoll(); tally != nil {
+		joined.Poll = tally.Poll
+		joined.PollResponse, _ = tally.Response(p.ID)
+	}
+
+	for _, answer := range p.Answers {
+		joined.Answers = append(joined.Answers, answer)
+	}
+
+	// room_joined goes out before the client counts as joined, so it is not
+	// sequenced. The replay happens under the room lock, so no live event can
+	// overtake it.
+	if err := c.sendEvent(EventRoomJoined, joined); err != nil {
+		room.sync.Unlock()
+		return err
+	}
+
+	c.participant = p
+
+	if result.resumed {
+		lastSeq := p.acked
+		if joinEvent.LastSeq != nil {
+			lastSeq = *joinEvent.LastSeq
+		}
+
+		if joinEvent.LastSeq != nil || lastSeq > 0 {
+			if err := room.replay(c, lastSeq); err != nil {
+				room.sync.Unlock()
+				return err
+			}
+		}
+	}
+	room.sync.Unlock()
+
+	return room.notifyProfessors(EventParticipantJoined, ParticipantEvent{
+		ParticipantID: joined.ParticipantID,
+		Name:          joined.Name,
+	})
+}
@@ -0,0 +1,204 @@
+package ws
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+
+	"mnemo/storage"
+)
+
+func TestJoinRoom(t *testing.T) {
+	tests := []struct {
+		name string
+		// setup runs before the join and returns its request
+		setup func(t *testing.T, m *Manager, room *Room) JoinRoomEvent
+
+		wantJoined bool
+		wantName   string // reserved after the join
+		wantFree   string // not reserved after the join
+		resumed    bool
+	}{
+		{
+			name: "new name",
+			setup: func(t *testing.T, m *Manager, room *Room) JoinRoomEvent {
+				return JoinRoomEvent{Name: " Ada "}
+			},
+			wantJoined: true,
+			wantName:   "ada",
+		},
+		{
+			name: "name taken ignoring case",
+			setup: func(t *testing.T, m *Manager, room *Room) JoinRoomEvent {
+				join(t, m, room, "Ada")
+				return JoinRoomEvent{Name: "ADA"}
+			},
+			wantName: "ada",
+		},
+		{
+			name: "name reserved by a join in progress",
+			setup: func(t *testing.T, m *Manager, room *Room) JoinRoomEvent {
+				room.sync.Lock()
+				room.names["bob"] = nil
+				room.sync.Unlock()
+
+				return JoinRoomEvent{Name: "Bob"}
+			},
+			wantName: "bob",
+		},
+		{
+			name: "empty name",
+			setup: func(t *testing.T, m *Manager, room *Room) JoinRoomEvent {
+				return JoinRoomEvent{Name: "  "}
+			},
+		},
+		{
+			name: "failed join releases the name",
+			setup: func(t *testing.T, m *Manager, room *Room) JoinRoomEvent {
+				return JoinRoomEvent{Name: "Carol", StudentToken: "unknown"}
+			},
+			wantFree: "carol",
+		},
+		{
+			name: "resume with token",
+			setup: func(t *testing.T, m *Manager, room *Room) JoinRoomEvent {
+				joined := join(t, m, room, "Dave")
+				return JoinRoomEvent{Token: joined.Token}
+			},
+			wantJoined: true,
+			wantName:   "dave",
+			resumed:    true,
+		},
+		{
+			name: "unknown token",
+			setup: func(t *testing.T, m *Manager, room *Room) JoinRoomEvent {
+				return JoinRoomEvent{Token: "unknown"}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newTestManager(t)
+
+			room, err := m.CreateRoom(context.Background(), testProfessor, "")
+			if err != nil {
+				t.Fatalf("CreateRoom: %v", err)
+			}
+
+			req := tt.setup(t, m, room)
+
+			client := connect(t, m, room, RoleStudent)
+			if err := send(t, client, EventJoinRoom, req); err != nil {
+				t.Fatalf("join_room: %v", err)
+			}
+
+			events := received(client)
+
+			var joined RoomJoinedEvent
+			if ok := lastEvent(t, events, EventRoomJoined, &joined); ok != tt.wantJoined {
+				t.Fatalf("joined = %v, want %v: %+v", ok, tt.wantJoined, events)
+			}
+
+			var rejected JoinRejectedEvent
+			if ok := lastEvent(t, events, EventJoinRejected, &rejected); ok == tt.wantJoined {
+				t.Fatalf("rejected = %v, want %v", ok, !tt.wantJoined)
+			}
+
+			if tt.wantJoined {
+				if joined.Resumed != tt.resumed {
+					t.Errorf("resumed = %v, want %v", joined.Resumed, tt.resumed)
+				}
+				if client.participant == nil || client.participant.client != client {
+					t.Errorf("client is not linked to its participant")
+				}
+				if !tt.resumed && (joined.Token == "" || joined.StudentToken == "") {
+					t.Errorf("new participant without tokens: %+v", joined)
+				}
+			}
+
+			room.sync.RLock()
+			defer room.sync.RUnlock()
+
+			if tt.wantName != "" {
+				if _, ok := room.names[tt.wantName]; !ok {
+					t.Errorf("name %q is not reserved", tt.wantName)
+				}
+			}
+			if tt.wantFree != "" {
+				if _, ok := room.names[tt.wantFree]; ok {
+					t.Errorf("name %q is still reserved", tt.wantFree)
+				}
+			}
+		})
+	}
+}
+
+// lockProbeStore records whether the room was locked while something was
+// saved.
+type lockProbeStore struct {
+	storage.Repository
+	room   *Room
+	locked atomic.Bool
+}
+
+func (s *lockProbeStore) probe() {
+	if s.room.sync.TryLock() {
+		s.room.sync.Unlock()
+	} else {
+		s.locked.Store(true)
+	}
+}
+
+func (s *lockProbeStore) SaveParticipant(ctx context.Context, p *storage.Participant) error {
+	s.probe()
+	return s.Repository.SaveParticipant(ctx, p)
+}
+
+func (s *lockProbeStore) CreateStudent(ctx context.Context, student *storage.Student) error {
+	s.probe()
+	return s.Repository.CreateStudent(ctx, student)
+}
+
+func TestJoinSavesOutsideRoomLock(t *testing.T) {
+	m := newTestManager(t)
+
+	room, err := m.CreateRoom(context.Background(), testProfessor, "")
+	if err != nil {
+		t.Fatalf("CreateRoom: %v", err)
+	}
+
+	probe := &lockProbeStore{Repository: room.store, room: room}
+	room.store = probe
+
+	join(t, m, room, "Ada")
+
+	if probe.locked.Load() {
+		t.Fatal("participant was saved while the room was locked")
+	}
+
+	participants, err := m.store.ListParticipants(context.Background(), room.ID)
+	if err != nil {
+		t.Fatalf("ListParticipants: %v", err)
+	}
+	if len(participants) != 1 || participants[0].Name != "Ada" {
+		t.Fatalf("stored participants = %+v", participants)
+	}
+}
+
+// join connects a new student to the room under the given name.
+func join(t *testing.T, m *Manager, room *Room, name string) RoomJoinedEvent {
+	t.Helper()
+
+	client := connect(t, m, room, RoleStudent)
+	if err := send(t, client, EventJoinRoom, JoinRoomEvent{Name: name}); err != nil {
+		t.Fatalf("join_room: %v", err)
+	}
+
+	var joined RoomJoinedEvent
+	if !lastEvent(t, received(client), EventRoomJoined, &joined) {
+		t.Fatalf("%s did not join", name)
+	}
+
+	return joined
+}
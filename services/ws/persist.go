@@ -0,0 +1,269 @@
+package ws
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"mnemo/services/poll"
+	"mnemo/services/quiz"
+	"mnemo/storage"
+)
+
+// hashToken is how participant tokens are indexed in memory and stored, so a
+// leaked database does not allow hijacking participants.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func (r *Room) saveParticipant(p *Participant) error {
+	return r.store.SaveParticipant(context.Background(), r.storedParticipant(p))
+}
+
+// storedParticipant copies the participant for storage. The caller must hold
+// the room lock.
+func (r *Room) storedParticipant(p *Participant) *storage.Participant {
+	return &storage.Participant{
+		ID:        p.ID,
+		RoomID:    r.ID,
+		StudentID: p.StudentID,
+		Name:      p.Name,
+		TokenHash: p.tokenHash,
+		Score:     p.Score,
+		Streak:    p.Streak,
+		JoinedAt:  p.JoinedAt,
+	}
+}
+
+func (r *Room) saveQuestion(round *quiz.Round) error {
+	r.sync.RLock()
+	source := r.sources[round.Question.ID]
+	r.sync.RUnlock()
+
+	question := &storage.Question{
+		Question:       *round.Question,
+		RoomID:         r.ID,
+		BankQuestionID: source,
+		PublishedAt:    round.PublishedAt,
+		Deadline:       roundDeadline(round),
+	}
+
+	if closedAt := round.ClosedAt(); !closedAt.IsZero() {
+		question.ClosedAt = &closedAt
+	}
+
+	return r.store.SaveQuestion(context.Background(), question)
+}
+
+func (r *Room) saveAnswer(answer *quiz.Answer) error {
+	return r.store.SaveAnswer(context.Background(), &storage.Answer{
+		Answer: *answer,
+		RoomID: r.ID,
+	})
+}
+
+// persist saves the room state that is kept in memory, so the next instance
+// to host the room picks up where this one left off.
+func (r *Room) persist() error {
+	r.sync.RLock()
+	for _, p := range r.participants {
+		if err := r.saveParticipant(p); err != nil {
+			r.sync.RUnlock()
+			return errors.Wrapf(err, "unable to save participant %s", p.ID)
+		}
+	}
+	current := r.current
+	r.sync.RUnlock()
+
+	if current == nil {
+		return nil
+	}
+
+	return errors.Wrap(r.saveQuestion(current), "unable to save current question")
+}
+
+// Restore adopts every open room nobody hosts, so a restart does not end
+// running lectures. Students resume with their existing tokens. Timed
+// questions whose deadline passed while the server was down are closed and
+// scored; the others get their timers re-armed.
+func (m *Manager) Restore(ctx context.Context) error {
+	rooms, err := m.store.ListOpenRooms(ctx)
+	if err != nil {
+		return errors.Wrap(err, "unable to list open rooms")
+	}
+
+	for _, stored := range rooms {
+		// with several instances sharing storage, rooms still hosted by
+		// another one stay there
+		if _, err := m.adopt(ctx, stored); err != nil && !errors.Is(err, errHostedElsewhere) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// resumeTimer continues the current question of a restored room: it is
+// closed and scored if its deadline passed in the meantime, otherwise its
+// timer is re-armed.
+func (r *Room) resumeTimer(tick time.Duration) error {
+	current := r.currentRound()
+	if current == nil || current.Closed() || !current.Timed() {
+		return nil
+	}
+
+	if time.Now().After(current.Deadline) {
+		return r.closeRound(current)
+	}
+
+	go r.runTimer(current, tick)
+
+	return nil
+}
+
+func (m *Manager) restoreRoom(ctx context.Context, stored *storage.Room) (*Room, error) {
+	room := NewRoom(stored.ID, stored.Professor, m.roomOptions(), m.store, m.log)
+	room.CreatedAt = stored.CreatedAt
+
+	// the replay buffer did not survive the restart; continuing from a
+	// clock-based sequence number keeps it above anything handed out before,
+	// so reconnecting clients are told to resync instead of seeing reused
+	// numbers
+	room.outbox.seq = uint64(time.Now().UnixMilli())
+
+	if stored.BankID != "" {
+		playlist, err := m.store.ListBankQuestions(ctx, stored.BankID)
+		if err != nil {
+			return nil, err
+		}
+
+		room.bankID = stored.BankID
+		room.playlist = playlist
+		room.bankPosition = stored.BankPosition
+	}
+
+	participants, err := m.store.ListParticipants(ctx, stored.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, sp := range participants {
+		room.addParticipant(&Participant{
+			ID:        sp.ID,
+			StudentID: sp.StudentID,
+			Name:      sp.Name,
+			Score:     sp.Score,
+			Streak:    sp.Streak,
+			JoinedAt:  sp.JoinedAt,
+			Answers:   make(map[string]*quiz.Answer),
+			tokenHash: sp.TokenHash,
+		})
+	}
+
+	questions, err := m.store.ListQuestions(ctx, stored.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// questions are ordered by publish time, so the last one wins current
+	for _, sq := range questions {
+		question := sq.Question
+
+		var duration time.Duration
+		if sq.Deadline != nil {
+			duration = sq.Deadline.Sub(sq.PublishedAt)
+		}
+
+		round := quiz.NewRound(&question, sq.PublishedAt, duration)
+		if sq.ClosedAt != nil {
+			round.CloseAt(*sq.ClosedAt)
+		}
+
+		room.rounds[question.ID] = round
+		room.current = round
+
+		if sq.BankQuestionID != "" {
+			room.sources[question.ID] = sq.BankQuestionID
+		}
+	}
+
+	answers, err := m.store.ListAnswers(ctx, stored.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, sa := range answers {
+		answer := sa.Answer
+
+		round, ok := room.rounds[answer.QuestionID]
+		if !ok {
+			continue
+		}
+		round.Restore(&answer)
+
+		if p, ok := room.participants[answer.ParticipantID]; ok {
+			p.Answers[answer.QuestionID] = &answer
+		}
+	}
+
+	board, err := m.store.ListStudentQuestions(ctx, stored.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, sq := range board {
+		q := &boardQuestion{
+			ID:            sq.ID,
+			ParticipantID: sq.ParticipantID,
+			Text:          sq.Text,
+			Anonymous:     sq.Anonymous,
+			Status:        sq.Status,
+			Pinned:        sq.Pinned,
+			AskedAt:       sq.AskedAt,
+			upvoters:      make(map[string]bool, len(sq.Upvoters)),
+		}
+
+		for _, id := range sq.Upvoters {
+			q.upvoters[id] = true
+		}
+
+		room.board[q.ID] = q
+	}
+
+	polls, err := m.store.ListPolls(ctx, stored.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	// polls are ordered by start time, so the last one wins poll
+	for _, sp := range polls {
+		p := sp.Poll
+
+		tally := poll.NewTally(&p, sp.StartedAt)
+		if sp.ClosedAt != nil {
+			tally.CloseAt(*sp.ClosedAt)
+		}
+
+		room.polls[p.ID] = tally
+		room.poll = tally
+	}
+
+	responses, err := m.store.ListPollResponses(ctx, stored.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, sr := range responses {
+		response := sr.Response
+
+		if tally, ok := room.polls[response.PollID]; ok {
+			tally.Restore(&response)
+		}
+	}
+
+	return room, nil
+}
@@ -0,0 +1,203 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"mnemo/services/poll"
+	"mnemo/storage"
+)
+
+const pollIDBytes = 8
+
+// StartPoll lets the professor run an ungraded poll or word cloud. Starting
+// a poll closes the previous one if it is still open.
+func StartPoll(event Event, c *Client) error {
+	if c.role != RoleProfessor {
+		return newProtocolError(CodeForbidden, "only professors can start polls")
+	}
+
+	var startEvent StartPollEvent
+	if err := json.Unmarshal(event.Payload, &startEvent); err != nil {
+		return badPayload(err)
+	}
+
+	p := startEvent.Poll
+	if err := p.Validate(); err != nil {
+		return err
+	}
+
+	id, err := newToken(pollIDBytes)
+	if err != nil {
+		return errors.Wrap(err, "unable to generate poll id")
+	}
+	p.ID = id
+
+	room := c.room
+
+	if previous := room.currentPoll(); previous != nil {
+		if err := room.closePoll(previous); err != nil {
+			return err
+		}
+	}
+
+	tally := poll.NewTally(&p, time.Now())
+
+	if err := room.savePoll(tally); err != nil {
+		return err
+	}
+
+	room.sync.Lock()
+	room.polls[p.ID] = tally
+	room.poll = tally
+	room.sync.Unlock()
+
+	return room.notifyAll(EventPollStarted, PollStartedEvent{Poll: tally.Poll, StartedAt: tally.StartedAt})
+}
+
+// RespondPoll records a student's response to an open poll and pushes the
+// updated results to the professor.
+func RespondPoll(event Event, c *Client) error {
+	if c.role != RoleStudent {
+		return newProtocolError(CodeForbidden, "only students can respond to polls")
+	}
+
+	var responseEvent PollResponseEvent
+	if err := json.Unmarshal(event.Payload, &responseEvent); err != nil {
+		return badPayload(err)
+	}
+
+	room := c.room
+
+	tally, ok := room.getPoll(responseEvent.PollID)
+	if !ok {
+		return newProtocolError(CodeNotFound, "unknown poll '%s'", responseEvent.PollID)
+	}
+
+	response := &poll.Response{
+		ParticipantID: c.participant.ID,
+		PollID:        tally.Poll.ID,
+		Choices:       responseEvent.Choices,
+		Text:          responseEvent.Text,
+		SubmittedAt:   time.Now(),
+	}
+
+	if err := tally.Submit(response); err != nil {
+		if errors.Is(err, poll.ErrClosed) {
+			return newProtocolError(CodeInvalidState, "poll is closed")
+		}
+		return err
+	}
+
+	if err := room.store.SavePollResponse(context.Background(), &storage.PollResponse{Response: *response, RoomID: room.ID}); err != nil {
+		return err
+	}
+
+	if err := c.sendEvent(EventPollResponseAccepted, PollResponseAcceptedEvent{Response: response}); err != nil {
+		return err
+	}
+
+	return room.notifyProfessors(EventPollResults, PollResultsEvent{Results: tally.Results(room.options.WordCloudTerms)})
+}
+
+// ClosePoll lets the professor stop accepting responses and share the
+// results with the room.
+func ClosePoll(event Event, c *Client) error {
+	if c.role != RoleProfessor {
+		return newProtocolError(CodeForbidden, "only professors can close polls")
+	}
+
+	var closeEvent ClosePollEvent
+	if err := json.Unmarshal(event.Payload, &closeEvent); err != nil {
+		return badPayload(err)
+	}
+
+	tally, ok := c.room.getPoll(closeEvent.PollID)
+	if !ok {
+		return newProtocolError(CodeNotFound, "unknown poll '%s'", closeEvent.PollID)
+	}
+
+	if tally.Closed() {
+		return newProtocolError(CodeInvalidState, "poll is already closed")
+	}
+
+	return c.room.closePoll(tally)
+}
+
+// closePoll stops the poll and sends everybody the final results. Closing a
+// poll twice is a no-op.
+func (r *Room) closePoll(tally *poll.Tally) error {
+	if !tally.Close() {
+		return nil
+	}
+
+	if err := r.savePoll(tally); err != nil {
+		return err
+	}
+
+	return r.notifyAll(EventPollClosed, PollClosedEvent{
+		PollID:  tally.Poll.ID,
+		Results: tally.Results(r.options.WordCloudTerms),
+	})
+}
+
+func (r *Room) savePoll(tally *poll.Tally) error {
+	stored := &storage.Poll{
+		Poll:      *tally.Poll,
+		RoomID:    r.ID,
+		StartedAt: tally.StartedAt,
+	}
+
+	if closedAt := tally.ClosedAt(); !closedAt.IsZero() {
+		stored.ClosedAt = &closedAt
+	}
+
+	return r.store.SavePoll(context.Background(), stored)
+}
+
+func (r *Room) getPoll(id string) (*poll.Tally, bool) {
+	r.sync.RLock()
+	defer r.sync.RUnlock()
+
+	tally, ok := r.polls[id]
+	return tally, ok
+}
+
+// currentPoll returns the poll accepting responses, if any.
+func (r *Room) currentPoll() *poll.Tally {
+	r.sync.RLock()
+	defer r.sync.RUnlock()
+
+	return r.openPoll()
+}
+
+// openPoll is currentPoll for callers that hold the room lock.
+func (r *Room) openPoll() *poll.Tally {
+	if r.poll == nil || r.poll.Closed() {
+		return nil
+	}
+
+	return r.poll
+}
+
+// sendPoll sends the open poll and its results so far to a professor that
+// just connected.
+func (r *Room) sendPoll(client *Client) {
+	tally := r.currentPoll()
+	if tally == nil {
+		return
+	}
+
+	if err := client.sendEvent(EventPollStarted, PollStartedEvent{Poll: tally.Poll, StartedAt: tally.StartedAt}); err != nil {
+		client.log.Error("unable to send poll", zap.Error(err))
+		return
+	}
+
+	if err := client.sendEvent(EventPollResults, PollResultsEvent{Results: tally.Results(r.options.WordCloudTerms)}); err != nil {
+		client.log.Error("unable to send poll results", zap.Error(err))
+	}
+}
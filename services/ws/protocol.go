@@ -0,0 +1,111 @@
+package ws
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"mnemo/services/poll"
+	"mnemo/services/quiz"
+)
+
+// ProtocolVersion is the envelope version the server speaks. Clients may
+// leave it out, which means the current version.
+const ProtocolVersion = 1
+
+// Codes of error events.
+const (
+	CodeInvalidJSON        = "invalid_json"
+	CodeUnsupportedVersion = "unsupported_version"
+	CodeUnknownEvent       = "unknown_event"
+	CodeInvalidPayload     = "invalid_payload"
+	CodeNotJoined          = "not_joined"
+	CodeForbidden          = "forbidden"
+	CodeInvalidState       = "invalid_state"
+	CodeNotFound           = "not_found"
+	CodeRateLimited        = "rate_limited"
+	CodeInternal           = "internal_error"
+)
+
+// ProtocolError is an error that is reported back to the client that sent the
+// offending event.
+type ProtocolError struct {
+	Code    string
+	Message string
+}
+
+func (e *ProtocolError) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+func newProtocolError(code, format string, args ...any) *ProtocolError {
+	return &ProtocolError{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// badPayload wraps a payload decoding error.
+func badPayload(err error) *ProtocolError {
+	return newProtocolError(CodeInvalidPayload, "bad payload in request: %v", err)
+}
+
+// eventIDs generates outgoing event IDs: a random per-process prefix plus a
+// counter, so IDs are unique without a syscall per event.
+var eventIDs = struct {
+	prefix string
+	next   atomic.Uint64
+}{prefix: randomPrefix()}
+
+func randomPrefix() string {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+
+	return hex.EncodeToString(b)
+}
+
+// stamp fills in the envelope fields of an outgoing event.
+func stamp(event *Event) {
+	if event.Version == 0 {
+		event.Version = ProtocolVersion
+	}
+
+	if event.ID == "" {
+		event.ID = eventIDs.prefix + "-" + strconv.FormatUint(eventIDs.next.Add(1), 36)
+	}
+}
+
+// reportError sends an error event for a failed inbound event and returns
+// its code. Errors that are not ProtocolErrors are internal: they are logged
+// and the client only learns that something went wrong.
+func (c *Client) reportError(err error, correlationID string) string {
+	var protocolErr *ProtocolError
+
+	switch {
+	case errors.As(err, &protocolErr):
+	case errors.Is(err, quiz.ErrInvalidQuestion), errors.Is(err, poll.ErrInvalidPoll), errors.Is(err, poll.ErrInvalidResponse):
+		protocolErr = newProtocolError(CodeInvalidPayload, "%v", err)
+	default:
+		c.log.Error("unable to handle event", zap.String("correlation_id", correlationID), zap.Error(err))
+		protocolErr = newProtocolError(CodeInternal, "internal error")
+	}
+
+	data, err := json.Marshal(ErrorEvent{
+		Code:          protocolErr.Code,
+		Message:       protocolErr.Message,
+		CorrelationID: correlationID,
+	})
+	if err != nil {
+		c.log.Error("unable to marshal event", zap.String("type", EventError), zap.Error(err))
+		return protocolErr.Code
+	}
+
+	c.send(Event{Type: EventError, Payload: data})
+
+	return protocolErr.Code
+}
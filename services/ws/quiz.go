@@ -0,0 +1,274 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"mnemo/services/quiz"
+	"mnemo/storage"
+)
+
+const questionIDBytes = 8
+
+// PublishQuestion lets the professor ask a new question. The question is
+// graded server-side, so students only ever receive the public view without
+// the correct answer. Publishing closes the previous question if it is still
+// open.
+func PublishQuestion(event Event, c *Client) error {
+	if c.role != RoleProfessor {
+		return newProtocolError(CodeForbidden, "only professors can publish questions")
+	}
+
+	var publishEvent PublishQuestionEvent
+	if err := json.Unmarshal(event.Payload, &publishEvent); err != nil {
+		return badPayload(err)
+	}
+
+	if err := publishEvent.Question.Validate(); err != nil {
+		return err
+	}
+
+	duration := time.Duration(publishEvent.DurationSecs) * time.Second
+	if duration < 0 || duration > quiz.MaxDuration {
+		return newProtocolError(CodeInvalidPayload, "duration must be between 0 and %d seconds", int(quiz.MaxDuration.Seconds()))
+	}
+
+	return c.publish(publishEvent.Question, duration, nil)
+}
+
+// NextQuestion publishes the next question of the bank the room was started
+// from. The room only moves on once the question is published, so a failed
+// publish leaves it to be asked again.
+func NextQuestion(event Event, c *Client) error {
+	if c.role != RoleProfessor {
+		return newProtocolError(CodeForbidden, "only professors can publish questions")
+	}
+
+	next, position, ok := c.room.nextBankQuestion()
+	if !ok {
+		return newProtocolError(CodeInvalidState, "no more questions in this bank")
+	}
+
+	if err := c.publish(next.Question, time.Duration(next.DurationSecs)*time.Second, next); err != nil {
+		return err
+	}
+
+	if !c.room.advanceBank(position) {
+		// another professor connection published it first
+		return nil
+	}
+
+	return c.room.store.SetRoomBankPosition(context.Background(), c.room.ID, position+1)
+}
+
+// publish starts a new round for question, which must already be validated.
+// source is the bank question it came from, if any.
+func (c *Client) publish(question quiz.Question, duration time.Duration, source *storage.BankQuestion) error {
+	id, err := newToken(questionIDBytes)
+	if err != nil {
+		return errors.Wrap(err, "unable to generate question id")
+	}
+	question.ID = id
+
+	if previous := c.room.currentRound(); previous != nil {
+		if err := c.room.closeRound(previous); err != nil {
+			return err
+		}
+	}
+
+	round := quiz.NewRound(&question, time.Now(), duration)
+
+	published := QuestionPublishedEvent{
+		Question:    round.Question,
+		PublishedAt: round.PublishedAt,
+		Deadline:    roundDeadline(round),
+	}
+
+	if source != nil {
+		c.room.setSource(question.ID, source.ID)
+		published.Position, published.Total = source.Position, c.room.bankTotal()
+	}
+
+	if err := c.room.saveQuestion(round); err != nil {
+		return err
+	}
+
+	c.room.startRound(round)
+
+	// the professor gets the full question back so they know its ID
+	if err := c.room.notifyProfessors(EventQuestionPublished, published); err != nil {
+		return err
+	}
+
+	published.Question = round.Question.Public()
+	if err := c.room.notifyStudents(EventQuestionPublished, published); err != nil {
+		return err
+	}
+
+	if round.Timed() {
+		go c.room.runTimer(round, c.manager.config.QuestionTickInterval)
+	}
+
+	return nil
+}
+
+// CloseQuestion lets the professor stop accepting answers before the timer
+// runs out, or close a question that has no time limit.
+func CloseQuestion(event Event, c *Client) error {
+	if c.role != RoleProfessor {
+		return newProtocolError(CodeForbidden, "only professors can close questions")
+	}
+
+	var closeEvent CloseQuestionEvent
+	if err := json.Unmarshal(event.Payload, &closeEvent); err != nil {
+		return badPayload(err)
+	}
+
+	round, ok := c.room.round(closeEvent.QuestionID)
+	if !ok {
+		return newProtocolError(CodeNotFound, "unknown question '%s'", closeEvent.QuestionID)
+	}
+
+	return c.room.closeRound(round)
+}
+
+// SubmitAnswer grades a student's answer to the current question and pushes
+// the updated results to the professor.
+func SubmitAnswer(event Event, c *Client) error {
+	if c.role != RoleStudent {
+		return newProtocolError(CodeForbidden, "only students can answer questions")
+	}
+
+	var answerEvent AnswerSubmittedEvent
+	if err := json.Unmarshal(event.Payload, &answerEvent); err != nil {
+		return badPayload(err)
+	}
+
+	round, ok := c.room.round(answerEvent.QuestionID)
+	if !ok {
+		return c.sendEvent(EventAnswerRejected, AnswerRejectedEvent{
+			QuestionID: answerEvent.QuestionID,
+			Reason:     "unknown question",
+		})
+	}
+
+	answer, err := round.Submit(c.participant.ID, answerEvent.Choices, time.Now())
+	if err != nil {
+		if errors.Is(err, quiz.ErrInvalidAnswer) || errors.Is(err, quiz.ErrAlreadyAnswered) || errors.Is(err, quiz.ErrClosed) {
+			return c.sendEvent(EventAnswerRejected, AnswerRejectedEvent{
+				QuestionID: answerEvent.QuestionID,
+				Reason:     err.Error(),
+			})
+		}
+		return err
+	}
+
+	if err := c.room.saveAnswer(answer); err != nil {
+		return err
+	}
+
+	c.room.recordAnswer(c.participant, answer)
+	c.manager.observer.AnswerSubmitted(AnswerLive, answer.Correct, answer.ResponseTime)
+
+	if err := c.sendEvent(EventAnswerAccepted, AnswerAcceptedEvent{Answer: answer}); err != nil {
+		return err
+	}
+
+	return c.room.notifyProfessors(EventQuestionResults, QuestionResultsEvent{Results: round.Results()})
+}
+
+func (r *Room) startRound(round *quiz.Round) {
+	r.sync.Lock()
+	defer r.sync.Unlock()
+
+	r.rounds[round.Question.ID] = round
+	r.current = round
+}
+
+// roundDeadline returns the deadline to advertise to clients, or nil for
+// untimed questions.
+func roundDeadline(round *quiz.Round) *time.Time {
+	if !round.Timed() {
+		return nil
+	}
+
+	return &round.Deadline
+}
+
+// nextBankQuestion advances the room through its bank and returns the next
+// question together with the new position.
+// nextBankQuestion returns the next question of the playlist and its index,
+// without moving on to it; see advanceBank.
+func (r *Room) nextBankQuestion() (*storage.BankQuestion, int, bool) {
+	r.sync.RLock()
+	defer r.sync.RUnlock()
+
+	if r.bankPosition >= len(r.playlist) {
+		return nil, r.bankPosition, false
+	}
+
+	return r.playlist[r.bankPosition], r.bankPosition, true
+}
+
+// advanceBank moves past the playlist question at index, unless the room
+// already has.
+func (r *Room) advanceBank(index int) bool {
+	r.sync.Lock()
+	defer r.sync.Unlock()
+
+	if r.bankPosition != index {
+		return false
+	}
+
+	r.bankPosition++
+	return true
+}
+
+func (r *Room) bankTotal() int {
+	r.sync.RLock()
+	defer r.sync.RUnlock()
+
+	return len(r.playlist)
+}
+
+func (r *Room) setSource(questionID, bankQuestionID string) {
+	r.sync.Lock()
+	defer r.sync.Unlock()
+
+	r.sources[questionID] = bankQuestionID
+}
+
+func (r *Room) currentRound() *quiz.Round {
+	r.sync.RLock()
+	defer r.sync.RUnlock()
+
+	return r.current
+}
+
+func (r *Room) round(questionID string) (*quiz.Round, bool) {
+	r.sync.RLock()
+	defer r.sync.RUnlock()
+
+	round, ok := r.rounds[questionID]
+	return round, ok
+}
+
+func (r *Room) recordAnswer(p *Participant, answer *quiz.Answer) {
+	r.sync.Lock()
+	defer r.sync.Unlock()
+
+	p.Answers[answer.QuestionID] = answer
+}
+
+// openQuestion returns the public view of the question currently accepting
+// answers, if any. The caller must hold the room lock.
+func (r *Room) openQuestion() *quiz.Question {
+	if r.current == nil || r.current.Closed() {
+		return nil
+	}
+
+	return r.current.Question.Public()
+}
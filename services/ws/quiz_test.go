@@ -0,0 +1,122 @@
+package ws
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"mnemo/services/quiz"
+	"mnemo/storage"
+)
+
+// failingSaveStore fails to save questions while fail is set.
+type failingSaveStore struct {
+	storage.Repository
+	fail bool
+}
+
+func (s *failingSaveStore) SaveQuestion(ctx context.Context, q *storage.Question) error {
+	if s.fail {
+		return errors.New("disk full")
+	}
+
+	return s.Repository.SaveQuestion(ctx, q)
+}
+
+// newBankRoom creates a room started from a bank with the given prompts.
+func newBankRoom(t *testing.T, m *Manager, prompts ...string) *Room {
+	t.Helper()
+
+	ctx := context.Background()
+	now := time.Now()
+
+	bank := &storage.Bank{ID: "bank", Owner: testProfessor, Name: "Bank", CreatedAt: now, UpdatedAt: now}
+	if err := m.store.CreateBank(ctx, bank); err != nil {
+		t.Fatalf("CreateBank: %v", err)
+	}
+
+	questions := make([]*storage.BankQuestion, len(prompts))
+	for i, prompt := range prompts {
+		q := &storage.BankQuestion{
+			Question: quiz.Question{ID: prompt, Kind: quiz.KindTrueFalse, Prompt: prompt, Correct: []string{quiz.OptionTrue}},
+			BankID:   bank.ID,
+		}
+		if err := q.Validate(); err != nil {
+			t.Fatalf("Validate: %v", err)
+		}
+		questions[i] = q
+	}
+
+	if err := m.store.CreateBankQuestions(ctx, questions); err != nil {
+		t.Fatalf("CreateBankQuestions: %v", err)
+	}
+
+	room, err := m.CreateRoom(ctx, testProfessor, bank.ID)
+	if err != nil {
+		t.Fatalf("CreateRoom: %v", err)
+	}
+
+	return room
+}
+
+func TestNextQuestion(t *testing.T) {
+	m := newTestManager(t)
+	room := newBankRoom(t, m, "first", "second")
+
+	store := &failingSaveStore{Repository: room.store}
+	room.store = store
+
+	professor := connect(t, m, room, RoleProfessor)
+
+	tests := []struct {
+		name         string
+		fail         bool
+		wantErr      bool
+		wantCode     string
+		wantPrompt   string
+		wantPosition int // of the room after the event
+	}{
+		{name: "failed publish stays on the question", fail: true, wantErr: true, wantPosition: 0},
+		{name: "first", wantPrompt: "first", wantPosition: 1},
+		{name: "second", wantPrompt: "second", wantPosition: 2},
+		{name: "end of bank", wantErr: true, wantCode: CodeInvalidState, wantPosition: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store.fail = tt.fail
+
+			err := send(t, professor, EventNextQuestion, struct{}{})
+			if (err != nil) != tt.wantErr || errorCode(err) != tt.wantCode {
+				t.Fatalf("next_question = %v, want error %v with code %q", err, tt.wantErr, tt.wantCode)
+			}
+
+			var published QuestionPublishedEvent
+			ok := lastEvent(t, received(professor), EventQuestionPublished, &published)
+			if ok != (tt.wantPrompt != "") {
+				t.Fatalf("published = %v, want %v", ok, tt.wantPrompt != "")
+			}
+			if ok && (published.Question.Prompt != tt.wantPrompt || published.Position != tt.wantPosition || published.Total != 2) {
+				t.Errorf("published %q at %d of %d", published.Question.Prompt, published.Position, published.Total)
+			}
+
+			room.sync.RLock()
+			position := room.bankPosition
+			room.sync.RUnlock()
+
+			if position != tt.wantPosition {
+				t.Errorf("position = %d, want %d", position, tt.wantPosition)
+			}
+
+			stored, err := m.store.GetRoom(context.Background(), room.ID)
+			if err != nil {
+				t.Fatalf("GetRoom: %v", err)
+			}
+			if stored.BankPosition != tt.wantPosition {
+				t.Errorf("stored position = %d, want %d", stored.BankPosition, tt.wantPosition)
+			}
+		})
+	}
+}
@@ -0,0 +1,474 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"mnemo/services/broker"
+)
+
+// Rooms are hosted by the instance holding their lease, see lease.go; it holds
+// the room state and runs the handlers. A client whose connection lands on
+// another instance is relayed over the broker:
+//
+//   - the edge instance, where the client is connected, publishes the
+//     client's connect, messages and disconnect to the room's topic
+//   - the hosting instance represents the client by a Client whose transport
+//     is a remoteConn, and publishes the events for it to the edge instance's
+//     topic, once per event and instance however many of its clients receive
+//     it
+//
+// Both directions go over a single topic each, so events keep their order.
+
+var ErrRoomNotHosted = errors.New("room is not hosted by any instance")
+
+// relayQueueSize bounds the events waiting to be published to one edge
+// instance.
+const relayQueueSize = 4096
+
+// Kinds of relay messages.
+const (
+	// edge -> host, on the room topic
+	relayConnect    = "connect"
+	relayMessage    = "message"
+	relayDisconnect = "disconnect"
+
+	// host -> edge, on the instance topic
+	relayDeliver = "deliver"
+	relayClose   = "close"
+)
+
+type relayEnvelope struct {
+	Kind     string `json:"kind"`
+	Instance string `json:"instance,omitempty"` // sending edge instance
+
+	Client  string   `json:"client,omitempty"`
+	Clients []string `json:"clients,omitempty"` // recipients of a delivery
+
+	Role    string  `json:"role,omitempty"`
+	Name    string  `json:"name,omitempty"`
+	LastSeq *uint64 `json:"last_seq,omitempty"`
+
+	// Data is the client's raw message, or the event to deliver
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+func roomTopic(roomID string) string {
+	return "mnemo:room:" + roomID
+}
+
+func instanceTopic(instance string) string {
+	return "mnemo:instance:" + instance
+}
+
+// relayState is the manager's bookkeeping of relayed clients.
+type relayState struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// addr is the API address other instances reach this one at
+	addr string
+
+	// hosting side: links to edge instances, clients connected to them and
+	// the topics of hosted rooms
+	links         map[string]*relayLink
+	remotes       map[string]*Client
+	subscriptions map[string]broker.Subscription
+
+	// edge side: local clients of rooms hosted elsewhere
+	upstreams map[string]*Client
+
+	sync sync.Mutex
+}
+
+func newRelayState() *relayState {
+	return &relayState{
+		ctx:           context.Background(),
+		cancel:        func() {},
+		links:         make(map[string]*relayLink),
+		remotes:       make(map[string]*Client),
+		subscriptions: make(map[string]broker.Subscription),
+		upstreams:     make(map[string]*Client),
+	}
+}
+
+// Start subscribes the manager to the events other instances relay to its
+// clients and keeps the leases on its rooms. Once ctx is done the manager
+// drains its clients; rooms are hosted and relayed until Stop is called, so
+// the clients can still be told.
+func (m *Manager) Start(ctx context.Context) error {
+	relayCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+
+	m.relay.sync.Lock()
+	m.relay.ctx = relayCtx
+	m.relay.cancel = cancel
+	m.relay.addr = m.config.APIListenAddress
+	m.relay.sync.Unlock()
+
+	if _, err := m.broker.Subscribe(relayCtx, instanceTopic(m.instance), m.handleDownstream); err != nil {
+		return errors.Wrap(err, "unable to subscribe to instance topic")
+	}
+
+	go m.holdLeases(relayCtx)
+
+	go func() {
+		<-ctx.Done()
+		m.drain()
+	}()
+
+	return nil
+}
+
+// host takes the lease on a new room and subscribes to its topic, making the
+// room reachable from clients connected to other instances.
+func (m *Manager) host(room *Room) error {
+	ok, err := m.lease(context.Background(), room.ID, room.Professor)
+	if err != nil {
+		return errors.Wrapf(err, "unable to lease room %s", room.ID)
+	}
+	if !ok {
+		return errHostedElsewhere
+	}
+
+	return m.subscribe(room)
+}
+
+func (m *Manager) subscribe(room *Room) error {
+	m.relay.sync.Lock()
+	ctx := m.relay.ctx
+	m.relay.sync.Unlock()
+
+	sub, err := m.broker.Subscribe(ctx, roomTopic(room.ID), func(data []byte) {
+		m.handleUpstream(room, data)
+	})
+	if err != nil {
+		return errors.Wrapf(err, "unable to subscribe to room %s", room.ID)
+	}
+
+	m.relay.sync.Lock()
+	m.relay.subscriptions[room.ID] = sub
+	m.relay.sync.Unlock()
+
+	return nil
+}
+
+// unhost unsubscribes from the room's topic and gives up its lease.
+func (m *Manager) unhost(room *Room) {
+	m.unsubscribe(room.ID)
+	m.releaseLease(context.Background(), room.ID, room.Professor)
+}
+
+func (m *Manager) unsubscribe(roomID string) {
+	m.relay.sync.Lock()
+	sub, ok := m.relay.subscriptions[roomID]
+	delete(m.relay.subscriptions, roomID)
+	m.relay.sync.Unlock()
+
+	if !ok {
+		return
+	}
+
+	if err := sub.Unsubscribe(); err != nil {
+		m.log.Error("unable to unsubscribe from room", zap.String("room", roomID), zap.Error(err))
+	}
+}
+
+func (m *Manager) publish(ctx context.Context, topic string, msg relayEnvelope) (int, error) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return 0, errors.Wrapf(err, "unable to marshal %s relay message", msg.Kind)
+	}
+
+	return m.broker.Publish(ctx, topic, data)
+}
+
+// upstream links a local client to the room it joined on another instance.
+type upstream struct {
+	roomID string
+	id     string
+
+	// host is the instance hosting the room when the client connected
+	host string
+}
+
+// relayClient connects a local client to the room hosted on another
+// instance. It returns ErrRoomNotHosted if no instance hosts it.
+func (m *Manager) relayClient(client *Client, req *roomConnection) error {
+	// the client keeps its id on the hosting instance
+	id := client.id
+	client.upstream = &upstream{roomID: req.roomID, id: id, host: req.host.Instance}
+
+	m.relay.sync.Lock()
+	m.relay.upstreams[id] = client
+	m.relay.sync.Unlock()
+
+	n, err := m.publish(context.Background(), roomTopic(req.roomID), relayEnvelope{
+		Kind:     relayConnect,
+		Instance: m.instance,
+		Client:   id,
+		Role:     req.role,
+		Name:     req.name,
+		LastSeq:  req.lastSeq,
+	})
+	if err == nil && n == 0 {
+		err = ErrRoomNotHosted
+	}
+
+	if err != nil {
+		m.relay.sync.Lock()
+		delete(m.relay.upstreams, id)
+		m.relay.sync.Unlock()
+	}
+
+	return err
+}
+
+// forward relays a message of a local client to the room's host.
+func (c *Client) forward(payload []byte) {
+	_, err := c.manager.publish(context.Background(), roomTopic(c.upstream.roomID), relayEnvelope{
+		Kind:     relayMessage,
+		Instance: c.manager.instance,
+		Client:   c.upstream.id,
+		Data:     payload,
+	})
+	if err != nil {
+		c.log.Error("unable to relay message", zap.Error(err))
+	}
+}
+
+// detachUpstream tells the room's host that the local client left. It is
+// safe to call more than once.
+func (m *Manager) detachUpstream(client *Client) {
+	m.relay.sync.Lock()
+	_, ok := m.relay.upstreams[client.upstream.id]
+	delete(m.relay.upstreams, client.upstream.id)
+	m.relay.sync.Unlock()
+
+	if !ok {
+		return
+	}
+
+	_, err := m.publish(context.Background(), roomTopic(client.upstream.roomID), relayEnvelope{
+		Kind:     relayDisconnect,
+		Instance: m.instance,
+		Client:   client.upstream.id,
+	})
+	if err != nil {
+		client.log.Error("unable to relay disconnect", zap.Error(err))
+	}
+}
+
+// handleDownstream handles what hosting instances send to local clients.
+func (m *Manager) handleDownstream(data []byte) {
+	var msg relayEnvelope
+	if err := json.Unmarshal(data, &msg); err != nil {
+		m.log.Error("unable to decode relay message", zap.Error(err))
+		return
+	}
+
+	switch msg.Kind {
+	case relayDeliver:
+		var event Event
+		if err := json.Unmarshal(msg.Data, &event); err != nil {
+			m.log.Error("unable to decode relayed event", zap.Error(err))
+			return
+		}
+
+		for _, id := range msg.Clients {
+			if client, ok := m.upstreamClient(id); ok {
+				client.send(event)
+			}
+		}
+	case relayClose:
+		// after the events relayed before the close
+		if client, ok := m.upstreamClient(msg.Client); ok {
+			client.shutdown()
+		}
+	}
+}
+
+func (m *Manager) upstreamClient(id string) (*Client, bool) {
+	m.relay.sync.Lock()
+	defer m.relay.sync.Unlock()
+
+	client, ok := m.relay.upstreams[id]
+	return client, ok
+}
+
+// handleUpstream handles what edge instances send to a hosted room. Messages
+// of a room are handled one at a time, like messages read off a websocket.
+func (m *Manager) handleUpstream(room *Room, data []byte) {
+	var msg relayEnvelope
+	if err := json.Unmarshal(data, &msg); err != nil {
+		room.log.Error("unable to decode relay message", zap.Error(err))
+		return
+	}
+
+	switch msg.Kind {
+	case relayConnect:
+		client := newClient(msg.Client, &remoteConn{link: m.link(msg.Instance), id: msg.Client}, m, room, msg.Role)
+		client.name = msg.Name
+		client.log = client.log.With(zap.String("edge", msg.Instance))
+
+		m.relay.sync.Lock()
+		m.relay.remotes[msg.Client] = client
+		m.relay.sync.Unlock()
+
+		if err := m.addClient(client, msg.LastSeq); err != nil {
+			client.log.Error("unable to replay events", zap.Error(err))
+		}
+
+		client.log.Info("client connected")
+	case relayMessage:
+		client, ok := m.remoteClient(msg.Client)
+		if !ok {
+			// the client was removed here, e.g. for replacing it; let the
+			// edge drop it too
+			m.link(msg.Instance).close(msg.Client)
+			return
+		}
+
+		client.handleMessage(msg.Data)
+	case relayDisconnect:
+		if client, ok := m.remoteClient(msg.Client); ok {
+			m.removeClient(client)
+		}
+	}
+}
+
+func (m *Manager) remoteClient(id string) (*Client, bool) {
+	m.relay.sync.Lock()
+	defer m.relay.sync.Unlock()
+
+	client, ok := m.relay.remotes[id]
+	return client, ok
+}
+
+// link returns the queue of events to the given edge instance, starting it
+// on first use.
+func (m *Manager) link(instance string) *relayLink {
+	m.relay.sync.Lock()
+	defer m.relay.sync.Unlock()
+
+	if l, ok := m.relay.links[instance]; ok {
+		return l
+	}
+
+	l := &relayLink{
+		manager:  m,
+		instance: instance,
+		queue:    make(chan relayEnvelope, relayQueueSize),
+	}
+	m.relay.links[instance] = l
+
+	go l.run(m.relay.ctx)
+
+	return l
+}
+
+// relayLink publishes events to one edge instance in order, without making
+// the room wait for the broker.
+type relayLink struct {
+	manager  *Manager
+	instance string
+	queue    chan relayEnvelope
+
+	// pending counts the messages queued or being published
+	pending atomic.Int64
+}
+
+func (l *relayLink) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg := <-l.queue:
+			if _, err := l.manager.publish(ctx, instanceTopic(l.instance), msg); err != nil {
+				l.manager.log.Error("unable to relay to instance", zap.String("edge", l.instance), zap.Error(err))
+			}
+			l.pending.Add(-1)
+		}
+	}
+}
+
+// deliver queues the event for the given clients of the edge instance.
+func (l *relayLink) deliver(clients []string, event Event) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		l.manager.log.Error("unable to marshal event", zap.String("type", event.Type), zap.Error(err))
+		return
+	}
+
+	l.enqueue(relayEnvelope{Kind: relayDeliver, Clients: clients, Data: data})
+}
+
+func (l *relayLink) close(client string) {
+	l.enqueue(relayEnvelope{Kind: relayClose, Client: client})
+}
+
+func (l *relayLink) enqueue(msg relayEnvelope) {
+	l.pending.Add(1)
+
+	select {
+	case l.queue <- msg:
+	default:
+		l.pending.Add(-1)
+		l.manager.log.Warn("relay queue full, dropping message", zap.String("edge", l.instance), zap.String("kind", msg.Kind))
+		l.manager.egress.dropped.Add(uint64(max(len(msg.Clients), 1)))
+	}
+}
+
+// remoteConn is the transport of a client connected to another instance.
+type remoteConn struct {
+	link *relayLink
+	id   string
+
+	closeOnce sync.Once
+}
+
+// Close disconnects the client on its edge instance. The caller removes the
+// client from its room.
+func (c *remoteConn) Close() error {
+	c.closeOnce.Do(func() {
+		m := c.link.manager
+
+		m.relay.sync.Lock()
+		delete(m.relay.remotes, c.id)
+		m.relay.sync.Unlock()
+
+		c.link.close(c.id)
+	})
+
+	return nil
+}
+
+// fanout queues the event for targets. Clients connected to other instances
+// are batched into one delivery per instance.
+func (r *Room) fanout(event Event, targets []*Client) {
+	// every recipient sees the same event ID
+	stamp(&event)
+
+	var remote map[*relayLink][]string
+
+	for _, client := range targets {
+		conn, ok := client.connection.(*remoteConn)
+		if !ok {
+			client.send(event)
+			continue
+		}
+
+		if remote == nil {
+			remote = make(map[*relayLink][]string)
+		}
+		remote[conn.link] = append(remote[conn.link], conn.id)
+	}
+
+	for link, clients := range remote {
+		link.deliver(clients, event)
+	}
+}
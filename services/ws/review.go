@@ -0,0 +1,300 @@
+package ws
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+
+	"mnemo/services/srs"
+	"mnemo/storage"
+)
+
+var ErrUnknownStudent = errors.New("unknown student token")
+
+const (
+	// StudentTokenHeader carries the student token on API requests and
+	// review connections. StudentTokenQueryParam is the fallback for
+	// websocket clients that cannot set headers.
+	StudentTokenHeader     = "X-Student-Token"
+	StudentTokenQueryParam = "student_token"
+)
+
+// reviewSession is a student's self-paced run through their due reviews. It
+// is only touched from the client's read loop, so it needs no locking.
+type reviewSession struct {
+	studentID string
+	queue     []*storage.DueReview
+	sentAt    time.Time // when the head of the queue was sent
+	reviewed  int
+	correct   int
+}
+
+// AuthenticateStudent resolves a student token to its student.
+func (m *Manager) AuthenticateStudent(ctx context.Context, token string) (*storage.Student, error) {
+	student, err := m.store.GetStudentByToken(ctx, hashToken(token))
+	if errors.Is(err, storage.ErrNotFound) {
+		return nil, ErrUnknownStudent
+	}
+
+	return student, err
+}
+
+// StudentTokenFromRequest extracts a student token from the student token
+// header or query parameter. Instructor session tokens live elsewhere, see
+// auth.TokenFromRequest.
+func StudentTokenFromRequest(r *http.Request) string {
+	if token := r.Header.Get(StudentTokenHeader); token != "" {
+		return strings.TrimSpace(token)
+	}
+
+	return r.URL.Query().Get(StudentTokenQueryParam)
+}
+
+// ServeReview upgrades a request authenticated with a student token into a
+// self-paced review connection. Review connections don't belong to a room;
+// they only accept the review events.
+func (m *Manager) ServeReview(w http.ResponseWriter, r *http.Request) {
+	student, ok := m.reviewRequest(w, r)
+	if !ok {
+		return
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// the upgrader already wrote the error response
+		m.log.Debug("websocket upgrade failed", zap.Error(err))
+		return
+	}
+
+	client := newReviewClient(conn, m, student)
+	client.log.Info("review client connected")
+
+	go client.readMessages(conn)
+	go client.writeMessages(conn)
+}
+
+// reviewRequest authenticates the student token of a review connection. On
+// failure it writes the error response and returns false.
+func (m *Manager) reviewRequest(w http.ResponseWriter, r *http.Request) (*storage.Student, bool) {
+	if m.refuseDraining(w) {
+		return nil, false
+	}
+
+	token := StudentTokenFromRequest(r)
+	if token == "" {
+		http.Error(w, "missing student token", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	student, err := m.AuthenticateStudent(r.Context(), token)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return nil, false
+	}
+
+	return student, true
+}
+
+func newReviewClient(conn Transport, m *Manager, student *storage.Student) *Client {
+	client := NewClient(conn, m, nil, RoleStudent)
+	client.name = student.Name
+	client.review = &reviewSession{studentID: student.ID}
+	client.log = client.log.With(zap.String("student", student.ID))
+
+	return client
+}
+
+// StartReview loads the student's due questions and sends the first one.
+func StartReview(event Event, c *Client) error {
+	var startEvent StartReviewEvent
+	if len(event.Payload) > 0 {
+		if err := json.Unmarshal(event.Payload, &startEvent); err != nil {
+			return badPayload(err)
+		}
+	}
+
+	limit := c.manager.config.ReviewSessionSize
+	if startEvent.Limit > 0 && startEvent.Limit < limit {
+		limit = startEvent.Limit
+	}
+
+	due, err := c.manager.store.ListDueReviews(context.Background(), c.review.studentID, time.Now(), limit)
+	if err != nil {
+		return errors.Wrap(err, "unable to list due reviews")
+	}
+
+	c.review.queue = due
+	c.review.reviewed = 0
+	c.review.correct = 0
+
+	return c.sendNextReview()
+}
+
+// ReviewAnswer grades the answer to the question at the head of the queue,
+// reschedules it and sends the next one.
+func ReviewAnswer(event Event, c *Client) error {
+	var answerEvent AnswerSubmittedEvent
+	if err := json.Unmarshal(event.Payload, &answerEvent); err != nil {
+		return badPayload(err)
+	}
+
+	session := c.review
+	if len(session.queue) == 0 || session.queue[0].Question.ID != answerEvent.QuestionID {
+		return c.sendEvent(EventReviewRejected, AnswerRejectedEvent{
+			QuestionID: answerEvent.QuestionID,
+			Reason:     "not the current review question",
+		})
+	}
+
+	item := session.queue[0]
+	now := time.Now()
+
+	correct, err := item.Question.Grade(answerEvent.Choices)
+	if err != nil {
+		return c.sendEvent(EventReviewRejected, AnswerRejectedEvent{
+			QuestionID: answerEvent.QuestionID,
+			Reason:     err.Error(),
+		})
+	}
+
+	review, err := recordReview(c.manager.store, &storage.ReviewLog{
+		StudentID:      session.studentID,
+		BankQuestionID: item.Question.ID,
+		Correct:        correct,
+		ResponseTime:   now.Sub(session.sentAt),
+		ReviewedAt:     now,
+	}, reviewWindow(item.Question, c.manager.config.ScoreSpeedWindow))
+	if err != nil {
+		return err
+	}
+
+	c.manager.observer.AnswerSubmitted(AnswerReview, correct, now.Sub(session.sentAt))
+
+	session.queue = session.queue[1:]
+	session.reviewed++
+	if correct {
+		session.correct++
+	}
+
+	if err := c.sendEvent(EventReviewResult, ReviewResultEvent{
+		QuestionID:  item.Question.ID,
+		Correct:     correct,
+		Answer:      item.Question.Correct,
+		Explanation: item.Question.Explanation,
+		Card:        review.Card,
+	}); err != nil {
+		return err
+	}
+
+	return c.sendNextReview()
+}
+
+func (c *Client) sendNextReview() error {
+	session := c.review
+
+	if len(session.queue) == 0 {
+		return c.sendEvent(EventReviewFinished, ReviewFinishedEvent{
+			Reviewed: session.reviewed,
+			Correct:  session.correct,
+		})
+	}
+
+	item := session.queue[0]
+	session.sentAt = time.Now()
+
+	return c.sendEvent(EventReviewQuestion, ReviewQuestionEvent{
+		Question:  item.Question.Question.Public(),
+		DueAt:     item.Due,
+		Remaining: len(session.queue),
+	})
+}
+
+// resolveStudent returns the student owning token, or creates a new student
+// named name when token is empty. The new student's token is returned; it is
+// empty for existing students.
+func (r *Room) resolveStudent(name, token string) (*storage.Student, string, error) {
+	ctx := context.Background()
+
+	if token != "" {
+		student, err := r.store.GetStudentByToken(ctx, hashToken(token))
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, "", ErrUnknownStudent
+		}
+
+		return student, "", err
+	}
+
+	id, err := newToken(participantIDBytes)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "unable to generate student id")
+	}
+
+	token, err = newToken(participantTokenBytes)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "unable to generate student token")
+	}
+
+	student := &storage.Student{
+		ID:        id,
+		Name:      name,
+		TokenHash: hashToken(token),
+		CreatedAt: time.Now(),
+	}
+
+	if err := r.store.CreateStudent(ctx, student); err != nil {
+		return nil, "", err
+	}
+
+	return student, token, nil
+}
+
+// recordReview grades the attempt on the SM-2 scale, reschedules the
+// student's card for the question and logs the attempt. window is the time
+// in which an answer counts as fast.
+func recordReview(store storage.Repository, entry *storage.ReviewLog, window time.Duration) (*storage.Review, error) {
+	review, err := scheduleReview(store, entry, window)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := store.RecordReview(context.Background(), review, entry); err != nil {
+		return nil, err
+	}
+
+	return review, nil
+}
+
+// scheduleReview grades the attempt and returns the student's rescheduled
+// card without saving it.
+func scheduleReview(store storage.Repository, entry *storage.ReviewLog, window time.Duration) (*storage.Review, error) {
+	review, err := store.GetReview(context.Background(), entry.StudentID, entry.BankQuestionID)
+	if errors.Is(err, storage.ErrNotFound) {
+		review = &storage.Review{
+			Card:           srs.NewCard(entry.ReviewedAt),
+			StudentID:      entry.StudentID,
+			BankQuestionID: entry.BankQuestionID,
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	entry.Quality = srs.Quality(entry.Correct, entry.ResponseTime, window)
+	review.Card = review.Card.Review(entry.Quality, entry.ReviewedAt)
+
+	return review, nil
+}
+
+// reviewWindow is the time limit of a bank question, or fallback for
+// questions without one.
+func reviewWindow(question *storage.BankQuestion, fallback time.Duration) time.Duration {
+	if question.DurationSecs > 0 {
+		return time.Duration(question.DurationSecs) * time.Second
+	}
+
+	return fallback
+}
@@ -0,0 +1,249 @@
+package ws
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+
+	"mnemo/clog"
+	"mnemo/services/poll"
+	"mnemo/services/quiz"
+	"mnemo/storage"
+)
+
+type RoomList map[string]*Room
+
+// RoomOptions holds the per-room game settings.
+type RoomOptions struct {
+	Scoring quiz.Scoring
+
+	// LeaderboardTopN is how many leaderboard entries students see
+	LeaderboardTopN       int
+	LeaderboardToStudents bool
+
+	// ReplayBufferSize is how many recent events are kept for replay
+	ReplayBufferSize int
+
+	// WordCloudTerms is how many of the most frequent words poll results
+	// report for word clouds
+	WordCloudTerms int
+
+	// MoodWindow is how long reactions count towards the room's mood, and
+	// MoodUpdateInterval how often at most it is pushed to the professors
+	MoodWindow         time.Duration
+	MoodUpdateInterval time.Duration
+
+	// MoodReactionRate is how many reactions a student may send per second
+	MoodReactionRate int
+}
+
+// Room is a single lecture session. Every client belongs to exactly one room
+// and all event routing is scoped to the members of that room, so several
+// lectures can run on the same server without seeing each other's messages.
+type Room struct {
+	ID        string
+	Professor string // owning professor
+	CreatedAt time.Time
+
+	options RoomOptions
+	store   storage.Repository
+
+	clients ClientList
+
+	// participants are keyed by ID, tokens (hashed) and names index the
+	// same set. A nil name entry reserves the name for a participant that is
+	// being saved.
+	participants map[string]*Participant
+	tokens       map[string]*Participant
+	names        map[string]*Participant // lowercased display name
+
+	rounds  map[string]*quiz.Round // keyed by question ID
+	current *quiz.Round            // most recently published question
+	sources map[string]string      // question ID -> bank question ID
+
+	// board holds the questions students asked, keyed by ID. boardWrites
+	// serializes changes to it, so they can be saved without holding the
+	// room lock; it is taken before the room lock.
+	board       map[string]*boardQuestion
+	boardWrites sync.Mutex
+
+	polls map[string]*poll.Tally // keyed by poll ID
+	poll  *poll.Tally            // most recently started poll
+
+	// mood holds the students' recent reactions
+	mood *moodState
+
+	// rooms started from a question bank step through playlist in order
+	bankID       string
+	playlist     []*storage.BankQuestion
+	bankPosition int
+
+	// done is closed when the room is removed; background work tied to the
+	// room (e.g. question timers) listens to it
+	done chan struct{}
+
+	// outbox sequences outgoing events and buffers them for replay
+	outbox *outbox
+
+	// dropped counts events that did not reach a member's queue
+	dropped atomic.Uint64
+
+	log clog.ICustomLog
+
+	sync sync.RWMutex
+}
+
+func NewRoom(id, professor string, options RoomOptions, store storage.Repository, logger clog.ICustomLog) *Room {
+	return &Room{
+		ID:        id,
+		Professor: professor,
+		CreatedAt: time.Now(),
+		options:   options,
+		store:     store,
+		clients:   make(ClientList),
+
+		participants: make(map[string]*Participant),
+		tokens:       make(map[string]*Participant),
+		names:        make(map[string]*Participant),
+
+		rounds:  make(map[string]*quiz.Round),
+		sources: make(map[string]string),
+		board:   make(map[string]*boardQuestion),
+		polls:   make(map[string]*poll.Tally),
+		mood:    newMoodState(),
+		outbox:  newOutbox(options.ReplayBufferSize, 0),
+		done:    make(chan struct{}),
+		log:     logger.With(zap.String("room", id)),
+	}
+}
+
+// addClient adds the client to the room. If lastSeq is set the events the
+// client missed since are replayed before any live event reaches it.
+func (r *Room) addClient(client *Client, lastSeq *uint64) error {
+	r.sync.Lock()
+	defer r.sync.Unlock()
+
+	r.clients[client] = true
+
+	if lastSeq != nil {
+		return r.replay(client, *lastSeq)
+	}
+
+	return nil
+}
+
+// removeClient removes the client from the room and reports whether it was
+// a member.
+func (r *Room) removeClient(client *Client) bool {
+	r.sync.Lock()
+	defer r.sync.Unlock()
+
+	if _, ok := r.clients[client]; !ok {
+		return false
+	}
+
+	delete(r.clients, client)
+
+	if p := client.participant; p != nil && p.client == client {
+		p.client = nil
+	}
+
+	return true
+}
+
+// Members returns the number of clients currently connected to the room.
+func (r *Room) Members() int {
+	r.sync.RLock()
+	defer r.sync.RUnlock()
+
+	return len(r.clients)
+}
+
+// Bank reports the question bank the room was started from and how far the
+// room has stepped through it. id is empty for rooms without a bank.
+func (r *Room) Bank() (id string, position, total int) {
+	r.sync.RLock()
+	defer r.sync.RUnlock()
+
+	return r.bankID, r.bankPosition, len(r.playlist)
+}
+
+// DroppedEvents returns how many events members of the room did not receive
+// because they could not keep up.
+func (r *Room) DroppedEvents() uint64 {
+	return r.dropped.Load()
+}
+
+// Participants returns the number of students that have joined the room,
+// including those currently disconnected.
+func (r *Room) Participants() int {
+	r.sync.RLock()
+	defer r.sync.RUnlock()
+
+	return len(r.participants)
+}
+
+// notifyProfessors sends an event to every professor connected to the room.
+func (r *Room) notifyProfessors(eventType string, payload any) error {
+	return r.notifyRole(RoleProfessor, eventType, payload)
+}
+
+// notifyAll sends an event to every professor and every joined student.
+func (r *Room) notifyAll(eventType string, payload any) error {
+	if err := r.notifyProfessors(eventType, payload); err != nil {
+		return err
+	}
+
+	return r.notifyStudents(eventType, payload)
+}
+
+// notifyStudents sends an event to every student connected to the room that
+// has completed the join handshake.
+func (r *Room) notifyStudents(eventType string, payload any) error {
+	return r.notifyRole(RoleStudent, eventType, payload)
+}
+
+func (r *Room) notifyRole(role, eventType string, payload any) error {
+	r.sync.RLock()
+	defer r.sync.RUnlock()
+
+	return r.notifyRoleLocked(role, eventType, payload)
+}
+
+// notifyRoleLocked is notifyRole for callers that hold the room lock, so the
+// event is queued in the same state it was built from.
+func (r *Room) notifyRoleLocked(role, eventType string, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s event: %v", eventType, err)
+	}
+
+	outgoingEvent := Event{
+		Type:    eventType,
+		Payload: data,
+	}
+
+	targets := make([]*Client, 0, len(r.clients))
+	for client := range r.clients {
+		if client.role != role {
+			continue
+		}
+		if role == RoleStudent && client.participant == nil {
+			continue
+		}
+		targets = append(targets, client)
+	}
+
+	if volatileEvents[eventType] {
+		r.fanout(outgoingEvent, targets)
+		return nil
+	}
+
+	r.deliver(outgoingEvent, role, "", targets)
+
+	return nil
+}
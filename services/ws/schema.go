@@ -0,0 +1,130 @@
+package ws
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+//go:embed schemas/*.json
+var schemaFiles embed.FS
+
+const envelopeSchema = "envelope"
+
+// schemas holds the compiled JSON Schema of the envelope and of every
+// inbound event payload, keyed by event type. An event type without a schema
+// is not accepted from clients. The payload schemas bound every string and
+// array; maxMessageSize leaves room for the largest payload they allow.
+var schemas = mustCompileSchemas()
+
+func mustCompileSchemas() map[string]*jsonschema.Schema {
+	names, err := fs.Glob(schemaFiles, "schemas/*.json")
+	if err != nil {
+		panic(err)
+	}
+
+	compiler := jsonschema.NewCompiler()
+	compiled := make(map[string]*jsonschema.Schema, len(names))
+
+	for _, name := range names {
+		data, err := schemaFiles.ReadFile(name)
+		if err != nil {
+			panic(err)
+		}
+
+		if err := compiler.AddResource(name, bytes.NewReader(data)); err != nil {
+			panic(errors.Wrapf(err, "invalid schema %s", name))
+		}
+
+		schema, err := compiler.Compile(name)
+		if err != nil {
+			panic(errors.Wrapf(err, "invalid schema %s", name))
+		}
+
+		compiled[strings.TrimSuffix(path.Base(name), ".json")] = schema
+	}
+
+	return compiled
+}
+
+// decodeEvent parses and validates an inbound message: first the envelope,
+// then the payload against the schema of its event type. Missing payloads
+// are treated as empty objects.
+func decodeEvent(data []byte) (Event, error) {
+	var event Event
+
+	var doc any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return event, newProtocolError(CodeInvalidJSON, "message is not valid JSON: %v", err)
+	}
+
+	if err := validate(envelopeSchema, doc); err != nil {
+		// the ID is still useful for correlation if it is there
+		if m, ok := doc.(map[string]any); ok {
+			event.ID, _ = m["id"].(string)
+		}
+		return event, err
+	}
+
+	if err := json.Unmarshal(data, &event); err != nil {
+		return event, newProtocolError(CodeInvalidJSON, "message is not valid JSON: %v", err)
+	}
+
+	if event.Version == 0 {
+		event.Version = ProtocolVersion
+	}
+
+	if event.Version != ProtocolVersion {
+		return event, newProtocolError(CodeUnsupportedVersion, "protocol version %d is not supported, use %d", event.Version, ProtocolVersion)
+	}
+
+	if len(event.Payload) == 0 || bytes.Equal(event.Payload, []byte("null")) {
+		event.Payload = json.RawMessage("{}")
+	}
+
+	if _, ok := schemas[event.Type]; !ok || event.Type == envelopeSchema {
+		return event, newProtocolError(CodeUnknownEvent, "unknown event type '%s'", event.Type)
+	}
+
+	var payload any
+	if err := json.Unmarshal(event.Payload, &payload); err != nil {
+		return event, newProtocolError(CodeInvalidJSON, "payload is not valid JSON: %v", err)
+	}
+
+	return event, validate(event.Type, payload)
+}
+
+func validate(schema string, doc any) error {
+	err := schemas[schema].Validate(doc)
+
+	var validationErr *jsonschema.ValidationError
+	if !errors.As(err, &validationErr) {
+		return err
+	}
+
+	// report the innermost causes, which name the offending field
+	var messages []string
+	for _, cause := range validationErr.BasicOutput().Errors {
+		if cause.Error == "" || strings.HasPrefix(cause.Error, "doesn't validate with") {
+			continue
+		}
+
+		location := cause.InstanceLocation
+		if location == "" {
+			location = "/"
+		}
+		messages = append(messages, location+": "+cause.Error)
+	}
+
+	if len(messages) == 0 {
+		messages = append(messages, validationErr.Message)
+	}
+
+	return newProtocolError(CodeInvalidPayload, "%s", strings.Join(messages, "; "))
+}
@@ -0,0 +1,135 @@
+package ws
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestDecodeEvent(t *testing.T) {
+	tests := []struct {
+		name     string
+		message  string
+		wantType string
+		wantCode string // empty if the event is valid
+	}{
+		{
+			name:     "valid",
+			message:  `{"version": 1, "id": "c-1", "type": "send_message", "payload": {"message": "hi"}}`,
+			wantType: EventSendMessage,
+		},
+		{
+			name:     "version defaults to the current one",
+			message:  `{"type": "send_message", "payload": {"message": "hi"}}`,
+			wantType: EventSendMessage,
+		},
+		{
+			name:     "missing payload is an empty object",
+			message:  `{"type": "next_question"}`,
+			wantType: EventNextQuestion,
+		},
+		{
+			name:     "null payload is an empty object",
+			message:  `{"type": "next_question", "payload": null}`,
+			wantType: EventNextQuestion,
+		},
+		{
+			name:     "not JSON",
+			message:  `{"type": `,
+			wantCode: CodeInvalidJSON,
+		},
+		{
+			name:     "missing type",
+			message:  `{"payload": {}}`,
+			wantCode: CodeInvalidPayload,
+		},
+		{
+			name:     "unsupported version",
+			message:  `{"version": 2, "type": "send_message", "payload": {"message": "hi"}}`,
+			wantCode: CodeUnsupportedVersion,
+		},
+		{
+			name:     "unknown type",
+			message:  `{"type": "launch_missiles"}`,
+			wantCode: CodeUnknownEvent,
+		},
+		{
+			name:     "the envelope is no event",
+			message:  `{"type": "envelope"}`,
+			wantCode: CodeUnknownEvent,
+		},
+		{
+			name:     "payload missing a required field",
+			message:  `{"type": "send_message", "payload": {}}`,
+			wantCode: CodeInvalidPayload,
+		},
+		{
+			name:     "payload with an unknown field",
+			message:  `{"type": "send_message", "payload": {"message": "hi", "admin": true}}`,
+			wantCode: CodeInvalidPayload,
+		},
+		{
+			name:     "payload field too long",
+			message:  fmt.Sprintf(`{"type": "send_message", "payload": {"message": %q}}`, strings.Repeat("a", 1001)),
+			wantCode: CodeInvalidPayload,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event, err := decodeEvent([]byte(tt.message))
+
+			if tt.wantCode == "" {
+				if err != nil {
+					t.Fatalf("decodeEvent: %v", err)
+				}
+				if event.Type != tt.wantType || event.Version != ProtocolVersion || len(event.Payload) == 0 {
+					t.Fatalf("decoded %+v", event)
+				}
+				return
+			}
+
+			var protocolErr *ProtocolError
+			if !errors.As(err, &protocolErr) || protocolErr.Code != tt.wantCode {
+				t.Fatalf("decodeEvent error = %v, want code %s", err, tt.wantCode)
+			}
+		})
+	}
+}
+
+// TestMaxMessageSize checks that the largest publish_question the schemas
+// accept fits in maxMessageSize even with every character escaped.
+func TestMaxMessageSize(t *testing.T) {
+	// one character outside the BMP, escaped as a surrogate pair
+	const char = `\ud83d\ude00`
+
+	str := func(n int) string {
+		return `"` + strings.Repeat(char, n) + `"`
+	}
+
+	options := make([]string, 20)
+	correct := make([]string, 20)
+	for i := range options {
+		options[i] = fmt.Sprintf(`{"id": %s, "text": %s}`, str(64), str(500))
+		correct[i] = str(64)
+	}
+
+	message := fmt.Sprintf(
+		`{"version": 1, "id": %s, "type": "publish_question", "payload": {"id": %s, "kind": "multi_select", "prompt": %s, "options": [%s], "correct": [%s], "explanation": %s, "duration_secs": 3600}}`,
+		str(64), str(64), str(2000), strings.Join(options, ", "), strings.Join(correct, ", "), str(2000),
+	)
+
+	if _, err := decodeEvent([]byte(message)); err != nil {
+		t.Fatalf("largest publish_question is invalid: %v", err)
+	}
+
+	if len(message) > maxMessageSize {
+		t.Fatalf("largest publish_question is %d bytes, more than maxMessageSize (%d)", len(message), maxMessageSize)
+	}
+
+	// one more character anywhere makes it invalid
+	if _, err := decodeEvent([]byte(strings.Replace(message, `"prompt": "`, `"prompt": "`+char, 1))); err == nil {
+		t.Fatal("prompt longer than the schema allows was accepted")
+	}
+}
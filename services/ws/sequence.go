@@ -0,0 +1,178 @@
+package ws
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// outboxEntry is a sequenced event together with who it was sent to, so a
+// replay only hands clients what they were entitled to see.
+type outboxEntry struct {
+	event Event
+	role  string
+
+	// participantID is set for events addressed to a single student
+	participantID string
+}
+
+// outbox numbers the room's outgoing events and keeps the most recent ones
+// in a ring buffer for replay to reconnecting clients. Sequence numbers are
+// per room and strictly increasing; a client sees gaps where events were
+// addressed to somebody else.
+type outbox struct {
+	seq     uint64
+	entries []outboxEntry // ring; entries[next] is the oldest once full
+	next    int
+	full    bool
+
+	sync sync.Mutex
+}
+
+func newOutbox(size int, seq uint64) *outbox {
+	return &outbox{
+		seq:     seq,
+		entries: make([]outboxEntry, size),
+	}
+}
+
+// record assigns the next sequence number to the event and remembers it. The
+// caller must hold the outbox lock.
+func (o *outbox) record(event *Event, role, participantID string) {
+	o.seq++
+	event.Seq = o.seq
+
+	if len(o.entries) == 0 {
+		return
+	}
+
+	o.entries[o.next] = outboxEntry{event: *event, role: role, participantID: participantID}
+	o.next = (o.next + 1) % len(o.entries)
+	if o.next == 0 {
+		o.full = true
+	}
+}
+
+// since returns the events after seq matching the audience, oldest first. ok
+// is false if events after seq already fell out of the buffer. The caller
+// must hold the outbox lock.
+func (o *outbox) since(seq uint64, role, participantID string) (events []Event, ok bool) {
+	if seq >= o.seq {
+		return nil, seq == o.seq
+	}
+
+	if len(o.entries) == 0 {
+		return nil, false
+	}
+
+	oldest := o.entries[0]
+	if o.full {
+		oldest = o.entries[o.next]
+	}
+
+	if oldest.event.Seq == 0 || oldest.event.Seq > seq+1 {
+		return nil, false
+	}
+
+	for i := 0; i < len(o.entries); i++ {
+		idx := i
+		if o.full {
+			idx = (o.next + i) % len(o.entries)
+		}
+
+		entry := o.entries[idx]
+		if entry.event.Seq == 0 {
+			break
+		}
+
+		if entry.event.Seq <= seq || entry.role != role {
+			continue
+		}
+
+		if entry.participantID != "" && entry.participantID != participantID {
+			continue
+		}
+
+		events = append(events, entry.event)
+	}
+
+	return events, true
+}
+
+// volatileEvents are only meaningful the moment they are sent; they are
+// neither sequenced nor replayed.
+var volatileEvents = map[string]bool{
+	EventTimerTick:          true,
+	EventServerShuttingDown: true,
+	EventMoodUpdated:        true,
+}
+
+// deliver sequences the event and queues it for targets. Holding the outbox
+// lock while queueing keeps every client's queue in sequence order.
+func (r *Room) deliver(event Event, role, participantID string, targets []*Client) {
+	r.outbox.sync.Lock()
+	defer r.outbox.sync.Unlock()
+
+	stamp(&event)
+	r.outbox.record(&event, role, participantID)
+
+	r.fanout(event, targets)
+}
+
+// replay queues the events the client missed after lastSeq. If they are no
+// longer buffered, or would not fit the client's queue, the client gets a
+// replay_incomplete event instead and has to resync its state.
+func (r *Room) replay(c *Client, lastSeq uint64) error {
+	participantID := ""
+	if c.participant != nil {
+		participantID = c.participant.ID
+	}
+
+	r.outbox.sync.Lock()
+	defer r.outbox.sync.Unlock()
+
+	events, ok := r.outbox.since(lastSeq, c.role, participantID)
+	if ok && len(events) <= cap(c.egress)-len(c.egress) {
+		for _, event := range events {
+			c.send(event)
+		}
+		return nil
+	}
+
+	data, err := json.Marshal(ReplayIncompleteEvent{LastSeq: lastSeq, Seq: r.outbox.seq})
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s event: %v", EventReplayIncomplete, err)
+	}
+
+	c.send(Event{Type: EventReplayIncomplete, Payload: data})
+	return nil
+}
+
+// currentSeq returns the sequence number of the room's latest event.
+func (r *Room) currentSeq() uint64 {
+	r.outbox.sync.Lock()
+	defer r.outbox.sync.Unlock()
+
+	return r.outbox.seq
+}
+
+// Ack records the highest sequence number the client has processed. A
+// student that resumes without last_seq is replayed everything after it;
+// professors always reconnect with an explicit last_seq.
+func Ack(event Event, c *Client) error {
+	var ackEvent AckEvent
+	if err := json.Unmarshal(event.Payload, &ackEvent); err != nil {
+		return badPayload(err)
+	}
+
+	if c.participant == nil {
+		return nil
+	}
+
+	c.room.sync.Lock()
+	defer c.room.sync.Unlock()
+
+	c.participant.acked = max(c.participant.acked, ackEvent.Seq)
+
+	return nil
+}
@@ -0,0 +1,236 @@
+package ws
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestOutboxSince(t *testing.T) {
+	type audience struct {
+		role          string
+		participantID string
+	}
+
+	var (
+		students  = audience{role: RoleStudent}
+		professor = audience{role: RoleProfessor}
+		alice     = audience{role: RoleStudent, participantID: "alice"}
+		bob       = audience{role: RoleStudent, participantID: "bob"}
+	)
+
+	repeat := func(a audience, n int) []audience {
+		events := make([]audience, n)
+		for i := range events {
+			events[i] = a
+		}
+		return events
+	}
+
+	tests := []struct {
+		name     string
+		size     int
+		seq      uint64 // sequence number the outbox starts at
+		recorded []audience
+
+		after  uint64
+		reader audience
+
+		want   []uint64
+		wantOK bool
+	}{
+		{
+			name:     "up to date",
+			size:     4,
+			recorded: repeat(students, 3),
+			after:    3,
+			reader:   students,
+			wantOK:   true,
+		},
+		{
+			name:     "ahead of the room",
+			size:     4,
+			recorded: repeat(students, 3),
+			after:    5,
+			reader:   students,
+		},
+		{
+			name:     "missed events",
+			size:     4,
+			recorded: repeat(students, 3),
+			after:    1,
+			reader:   students,
+			want:     []uint64{2, 3},
+			wantOK:   true,
+		},
+		{
+			name:     "everything",
+			size:     4,
+			recorded: repeat(students, 3),
+			reader:   students,
+			want:     []uint64{1, 2, 3},
+			wantOK:   true,
+		},
+		{
+			name:     "exactly full",
+			size:     4,
+			recorded: repeat(students, 4),
+			reader:   students,
+			want:     []uint64{1, 2, 3, 4},
+			wantOK:   true,
+		},
+		{
+			name:     "wraparound keeps order",
+			size:     4,
+			recorded: repeat(students, 6),
+			after:    2,
+			reader:   students,
+			want:     []uint64{3, 4, 5, 6},
+			wantOK:   true,
+		},
+		{
+			name:     "wraparound replays the newest",
+			size:     4,
+			recorded: repeat(students, 10),
+			after:    8,
+			reader:   students,
+			want:     []uint64{9, 10},
+			wantOK:   true,
+		},
+		{
+			name:     "missed events fell out of the buffer",
+			size:     4,
+			recorded: repeat(students, 6),
+			after:    1,
+			reader:   students,
+		},
+		{
+			name:     "students skip professor events",
+			size:     4,
+			recorded: []audience{students, professor, students},
+			reader:   students,
+			want:     []uint64{1, 3},
+			wantOK:   true,
+		},
+		{
+			name:     "professors skip student events",
+			size:     4,
+			recorded: []audience{students, professor, students},
+			reader:   professor,
+			want:     []uint64{2},
+			wantOK:   true,
+		},
+		{
+			name:     "nothing for the reader",
+			size:     4,
+			recorded: []audience{students, professor, students},
+			after:    2,
+			reader:   professor,
+			wantOK:   true,
+		},
+		{
+			name:     "events addressed to a participant",
+			size:     4,
+			recorded: []audience{students, alice, bob, students},
+			reader:   alice,
+			want:     []uint64{1, 2, 4},
+			wantOK:   true,
+		},
+		{
+			name:     "events addressed to another participant",
+			size:     4,
+			recorded: []audience{students, alice, bob, students},
+			reader:   bob,
+			want:     []uint64{1, 3, 4},
+			wantOK:   true,
+		},
+		{
+			name:     "participant events for a reader without participant",
+			size:     4,
+			recorded: []audience{students, alice, bob, students},
+			reader:   students,
+			want:     []uint64{1, 4},
+			wantOK:   true,
+		},
+		{
+			name:     "wraparound with gaps",
+			size:     3,
+			recorded: []audience{alice, bob, professor, alice, bob},
+			after:    2,
+			reader:   alice,
+			want:     []uint64{4},
+			wantOK:   true,
+		},
+		{
+			name:     "no buffer",
+			recorded: repeat(students, 2),
+			after:    1,
+			reader:   students,
+		},
+		{
+			name:     "no buffer up to date",
+			recorded: repeat(students, 2),
+			after:    2,
+			reader:   students,
+			wantOK:   true,
+		},
+		{
+			name:   "restored room up to date",
+			size:   4,
+			seq:    10,
+			after:  10,
+			reader: students,
+			wantOK: true,
+		},
+		{
+			name:   "restored room misses events from before the restart",
+			size:   4,
+			seq:    10,
+			after:  9,
+			reader: students,
+		},
+		{
+			name:     "restored room replays events since the restart",
+			size:     4,
+			seq:      10,
+			recorded: repeat(students, 2),
+			after:    10,
+			reader:   students,
+			want:     []uint64{11, 12},
+			wantOK:   true,
+		},
+		{
+			name:     "restored room with events before and since the restart",
+			size:     4,
+			seq:      10,
+			recorded: repeat(students, 2),
+			after:    9,
+			reader:   students,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := newOutbox(tt.size, tt.seq)
+
+			for i, a := range tt.recorded {
+				event := Event{Type: EventNewMessage}
+				o.record(&event, a.role, a.participantID)
+
+				if want := tt.seq + uint64(i) + 1; event.Seq != want {
+					t.Fatalf("event %d sequenced %d, want %d", i, event.Seq, want)
+				}
+			}
+
+			events, ok := o.since(tt.after, tt.reader.role, tt.reader.participantID)
+
+			var got []uint64
+			for _, event := range events {
+				got = append(got, event.Seq)
+			}
+
+			if ok != tt.wantOK || !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("since(%d) = %v, %v; want %v, %v", tt.after, got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package ws
+
+import (
+	"context"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// errShuttingDown is the response to connections attempted while the
+// manager drains.
+const errShuttingDown = "server shutting down"
+
+// drainState tracks the clients connected to this instance, whatever they
+// are connected to, so they can be told when it shuts down.
+type drainState struct {
+	clients  ClientList
+	draining bool
+
+	// empty is closed once the manager drains and its last client is gone
+	empty chan struct{}
+
+	sync sync.Mutex
+}
+
+func newDrainState() *drainState {
+	return &drainState{
+		clients: make(ClientList),
+		empty:   make(chan struct{}),
+	}
+}
+
+// Draining reports whether the manager is shutting down; it accepts no new
+// clients then.
+func (m *Manager) Draining() bool {
+	m.drainer.sync.Lock()
+	defer m.drainer.sync.Unlock()
+
+	return m.drainer.draining
+}
+
+// refuseDraining writes the error response and returns true if the manager
+// is shutting down.
+func (m *Manager) refuseDraining(w http.ResponseWriter) bool {
+	if !m.Draining() {
+		return false
+	}
+
+	w.Header().Set("Retry-After", "1")
+	http.Error(w, errShuttingDown, http.StatusServiceUnavailable)
+	return true
+}
+
+// track registers a client connected to this instance. A client that slips
+// in while the manager drains is sent away right away.
+func (m *Manager) track(client *Client) {
+	m.drainer.sync.Lock()
+	draining := m.drainer.draining
+	if !draining {
+		m.drainer.clients[client] = true
+	}
+	m.drainer.sync.Unlock()
+
+	if draining {
+		m.sendAway(client)
+	}
+}
+
+func (m *Manager) untrack(client *Client) {
+	m.drainer.sync.Lock()
+	defer m.drainer.sync.Unlock()
+
+	if !m.drainer.clients[client] {
+		return
+	}
+
+	delete(m.drainer.clients, client)
+
+	if m.drainer.draining && len(m.drainer.clients) == 0 {
+		close(m.drainer.empty)
+	}
+}
+
+// drain tells every client that the server shuts down, closes their
+// connections once their queued events are sent, and saves the hosted rooms.
+// It is safe to call more than once.
+func (m *Manager) drain() {
+	m.drainer.sync.Lock()
+	if m.drainer.draining {
+		m.drainer.sync.Unlock()
+		return
+	}
+
+	m.drainer.draining = true
+
+	clients := make([]*Client, 0, len(m.drainer.clients))
+	for client := range m.drainer.clients {
+		clients = append(clients, client)
+	}
+
+	if len(clients) == 0 {
+		close(m.drainer.empty)
+	}
+	m.drainer.sync.Unlock()
+
+	m.log.Info("draining clients", zap.Int("clients", len(clients)))
+
+	for _, client := range clients {
+		m.sendAway(client)
+	}
+
+	for _, room := range m.Rooms() {
+		// clients relayed here go back to their own instance, which can
+		// reconnect them to wherever the room is hosted next
+		room.sync.RLock()
+		var remotes []*Client
+		for client := range room.clients {
+			if _, ok := client.connection.(*remoteConn); ok {
+				remotes = append(remotes, client)
+			}
+		}
+		room.sync.RUnlock()
+
+		for _, client := range remotes {
+			m.sendAway(client)
+		}
+
+		if err := room.persist(); err != nil {
+			room.log.Error("unable to persist room", zap.Error(err))
+		}
+	}
+}
+
+// sendAway tells the client to reconnect later and closes its connection.
+// Reconnects are spread over the configured window.
+func (m *Manager) sendAway(client *Client) {
+	var after time.Duration
+	if window := m.config.ShutdownReconnectWindow; window > 0 {
+		after = rand.N(window)
+	}
+
+	if err := client.sendEvent(EventServerShuttingDown, ServerShuttingDownEvent{ReconnectAfterMs: after.Milliseconds()}); err != nil {
+		client.log.Error("unable to send event", zap.String("type", EventServerShuttingDown), zap.Error(err))
+	}
+
+	client.shutdown()
+}
+
+// Stop drains the clients if that did not happen yet, waits for them to
+// disconnect and for the messages to other instances to go out, then ends
+// hosting and relaying rooms. The room leases are released, so other
+// instances can adopt the rooms right away.
+func (m *Manager) Stop(ctx context.Context) error {
+	m.drain()
+
+	select {
+	case <-m.drainer.empty:
+	case <-ctx.Done():
+		m.drainer.sync.Lock()
+		m.log.Warn("clients still connected at shutdown", zap.Int("clients", len(m.drainer.clients)))
+		m.drainer.sync.Unlock()
+	}
+
+	m.relay.sync.Lock()
+	links := make([]*relayLink, 0, len(m.relay.links))
+	for _, link := range m.relay.links {
+		links = append(links, link)
+	}
+	cancel := m.relay.cancel
+	m.relay.sync.Unlock()
+
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+
+	for _, link := range links {
+		for link.pending.Load() > 0 && ctx.Err() == nil {
+			<-ticker.C
+		}
+	}
+
+	cancel()
+
+	for _, room := range m.Rooms() {
+		m.releaseLease(context.WithoutCancel(ctx), room.ID, room.Professor)
+	}
+
+	return ctx.Err()
+}
@@ -0,0 +1,131 @@
+package ws
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// disconnectOnClose removes the client once it is told to close, as its
+// transport would after flushing the egress queue.
+func disconnectOnClose(m *Manager, c *Client) {
+	go func() {
+		<-c.closing
+		m.removeClient(c)
+	}()
+}
+
+func TestStop(t *testing.T) {
+	tests := []struct {
+		name     string
+		clients  int
+		stuck    int // clients that never disconnect
+		wantErr  error
+		wantAway int // clients sent away
+	}{
+		{name: "no clients"},
+		{name: "clients disconnect", clients: 3, wantAway: 3},
+		{name: "a client does not disconnect", clients: 2, stuck: 1, wantErr: context.DeadlineExceeded, wantAway: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testConfig()
+			cfg.ShutdownReconnectWindow = 100 * time.Millisecond
+
+			m := newTestManagerConfig(t, cfg)
+
+			room, err := m.CreateRoom(context.Background(), testProfessor, "")
+			if err != nil {
+				t.Fatalf("CreateRoom: %v", err)
+			}
+
+			var clients []*Client
+			for i := 0; i < tt.clients+tt.stuck; i++ {
+				client := connect(t, m, room, RoleStudent)
+				received(client)
+
+				if i < tt.clients {
+					disconnectOnClose(m, client)
+				}
+				clients = append(clients, client)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+			defer cancel()
+
+			if err := m.Stop(ctx); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Stop = %v, want %v", err, tt.wantErr)
+			}
+
+			away := 0
+			for _, client := range clients {
+				var event ServerShuttingDownEvent
+				if !lastEvent(t, received(client), EventServerShuttingDown, &event) {
+					continue
+				}
+				away++
+
+				if event.ReconnectAfterMs < 0 || event.ReconnectAfterMs >= cfg.ShutdownReconnectWindow.Milliseconds() {
+					t.Errorf("reconnect after %dms, want within %v", event.ReconnectAfterMs, cfg.ShutdownReconnectWindow)
+				}
+
+				select {
+				case <-client.closing:
+				default:
+					t.Error("client sent away without closing its connection")
+				}
+			}
+
+			if away != tt.wantAway {
+				t.Errorf("%d clients sent away, want %d", away, tt.wantAway)
+			}
+			if !m.Draining() {
+				t.Error("manager not draining after Stop")
+			}
+		})
+	}
+}
+
+func TestDraining(t *testing.T) {
+	m := newTestManager(t)
+
+	room, err := m.CreateRoom(context.Background(), testProfessor, "")
+	if err != nil {
+		t.Fatalf("CreateRoom: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	if m.refuseDraining(rec) {
+		t.Fatal("connection refused before the manager drains")
+	}
+
+	m.drain()
+	m.drain()
+
+	rec = httptest.NewRecorder()
+	if !m.refuseDraining(rec) {
+		t.Fatal("connection accepted while the manager drains")
+	}
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("refused with %d, Retry-After %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+
+	// a client that slips in is sent away right away
+	late := NewClient(&testConn{}, m, room, RoleStudent)
+
+	var event ServerShuttingDownEvent
+	if !lastEvent(t, received(late), EventServerShuttingDown, &event) {
+		t.Error("late client not told about the shutdown")
+	}
+
+	select {
+	case <-late.closing:
+	default:
+		t.Error("late client kept its connection")
+	}
+}
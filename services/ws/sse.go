@@ -0,0 +1,259 @@
+package ws
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+const streamSessionBytes = 16
+
+// eventStream is the fallback transport for networks that break websockets.
+// Events reach the client as Server-Sent Events on a long-lived response,
+// and the client posts its own events, one per request, with the session ID
+// it got in the stream_opened event.
+type eventStream struct {
+	session string
+	client  *Client
+
+	done      chan struct{}
+	closeOnce sync.Once
+
+	// inbound serializes posted events; handlers expect to see one message
+	// at a time like on a websocket
+	inbound sync.Mutex
+}
+
+func (s *eventStream) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.done)
+	})
+
+	return nil
+}
+
+// ServeSSE opens an event stream to the room given by the "room" query
+// parameter, e.g. /sse?room=lecture-1. It accepts the same parameters and
+// tokens as ServeWs.
+func (m *Manager) ServeSSE(w http.ResponseWriter, r *http.Request) {
+	req, ok := m.roomRequest(w, r)
+	if !ok {
+		return
+	}
+
+	stream, ok := m.openStream(w)
+	if !ok {
+		return
+	}
+
+	client := NewClient(stream, m, req.room, req.role)
+	client.name = req.name
+	client.log = client.log.With(zap.String("transport", "sse"))
+
+	m.serveStream(w, r, stream, client, func() error {
+		return m.connect(client, req)
+	})
+}
+
+// ServeSSEReview opens an event stream for a self-paced review session. It
+// accepts the same tokens as ServeReview.
+func (m *Manager) ServeSSEReview(w http.ResponseWriter, r *http.Request) {
+	student, ok := m.reviewRequest(w, r)
+	if !ok {
+		return
+	}
+
+	stream, ok := m.openStream(w)
+	if !ok {
+		return
+	}
+
+	client := newReviewClient(stream, m, student)
+	client.log = client.log.With(zap.String("transport", "sse"))
+
+	m.serveStream(w, r, stream, client, func() error {
+		client.log.Info("review client connected")
+		return nil
+	})
+}
+
+// ServeSSEPost handles an event posted by the client of the stream given by
+// the "session" query parameter. The event is routed exactly like a
+// websocket message; its outcome, including errors, arrives on the stream.
+func (m *Manager) ServeSSEPost(w http.ResponseWriter, r *http.Request) {
+	m.sync.RLock()
+	stream, ok := m.streams[r.URL.Query().Get("session")]
+	m.sync.RUnlock()
+
+	if !ok {
+		http.Error(w, "unknown session", http.StatusNotFound)
+		return
+	}
+
+	// posted events are held to the same limit as websocket messages
+	payload, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxMessageSize))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "message too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		http.Error(w, "unable to read message", http.StatusBadRequest)
+		return
+	}
+
+	stream.inbound.Lock()
+	defer stream.inbound.Unlock()
+
+	select {
+	case <-stream.done:
+		http.Error(w, "session closed", http.StatusGone)
+		return
+	default:
+	}
+
+	stream.client.handleMessage(payload)
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// openStream starts the event stream response. On failure it writes the error
+// response and returns false.
+func (m *Manager) openStream(w http.ResponseWriter) (*eventStream, bool) {
+	session, err := newToken(streamSessionBytes)
+	if err != nil {
+		http.Error(w, "unable to create session", http.StatusInternalServerError)
+		return nil, false
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	// keep reverse proxies from buffering the stream
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	if err := http.NewResponseController(w).Flush(); err != nil {
+		m.log.Error("event streams are not supported by the response writer", zap.Error(err))
+		return nil, false
+	}
+
+	return &eventStream{session: session, done: make(chan struct{})}, true
+}
+
+// serveStream registers the stream's session, runs join once the client can
+// be reached and writes events until the client or the server ends the
+// stream.
+func (m *Manager) serveStream(w http.ResponseWriter, r *http.Request, stream *eventStream, client *Client, join func() error) {
+	stream.client = client
+
+	m.sync.Lock()
+	m.streams[stream.session] = stream
+	m.sync.Unlock()
+
+	defer func() {
+		m.sync.Lock()
+		delete(m.streams, stream.session)
+		m.sync.Unlock()
+
+		stream.Close()
+
+		// wait for a posted event still being handled, so it can't act on
+		// the client after it left the room
+		stream.inbound.Lock()
+		m.removeClient(client)
+		stream.inbound.Unlock()
+	}()
+
+	data, err := json.Marshal(StreamOpenedEvent{Session: stream.session})
+	if err != nil {
+		client.log.Error("unable to marshal event", zap.String("type", EventStreamOpened), zap.Error(err))
+		return
+	}
+
+	// queued first, so it precedes any replayed event
+	client.send(Event{Type: EventStreamOpened, Payload: data})
+
+	if err := join(); err != nil {
+		client.log.Error("unable to connect client", zap.Error(err))
+		return
+	}
+
+	client.writeStream(w, r, stream)
+}
+
+func (c *Client) writeStream(w http.ResponseWriter, r *http.Request, stream *eventStream) {
+	rc := http.NewResponseController(w)
+
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+
+	write := func(frame string) bool {
+		if err := rc.SetWriteDeadline(time.Now().Add(writeWait)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+			c.log.Debug("unable to set write deadline", zap.Error(err))
+			return false
+		}
+
+		if _, err := io.WriteString(w, frame); err != nil {
+			c.log.Debug("unable to send event", zap.Error(err))
+			return false
+		}
+
+		if err := rc.Flush(); err != nil {
+			c.log.Debug("unable to send event", zap.Error(err))
+			return false
+		}
+
+		return true
+	}
+
+	send := func(message Event) bool {
+		data, err := json.Marshal(message)
+		if err != nil {
+			c.log.Error("unable to marshal event", zap.String("type", message.Type), zap.Error(err))
+			return false
+		}
+
+		// the sequence number doubles as the event ID, so a reconnecting
+		// EventSource sends it back as Last-Event-ID
+		frame := fmt.Sprintf("data: %s\n\n", data)
+		if message.Seq > 0 {
+			frame = fmt.Sprintf("id: %d\n%s", message.Seq, frame)
+		}
+
+		return write(frame)
+	}
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-stream.done:
+			return
+		case message := <-c.egress:
+			if !send(message) {
+				return
+			}
+		case <-c.closing:
+			// flush what is queued so far; ending the response closes the
+			// stream
+			for len(c.egress) > 0 {
+				if !send(<-c.egress) {
+					return
+				}
+			}
+			return
+		case <-ticker.C:
+			// comments keep proxies from timing out an idle stream
+			if !write(": ping\n\n") {
+				return
+			}
+		}
+	}
+}
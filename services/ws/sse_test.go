@@ -0,0 +1,226 @@
+package ws
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testStream reads Server-Sent Events from an open stream.
+type testStream struct {
+	events chan streamFrame
+	cancel context.CancelFunc
+}
+
+// streamFrame is one event of the stream with the ID it was sent under.
+type streamFrame struct {
+	id    string
+	event Event
+}
+
+// openTestStream connects to the event stream at url.
+func openTestStream(t *testing.T, url string) *testStream {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET %s: %v", url, err)
+	}
+
+	if ct := resp.Header.Get("Content-Type"); resp.StatusCode != http.StatusOK || ct != "text/event-stream" {
+		t.Fatalf("GET %s = %d with content type %q", url, resp.StatusCode, ct)
+	}
+
+	s := &testStream{events: make(chan streamFrame, 64), cancel: cancel}
+	t.Cleanup(s.cancel)
+
+	go func() {
+		defer resp.Body.Close()
+		defer close(s.events)
+
+		var frame streamFrame
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			line := scanner.Text()
+
+			switch {
+			case strings.HasPrefix(line, "id: "):
+				frame.id = strings.TrimPrefix(line, "id: ")
+			case strings.HasPrefix(line, "data: "):
+				if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &frame.event); err != nil {
+					return
+				}
+			case line == "" && frame.event.Type != "":
+				s.events <- frame
+				frame = streamFrame{}
+			}
+		}
+	}()
+
+	return s
+}
+
+// next returns the next event of the given type, skipping others.
+func (s *testStream) next(t *testing.T, eventType string) streamFrame {
+	t.Helper()
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case frame, ok := <-s.events:
+			if !ok {
+				t.Fatalf("stream ended before %s", eventType)
+			}
+			if frame.event.Type == eventType {
+				return frame
+			}
+		case <-timeout:
+			t.Fatalf("no %s on the stream", eventType)
+		}
+	}
+}
+
+// post sends an event on the stream's session and returns the status.
+func post(t *testing.T, url, session, body string) int {
+	t.Helper()
+
+	resp, err := http.Post(url+"/sse/send?session="+session, "application/json", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("POST: %v", err)
+	}
+	resp.Body.Close()
+
+	return resp.StatusCode
+}
+
+func newSSEServer(t *testing.T) (*Manager, *Room, string) {
+	t.Helper()
+
+	m := newTestManager(t)
+
+	room, err := m.CreateRoom(context.Background(), testProfessor, "")
+	if err != nil {
+		t.Fatalf("CreateRoom: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /sse", m.ServeSSE)
+	mux.HandleFunc("POST /sse/send", m.ServeSSEPost)
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	return m, room, server.URL
+}
+
+func TestServeSSE(t *testing.T) {
+	m, room, url := newSSEServer(t)
+
+	stream := openTestStream(t, url+"/sse?room="+room.ID)
+
+	var opened StreamOpenedEvent
+	if err := json.Unmarshal(stream.next(t, EventStreamOpened).event.Payload, &opened); err != nil {
+		t.Fatalf("decode stream_opened: %v", err)
+	}
+	if opened.Session == "" {
+		t.Fatal("stream_opened without a session")
+	}
+
+	tests := []struct {
+		name       string
+		session    string
+		body       string
+		wantStatus int
+		wantEvent  string // on the stream after the post
+	}{
+		{
+			name:       "join",
+			session:    opened.Session,
+			body:       `{"version":1,"type":"join_room","payload":{"name":"Ada"}}`,
+			wantStatus: http.StatusAccepted,
+			wantEvent:  EventRoomJoined,
+		},
+		{
+			name:       "invalid event is reported on the stream",
+			session:    opened.Session,
+			body:       `{"version":1,"id":"c-1","type":"no_such_event","payload":{}}`,
+			wantStatus: http.StatusAccepted,
+			wantEvent:  EventError,
+		},
+		{
+			name:       "unknown session",
+			session:    "forged",
+			body:       `{"version":1,"type":"join_room","payload":{"name":"Bob"}}`,
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "message too large",
+			session:    opened.Session,
+			body:       `{"version":1,"type":"ask_question","payload":{"text":"` + strings.Repeat("a", maxMessageSize) + `"}}`,
+			wantStatus: http.StatusRequestEntityTooLarge,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status := post(t, url, tt.session, tt.body); status != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", status, tt.wantStatus)
+			}
+
+			if tt.wantEvent == "" {
+				return
+			}
+
+			frame := stream.next(t, tt.wantEvent)
+
+			// replayable events carry their sequence number as the event
+			// ID, so a reconnecting EventSource resumes after it
+			if frame.event.Seq > 0 && frame.id != strconv.FormatUint(frame.event.Seq, 10) {
+				t.Errorf("event id = %q, want %d", frame.id, frame.event.Seq)
+			}
+		})
+	}
+
+	room.sync.RLock()
+	participants := len(room.participants)
+	room.sync.RUnlock()
+
+	if participants != 1 {
+		t.Errorf("room has %d participants, want 1", participants)
+	}
+
+	// closing the stream ends the session
+	stream.cancel()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		m.sync.RLock()
+		_, open := m.streams[opened.Session]
+		m.sync.RUnlock()
+
+		if !open {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("session outlived its stream")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if status := post(t, url, opened.Session, `{"version":1,"type":"ask_question","payload":{"text":"still there?"}}`); status != http.StatusNotFound {
+		t.Errorf("post after close = %d, want %d", status, http.StatusNotFound)
+	}
+}
@@ -0,0 +1,75 @@
+package ws
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+
+	"mnemo/services/quiz"
+)
+
+// runTimer drives a timed question from the server clock: it broadcasts a
+// timer_tick every interval and closes the round once its deadline passes.
+// It returns early when the round is closed some other way or the room goes
+// away.
+func (r *Room) runTimer(round *quiz.Round, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	deadline := time.NewTimer(time.Until(round.Deadline))
+	defer deadline.Stop()
+
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-deadline.C:
+			if err := r.closeRound(round); err != nil {
+				r.log.Error("unable to close question", zap.String("question", round.Question.ID), zap.Error(err))
+			}
+			return
+		case now := <-ticker.C:
+			if round.Closed() {
+				return
+			}
+
+			tick := TimerTickEvent{
+				QuestionID:  round.Question.ID,
+				RemainingMs: round.Remaining(now).Milliseconds(),
+				Deadline:    round.Deadline,
+			}
+
+			if err := r.notifyAll(EventTimerTick, tick); err != nil {
+				r.log.Error("unable to send timer tick", zap.String("question", round.Question.ID), zap.Error(err))
+			}
+		}
+	}
+}
+
+// closeRound stops the round from accepting answers, scores it and reveals
+// the correct answer together with the aggregate results and the updated
+// leaderboard to the whole room. Closing an already closed round is a no-op.
+func (r *Room) closeRound(round *quiz.Round) error {
+	if !round.Close() {
+		return nil
+	}
+
+	r.scoreRound(round)
+
+	if err := r.saveQuestion(round); err != nil {
+		return err
+	}
+
+	if err := r.notifyAll(EventQuestionClosed, QuestionClosedEvent{
+		QuestionID:  round.Question.ID,
+		Correct:     round.Question.Correct,
+		Explanation: round.Question.Explanation,
+		Results:     round.Results(),
+	}); err != nil {
+		return err
+	}
+
+	r.publishLeaderboard(round.Question.ID)
+
+	return nil
+}
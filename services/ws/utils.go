@@ -1,25 +1,20 @@
 package ws
 
 import (
-	"log"
+	"crypto/rand"
+	"encoding/hex"
+	"math/big"
 	"net/http"
-	"time"
+	"strings"
 )
 
-func sendWithRetry(ch chan Event, msg Event) {
-	const maxRetries = 5
-	retryDelay := 1 * time.Second
-	for i := 0; i < maxRetries; i++ {
-		select {
-		case ch <- msg:
-			return // sent successfully.
-		default:
-			log.Printf("retry sending message, attempt %d", i)
-			time.Sleep(retryDelay)
-		}
-	}
-	log.Println("failed to send message after retries")
-}
+const (
+	roomCodeLength = 6
+	// ambiguous characters (0/O, 1/I/L) are left out so codes can be read
+	// off a projector and typed on a phone without mistakes
+	roomCodeAlphabet    = "ABCDEFGHJKMNPQRSTUVWXYZ23456789"
+	maxRoomCodeAttempts = 10
+)
 
 func checkOrigin(r *http.Request) bool {
 	origin := r.Header.Get("Origin")
@@ -32,3 +27,33 @@ func checkOrigin(r *http.Request) bool {
 		return false
 	}
 }
+
+func newRoomCode() (string, error) {
+	code := make([]byte, roomCodeLength)
+	max := big.NewInt(int64(len(roomCodeAlphabet)))
+
+	for i := range code {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		code[i] = roomCodeAlphabet[n.Int64()]
+	}
+
+	return string(code), nil
+}
+
+// NormalizeRoomCode turns user input into the canonical room code form.
+func NormalizeRoomCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// newToken returns n random bytes, hex encoded.
+func newToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,108 @@
+package ws
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+)
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	//CheckOrigin: checkOrigin
+}
+
+func (c *Client) readMessages(conn *websocket.Conn) {
+	defer func() {
+		// cleanup connection
+		c.manager.removeClient(c)
+	}()
+
+	if err := conn.SetReadDeadline(time.Now().Add(pongWait)); err != nil {
+		c.log.Debug("unable to set read deadline", zap.Error(err))
+		return
+	}
+
+	conn.SetReadLimit(maxMessageSize)
+
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	for {
+		_, payload, err := conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+				c.log.Debug("connection closed unexpectedly", zap.Error(err))
+			}
+			break
+		}
+
+		c.handleMessage(payload)
+	}
+}
+
+func (c *Client) writeMessages(conn *websocket.Conn) {
+	defer func() {
+		// cleanup connection
+		c.manager.removeClient(c)
+	}()
+
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+
+	write := func(message Event) bool {
+		data, err := json.Marshal(message)
+		if err != nil {
+			c.log.Error("unable to marshal event", zap.String("type", message.Type), zap.Error(err))
+			return false
+		}
+
+		if err := conn.SetWriteDeadline(time.Now().Add(writeWait)); err != nil {
+			c.log.Debug("unable to set write deadline", zap.Error(err))
+			return false
+		}
+
+		if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
+			c.log.Debug("unable to send event", zap.String("type", message.Type), zap.Error(err))
+			return false
+		}
+
+		return true
+	}
+
+	for {
+		select {
+		case message := <-c.egress:
+			if !write(message) {
+				return
+			}
+		case <-c.closing:
+			// flush what is queued so far, then close properly
+			for len(c.egress) > 0 {
+				if !write(<-c.egress) {
+					return
+				}
+			}
+
+			closeMessage := websocket.FormatCloseMessage(websocket.CloseGoingAway, "")
+			if err := conn.WriteControl(websocket.CloseMessage, closeMessage, time.Now().Add(writeWait)); err != nil {
+				c.log.Debug("unable to send close frame", zap.Error(err))
+			}
+			return
+		case <-ticker.C:
+			// send ping to client to keep connection alive
+			if err := conn.SetWriteDeadline(time.Now().Add(writeWait)); err != nil {
+				c.log.Debug("unable to set write deadline", zap.Error(err))
+				return
+			}
+
+			if err := conn.WriteMessage(websocket.PingMessage, []byte(``)); err != nil {
+				c.log.Debug("unable to send ping", zap.Error(err))
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,53 @@
+CREATE TABLE instructors (
+    id            TEXT PRIMARY KEY,
+    email         TEXT NOT NULL UNIQUE,
+    name          TEXT NOT NULL DEFAULT '',
+    password_hash TEXT NOT NULL
+);
+
+CREATE TABLE rooms (
+    id         TEXT PRIMARY KEY,
+    professor  TEXT NOT NULL,
+    created_at DATETIME NOT NULL,
+    closed_at  DATETIME
+);
+
+CREATE TABLE participants (
+    id         TEXT PRIMARY KEY,
+    room_id    TEXT NOT NULL REFERENCES rooms (id),
+    name       TEXT NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE,
+    score      INTEGER NOT NULL DEFAULT 0,
+    streak     INTEGER NOT NULL DEFAULT 0,
+    joined_at  DATETIME NOT NULL
+);
+
+CREATE INDEX participants_room_id ON participants (room_id);
+
+CREATE TABLE questions (
+    id           TEXT PRIMARY KEY,
+    room_id      TEXT NOT NULL REFERENCES rooms (id),
+    kind         TEXT NOT NULL,
+    prompt       TEXT NOT NULL,
+    options      TEXT NOT NULL, -- JSON array of quiz.Option
+    correct      TEXT NOT NULL, -- JSON array of option IDs
+    published_at DATETIME NOT NULL,
+    deadline     DATETIME,
+    closed_at    DATETIME
+);
+
+CREATE INDEX questions_room_id ON questions (room_id);
+
+CREATE TABLE answers (
+    participant_id   TEXT NOT NULL REFERENCES participants (id),
+    question_id      TEXT NOT NULL REFERENCES questions (id),
+    room_id          TEXT NOT NULL REFERENCES rooms (id),
+    choices          TEXT NOT NULL, -- JSON array of option IDs
+    correct          BOOLEAN NOT NULL,
+    points           INTEGER NOT NULL DEFAULT 0,
+    submitted_at     DATETIME NOT NULL,
+    response_time_ms INTEGER NOT NULL,
+    PRIMARY KEY (participant_id, question_id)
+);
+
+CREATE INDEX answers_room_id ON answers (room_id);
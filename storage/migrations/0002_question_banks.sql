@@ -0,0 +1,33 @@
+CREATE TABLE banks (
+    id          TEXT PRIMARY KEY,
+    owner       TEXT NOT NULL,
+    name        TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    created_at  DATETIME NOT NULL,
+    updated_at  DATETIME NOT NULL
+);
+
+CREATE INDEX banks_owner ON banks (owner);
+
+CREATE TABLE bank_questions (
+    id            TEXT PRIMARY KEY,
+    bank_id       TEXT NOT NULL REFERENCES banks (id) ON DELETE CASCADE,
+    position      INTEGER NOT NULL,
+    kind          TEXT NOT NULL,
+    prompt        TEXT NOT NULL,
+    options       TEXT NOT NULL, -- JSON array of quiz.Option
+    correct       TEXT NOT NULL, -- JSON array of option IDs
+    explanation   TEXT NOT NULL DEFAULT '',
+    tags          TEXT NOT NULL DEFAULT '[]', -- JSON array of strings
+    difficulty    TEXT NOT NULL DEFAULT '',
+    duration_secs INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX bank_questions_bank_id ON bank_questions (bank_id, position);
+
+-- rooms can be started from a bank and step through its questions in order
+ALTER TABLE rooms ADD COLUMN bank_id TEXT;
+ALTER TABLE rooms ADD COLUMN bank_position INTEGER NOT NULL DEFAULT 0;
+
+ALTER TABLE questions ADD COLUMN explanation TEXT NOT NULL DEFAULT '';
+ALTER TABLE questions ADD COLUMN bank_question_id TEXT;
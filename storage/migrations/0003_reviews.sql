@@ -0,0 +1,38 @@
+-- students are identities that outlive a single room, so their answers can
+-- feed spaced-repetition reviews across lectures
+CREATE TABLE students (
+    id         TEXT PRIMARY KEY,
+    name       TEXT NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE,
+    created_at DATETIME NOT NULL
+);
+
+ALTER TABLE participants ADD COLUMN student_id TEXT REFERENCES students (id);
+
+-- SM-2 scheduling state per student and bank question
+CREATE TABLE reviews (
+    student_id       TEXT NOT NULL REFERENCES students (id),
+    bank_question_id TEXT NOT NULL REFERENCES bank_questions (id) ON DELETE CASCADE,
+    repetitions      INTEGER NOT NULL,
+    interval_days    INTEGER NOT NULL,
+    ease             REAL NOT NULL,
+    due_at           DATETIME NOT NULL,
+    last_reviewed_at DATETIME,
+    PRIMARY KEY (student_id, bank_question_id)
+);
+
+CREATE INDEX reviews_due ON reviews (student_id, due_at);
+
+-- every graded attempt, from lectures (room_id set) and self-paced review
+CREATE TABLE review_log (
+    id               INTEGER PRIMARY KEY AUTOINCREMENT,
+    student_id       TEXT NOT NULL REFERENCES students (id),
+    bank_question_id TEXT NOT NULL REFERENCES bank_questions (id) ON DELETE CASCADE,
+    room_id          TEXT REFERENCES rooms (id),
+    correct          BOOLEAN NOT NULL,
+    quality          INTEGER NOT NULL,
+    response_time_ms INTEGER NOT NULL,
+    reviewed_at      DATETIME NOT NULL
+);
+
+CREATE INDEX review_log_student ON review_log (student_id, reviewed_at);
@@ -0,0 +1,20 @@
+-- questions students ask on a room's Q&A board, as opposed to the questions
+-- the professor asks them
+CREATE TABLE student_questions (
+    id             TEXT PRIMARY KEY,
+    room_id        TEXT NOT NULL REFERENCES rooms (id),
+    participant_id TEXT NOT NULL REFERENCES participants (id),
+    text           TEXT NOT NULL,
+    anonymous      BOOLEAN NOT NULL,
+    status         TEXT NOT NULL,
+    pinned         BOOLEAN NOT NULL DEFAULT FALSE,
+    asked_at       DATETIME NOT NULL
+);
+
+CREATE INDEX student_questions_room_id ON student_questions (room_id);
+
+CREATE TABLE student_question_upvotes (
+    question_id    TEXT NOT NULL REFERENCES student_questions (id),
+    participant_id TEXT NOT NULL REFERENCES participants (id),
+    PRIMARY KEY (question_id, participant_id)
+);
@@ -0,0 +1,24 @@
+-- ungraded polls and word clouds, which never count towards scores
+CREATE TABLE polls (
+    id         TEXT PRIMARY KEY,
+    room_id    TEXT NOT NULL REFERENCES rooms (id),
+    kind       TEXT NOT NULL,
+    prompt     TEXT NOT NULL,
+    options    TEXT NOT NULL, -- JSON array of {id, text}
+    started_at DATETIME NOT NULL,
+    closed_at  DATETIME
+);
+
+CREATE INDEX polls_room_id ON polls (room_id);
+
+CREATE TABLE poll_responses (
+    poll_id        TEXT NOT NULL REFERENCES polls (id),
+    participant_id TEXT NOT NULL REFERENCES participants (id),
+    room_id        TEXT NOT NULL REFERENCES rooms (id),
+    choices        TEXT NOT NULL, -- JSON array of option IDs
+    text           TEXT NOT NULL,
+    submitted_at   DATETIME NOT NULL,
+    PRIMARY KEY (poll_id, participant_id)
+);
+
+CREATE INDEX poll_responses_room_id ON poll_responses (room_id);
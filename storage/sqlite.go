@@ -0,0 +1,428 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"encoding/json"
+	"io/fs"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	_ "modernc.org/sqlite"
+
+	"mnemo/services/auth"
+	"mnemo/services/quiz"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// SQLite is the embedded Repository implementation. It uses the pure-Go
+// modernc.org/sqlite driver so the binary stays cgo-free.
+type SQLite struct {
+	db *sql.DB
+}
+
+// NewSQLite opens (creating if needed) the database at path and applies any
+// pending migrations.
+func NewSQLite(ctx context.Context, path string) (*SQLite, error) {
+	dsn := "file:" + path + "?_pragma=foreign_keys(1)&_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)"
+
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to open database")
+	}
+
+	// SQLite allows a single writer; serializing through one connection
+	// avoids SQLITE_BUSY under concurrent websocket handlers.
+	db.SetMaxOpenConns(1)
+
+	s := &SQLite{db: db}
+
+	if err := s.migrate(ctx); err != nil {
+		db.Close()
+		return nil, errors.Wrap(err, "unable to migrate database")
+	}
+
+	return s, nil
+}
+
+// migrate applies every embedded migration that has not been applied yet, in
+// file name order, each in its own transaction.
+func (s *SQLite) migrate(ctx context.Context) error {
+	if _, err := s.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    TEXT PRIMARY KEY,
+		applied_at DATETIME NOT NULL
+	)`); err != nil {
+		return err
+	}
+
+	names, err := fs.Glob(migrations, "migrations/*.sql")
+	if err != nil {
+		return err
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		version := strings.TrimSuffix(strings.TrimPrefix(name, "migrations/"), ".sql")
+
+		var applied int
+		if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM schema_migrations WHERE version = ?`, version).Scan(&applied); err != nil {
+			return err
+		}
+
+		if applied > 0 {
+			continue
+		}
+
+		script, err := migrations.ReadFile(name)
+		if err != nil {
+			return err
+		}
+
+		tx, err := s.db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, string(script)); err != nil {
+			tx.Rollback()
+			return errors.Wrapf(err, "migration %s failed", version)
+		}
+
+		if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)`, version, time.Now().UTC()); err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *SQLite) Ping(ctx context.Context) error {
+	return s.db.PingContext(ctx)
+}
+
+func (s *SQLite) Close() error {
+	return s.db.Close()
+}
+
+func (s *SQLite) SaveInstructor(ctx context.Context, instructor *auth.Instructor) error {
+	_, err := s.db.ExecContext(ctx, `INSERT INTO instructors (id, email, name, password_hash) VALUES (?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET email = excluded.email, name = excluded.name, password_hash = excluded.password_hash`,
+		instructor.ID, instructor.Email, instructor.Name, string(instructor.PasswordHash))
+
+	return errors.Wrap(err, "unable to save instructor")
+}
+
+func (s *SQLite) GetInstructor(id string) (*auth.Instructor, error) {
+	return s.getInstructor(`SELECT id, email, name, password_hash FROM instructors WHERE id = ?`, id)
+}
+
+func (s *SQLite) GetInstructorByEmail(email string) (*auth.Instructor, error) {
+	return s.getInstructor(`SELECT id, email, name, password_hash FROM instructors WHERE email = ?`, email)
+}
+
+func (s *SQLite) getInstructor(query string, arg string) (*auth.Instructor, error) {
+	var (
+		instructor auth.Instructor
+		hash       string
+	)
+
+	err := s.db.QueryRow(query, arg).Scan(&instructor.ID, &instructor.Email, &instructor.Name, &hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, auth.ErrNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to get instructor")
+	}
+
+	instructor.PasswordHash = []byte(hash)
+
+	return &instructor, nil
+}
+
+func (s *SQLite) CreateRoom(ctx context.Context, room *Room) error {
+	res, err := s.db.ExecContext(ctx, `INSERT INTO rooms (id, professor, created_at, closed_at, bank_id, bank_position) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO NOTHING`, room.ID, room.Professor, room.CreatedAt.UTC(), nullTime(room.ClosedAt), nullString(room.BankID), room.BankPosition)
+	if err != nil {
+		return errors.Wrap(err, "unable to create room")
+	}
+
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrConflict
+	}
+
+	return nil
+}
+
+func (s *SQLite) GetRoom(ctx context.Context, id string) (*Room, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT id, professor, created_at, closed_at, bank_id, bank_position FROM rooms WHERE id = ?`, id)
+
+	room, err := scanRoom(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to get room")
+	}
+
+	return room, nil
+}
+
+func (s *SQLite) CloseRoom(ctx context.Context, id string, at time.Time) error {
+	res, err := s.db.ExecContext(ctx, `UPDATE rooms SET closed_at = ? WHERE id = ? AND closed_at IS NULL`, at.UTC(), id)
+	if err != nil {
+		return errors.Wrap(err, "unable to close room")
+	}
+
+	return rowsAffected(res)
+}
+
+func (s *SQLite) SetRoomBankPosition(ctx context.Context, roomID string, position int) error {
+	_, err := s.db.ExecContext(ctx, `UPDATE rooms SET bank_position = ? WHERE id = ?`, position, roomID)
+	return errors.Wrap(err, "unable to set room bank position")
+}
+
+func (s *SQLite) ListOpenRooms(ctx context.Context) ([]*Room, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, professor, created_at, closed_at, bank_id, bank_position FROM rooms WHERE closed_at IS NULL ORDER BY created_at`)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to list rooms")
+	}
+	defer rows.Close()
+
+	rooms := make([]*Room, 0)
+	for rows.Next() {
+		room, err := scanRoom(rows)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to scan room")
+		}
+		rooms = append(rooms, room)
+	}
+
+	return rooms, rows.Err()
+}
+
+func (s *SQLite) SaveParticipant(ctx context.Context, p *Participant) error {
+	return saveParticipant(ctx, s.db, p)
+}
+
+func saveParticipant(ctx context.Context, db dbtx, p *Participant) error {
+	_, err := db.ExecContext(ctx, `INSERT INTO participants (id, room_id, name, token_hash, score, streak, joined_at, student_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET name = excluded.name, score = excluded.score, streak = excluded.streak`,
+		p.ID, p.RoomID, p.Name, p.TokenHash, p.Score, p.Streak, p.JoinedAt.UTC(), nullString(p.StudentID))
+
+	return errors.Wrap(err, "unable to save participant")
+}
+
+func (s *SQLite) ListParticipants(ctx context.Context, roomID string) ([]*Participant, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, room_id, name, token_hash, score, streak, joined_at, student_id FROM participants WHERE room_id = ? ORDER BY joined_at`, roomID)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to list participants")
+	}
+	defer rows.Close()
+
+	participants := make([]*Participant, 0)
+	for rows.Next() {
+		var (
+			p         Participant
+			studentID sql.NullString
+		)
+
+		if err := rows.Scan(&p.ID, &p.RoomID, &p.Name, &p.TokenHash, &p.Score, &p.Streak, &p.JoinedAt, &studentID); err != nil {
+			return nil, errors.Wrap(err, "unable to scan participant")
+		}
+
+		p.StudentID = studentID.String
+		participants = append(participants, &p)
+	}
+
+	return participants, rows.Err()
+}
+
+func (s *SQLite) SaveQuestion(ctx context.Context, q *Question) error {
+	options, err := json.Marshal(q.Options)
+	if err != nil {
+		return errors.Wrap(err, "unable to marshal options")
+	}
+
+	correct, err := json.Marshal(q.Correct)
+	if err != nil {
+		return errors.Wrap(err, "unable to marshal correct options")
+	}
+
+	_, err = s.db.ExecContext(ctx, `INSERT INTO questions (id, room_id, kind, prompt, options, correct, explanation, bank_question_id, published_at, deadline, closed_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET deadline = excluded.deadline, closed_at = excluded.closed_at`,
+		q.ID, q.RoomID, string(q.Kind), q.Prompt, string(options), string(correct), q.Explanation, nullString(q.BankQuestionID), q.PublishedAt.UTC(), nullTime(q.Deadline), nullTime(q.ClosedAt))
+
+	return errors.Wrap(err, "unable to save question")
+}
+
+func (s *SQLite) ListQuestions(ctx context.Context, roomID string) ([]*Question, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, room_id, kind, prompt, options, correct, explanation, bank_question_id, published_at, deadline, closed_at FROM questions WHERE room_id = ? ORDER BY published_at`, roomID)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to list questions")
+	}
+	defer rows.Close()
+
+	questions := make([]*Question, 0)
+	for rows.Next() {
+		var (
+			q                 Question
+			kind              string
+			options, correct  string
+			deadline, closeAt sql.NullTime
+			bankQuestionID    sql.NullString
+		)
+
+		if err := rows.Scan(&q.ID, &q.RoomID, &kind, &q.Prompt, &options, &correct, &q.Explanation, &bankQuestionID, &q.PublishedAt, &deadline, &closeAt); err != nil {
+			return nil, errors.Wrap(err, "unable to scan question")
+		}
+
+		q.Kind = quiz.Kind(kind)
+		q.BankQuestionID = bankQuestionID.String
+		q.Deadline = timePtr(deadline)
+		q.ClosedAt = timePtr(closeAt)
+
+		if err := json.Unmarshal([]byte(options), &q.Options); err != nil {
+			return nil, errors.Wrap(err, "unable to unmarshal options")
+		}
+
+		if err := json.Unmarshal([]byte(correct), &q.Correct); err != nil {
+			return nil, errors.Wrap(err, "unable to unmarshal correct options")
+		}
+
+		questions = append(questions, &q)
+	}
+
+	return questions, rows.Err()
+}
+
+func (s *SQLite) SaveAnswer(ctx context.Context, a *Answer) error {
+	return saveAnswer(ctx, s.db, a)
+}
+
+func saveAnswer(ctx context.Context, db dbtx, a *Answer) error {
+	choices, err := json.Marshal(a.Choices)
+	if err != nil {
+		return errors.Wrap(err, "unable to marshal choices")
+	}
+
+	_, err = db.ExecContext(ctx, `INSERT INTO answers (participant_id, question_id, room_id, choices, correct, points, submitted_at, response_time_ms) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (participant_id, question_id) DO UPDATE SET points = excluded.points`,
+		a.ParticipantID, a.QuestionID, a.RoomID, string(choices), a.Correct, a.Points, a.SubmittedAt.UTC(), a.ResponseTime.Milliseconds())
+
+	return errors.Wrap(err, "unable to save answer")
+}
+
+func (s *SQLite) ListAnswers(ctx context.Context, roomID string) ([]*Answer, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT participant_id, question_id, room_id, choices, correct, points, submitted_at, response_time_ms FROM answers WHERE room_id = ? ORDER BY submitted_at`, roomID)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to list answers")
+	}
+	defer rows.Close()
+
+	answers := make([]*Answer, 0)
+	for rows.Next() {
+		var (
+			a              Answer
+			choices        string
+			responseTimeMs int64
+		)
+
+		if err := rows.Scan(&a.ParticipantID, &a.QuestionID, &a.RoomID, &choices, &a.Correct, &a.Points, &a.SubmittedAt, &responseTimeMs); err != nil {
+			return nil, errors.Wrap(err, "unable to scan answer")
+		}
+
+		if err := json.Unmarshal([]byte(choices), &a.Choices); err != nil {
+			return nil, errors.Wrap(err, "unable to unmarshal choices")
+		}
+
+		a.ResponseTime = time.Duration(responseTimeMs) * time.Millisecond
+		answers = append(answers, &a)
+	}
+
+	return answers, rows.Err()
+}
+
+func (s *SQLite) SaveRoundResults(ctx context.Context, results *RoundResults) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "unable to begin transaction")
+	}
+	defer tx.Rollback()
+
+	for _, a := range results.Answers {
+		if err := saveAnswer(ctx, tx, a); err != nil {
+			return err
+		}
+	}
+
+	for _, p := range results.Participants {
+		if err := saveParticipant(ctx, tx, p); err != nil {
+			return err
+		}
+	}
+
+	for _, update := range results.Reviews {
+		if err := recordReview(ctx, tx, update.Review, update.Entry); err != nil {
+			return err
+		}
+	}
+
+	return errors.Wrap(tx.Commit(), "unable to commit round results")
+}
+
+// dbtx is a database or a transaction.
+type dbtx interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanRoom(row scanner) (*Room, error) {
+	var (
+		room     Room
+		closedAt sql.NullTime
+		bankID   sql.NullString
+	)
+
+	if err := row.Scan(&room.ID, &room.Professor, &room.CreatedAt, &closedAt, &bankID, &room.BankPosition); err != nil {
+		return nil, err
+	}
+
+	room.ClosedAt = timePtr(closedAt)
+	room.BankID = bankID.String
+
+	return &room, nil
+}
+
+func nullTime(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+
+	return sql.NullTime{Time: t.UTC(), Valid: true}
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+func timePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+
+	return &t.Time
+}
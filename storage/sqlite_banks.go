@@ -0,0 +1,238 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+
+	"mnemo/services/quiz"
+)
+
+func (s *SQLite) CreateBank(ctx context.Context, bank *Bank) error {
+	_, err := s.db.ExecContext(ctx, `INSERT INTO banks (id, owner, name, description, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		bank.ID, bank.Owner, bank.Name, bank.Description, bank.CreatedAt.UTC(), bank.UpdatedAt.UTC())
+
+	return errors.Wrap(err, "unable to create bank")
+}
+
+func (s *SQLite) GetBank(ctx context.Context, id string) (*Bank, error) {
+	var bank Bank
+
+	err := s.db.QueryRowContext(ctx, `SELECT id, owner, name, description, created_at, updated_at FROM banks WHERE id = ?`, id).
+		Scan(&bank.ID, &bank.Owner, &bank.Name, &bank.Description, &bank.CreatedAt, &bank.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to get bank")
+	}
+
+	return &bank, nil
+}
+
+func (s *SQLite) ListBanks(ctx context.Context, owner string) ([]*Bank, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, owner, name, description, created_at, updated_at FROM banks WHERE owner = ? ORDER BY name`, owner)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to list banks")
+	}
+	defer rows.Close()
+
+	banks := make([]*Bank, 0)
+	for rows.Next() {
+		var bank Bank
+		if err := rows.Scan(&bank.ID, &bank.Owner, &bank.Name, &bank.Description, &bank.CreatedAt, &bank.UpdatedAt); err != nil {
+			return nil, errors.Wrap(err, "unable to scan bank")
+		}
+		banks = append(banks, &bank)
+	}
+
+	return banks, rows.Err()
+}
+
+func (s *SQLite) UpdateBank(ctx context.Context, bank *Bank) error {
+	res, err := s.db.ExecContext(ctx, `UPDATE banks SET name = ?, description = ?, updated_at = ? WHERE id = ?`,
+		bank.Name, bank.Description, bank.UpdatedAt.UTC(), bank.ID)
+	if err != nil {
+		return errors.Wrap(err, "unable to update bank")
+	}
+
+	return rowsAffected(res)
+}
+
+func (s *SQLite) DeleteBank(ctx context.Context, id string) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM banks WHERE id = ?`, id)
+	if err != nil {
+		return errors.Wrap(err, "unable to delete bank")
+	}
+
+	return rowsAffected(res)
+}
+
+func (s *SQLite) CreateBankQuestion(ctx context.Context, q *BankQuestion) error {
+	return createBankQuestion(ctx, s.db, q)
+}
+
+func (s *SQLite) CreateBankQuestions(ctx context.Context, questions []*BankQuestion) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "unable to begin transaction")
+	}
+	defer tx.Rollback()
+
+	for _, q := range questions {
+		if err := createBankQuestion(ctx, tx, q); err != nil {
+			return err
+		}
+	}
+
+	return errors.Wrap(tx.Commit(), "unable to commit bank questions")
+}
+
+func createBankQuestion(ctx context.Context, db dbtx, q *BankQuestion) error {
+	cols, err := marshalBankQuestion(q)
+	if err != nil {
+		return err
+	}
+
+	err = db.QueryRowContext(ctx, `INSERT INTO bank_questions (id, bank_id, position, kind, prompt, options, correct, explanation, tags, difficulty, duration_secs)
+		VALUES (?, ?, (SELECT COALESCE(MAX(position), 0) + 1 FROM bank_questions WHERE bank_id = ?), ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING position`,
+		q.ID, q.BankID, q.BankID, string(q.Kind), q.Prompt, cols.options, cols.correct, q.Explanation, cols.tags, q.Difficulty, q.DurationSecs).Scan(&q.Position)
+
+	return errors.Wrap(err, "unable to create bank question")
+}
+
+func (s *SQLite) GetBankQuestion(ctx context.Context, bankID, id string) (*BankQuestion, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT id, bank_id, position, kind, prompt, options, correct, explanation, tags, difficulty, duration_secs
+		FROM bank_questions WHERE bank_id = ? AND id = ?`, bankID, id)
+
+	q, err := scanBankQuestion(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to get bank question")
+	}
+
+	return q, nil
+}
+
+func (s *SQLite) UpdateBankQuestion(ctx context.Context, q *BankQuestion) error {
+	cols, err := marshalBankQuestion(q)
+	if err != nil {
+		return err
+	}
+
+	res, err := s.db.ExecContext(ctx, `UPDATE bank_questions SET kind = ?, prompt = ?, options = ?, correct = ?, explanation = ?, tags = ?, difficulty = ?, duration_secs = ?
+		WHERE bank_id = ? AND id = ?`,
+		string(q.Kind), q.Prompt, cols.options, cols.correct, q.Explanation, cols.tags, q.Difficulty, q.DurationSecs, q.BankID, q.ID)
+	if err != nil {
+		return errors.Wrap(err, "unable to update bank question")
+	}
+
+	return rowsAffected(res)
+}
+
+func (s *SQLite) DeleteBankQuestion(ctx context.Context, bankID, id string) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM bank_questions WHERE bank_id = ? AND id = ?`, bankID, id)
+	if err != nil {
+		return errors.Wrap(err, "unable to delete bank question")
+	}
+
+	return rowsAffected(res)
+}
+
+func (s *SQLite) ListBankQuestions(ctx context.Context, bankID string) ([]*BankQuestion, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, bank_id, position, kind, prompt, options, correct, explanation, tags, difficulty, duration_secs
+		FROM bank_questions WHERE bank_id = ? ORDER BY position`, bankID)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to list bank questions")
+	}
+	defer rows.Close()
+
+	questions := make([]*BankQuestion, 0)
+	for rows.Next() {
+		q, err := scanBankQuestion(rows)
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to scan bank question")
+		}
+		questions = append(questions, q)
+	}
+
+	return questions, rows.Err()
+}
+
+type bankQuestionColumns struct {
+	options, correct, tags string
+}
+
+func marshalBankQuestion(q *BankQuestion) (*bankQuestionColumns, error) {
+	options, err := json.Marshal(q.Options)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to marshal options")
+	}
+
+	correct, err := json.Marshal(q.Correct)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to marshal correct options")
+	}
+
+	tags := q.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+
+	tagData, err := json.Marshal(tags)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to marshal tags")
+	}
+
+	return &bankQuestionColumns{
+		options: string(options),
+		correct: string(correct),
+		tags:    string(tagData),
+	}, nil
+}
+
+func scanBankQuestion(row scanner) (*BankQuestion, error) {
+	var (
+		q                      BankQuestion
+		kind                   string
+		options, correct, tags string
+	)
+
+	if err := row.Scan(&q.ID, &q.BankID, &q.Position, &kind, &q.Prompt, &options, &correct, &q.Explanation, &tags, &q.Difficulty, &q.DurationSecs); err != nil {
+		return nil, err
+	}
+
+	q.Kind = quiz.Kind(kind)
+
+	if err := json.Unmarshal([]byte(options), &q.Options); err != nil {
+		return nil, errors.Wrap(err, "unable to unmarshal options")
+	}
+
+	if err := json.Unmarshal([]byte(correct), &q.Correct); err != nil {
+		return nil, errors.Wrap(err, "unable to unmarshal correct options")
+	}
+
+	if err := json.Unmarshal([]byte(tags), &q.Tags); err != nil {
+		return nil, errors.Wrap(err, "unable to unmarshal tags")
+	}
+
+	return &q, nil
+}
+
+func rowsAffected(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
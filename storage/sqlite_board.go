@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+func (s *SQLite) SaveStudentQuestion(ctx context.Context, q *StudentQuestion) error {
+	_, err := s.db.ExecContext(ctx, `INSERT INTO student_questions (id, room_id, participant_id, text, anonymous, status, pinned, asked_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET status = excluded.status, pinned = excluded.pinned`,
+		q.ID, q.RoomID, q.ParticipantID, q.Text, q.Anonymous, q.Status, q.Pinned, q.AskedAt.UTC())
+
+	return errors.Wrap(err, "unable to save student question")
+}
+
+func (s *SQLite) SetUpvote(ctx context.Context, questionID, participantID string, up bool) error {
+	query := `INSERT INTO student_question_upvotes (question_id, participant_id) VALUES (?, ?) ON CONFLICT DO NOTHING`
+	if !up {
+		query = `DELETE FROM student_question_upvotes WHERE question_id = ? AND participant_id = ?`
+	}
+
+	_, err := s.db.ExecContext(ctx, query, questionID, participantID)
+
+	return errors.Wrap(err, "unable to save upvote")
+}
+
+func (s *SQLite) ListStudentQuestions(ctx context.Context, roomID string) ([]*StudentQuestion, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, room_id, participant_id, text, anonymous, status, pinned, asked_at FROM student_questions WHERE room_id = ? ORDER BY asked_at`, roomID)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to list student questions")
+	}
+	defer rows.Close()
+
+	questions := make([]*StudentQuestion, 0)
+	byID := make(map[string]*StudentQuestion)
+
+	for rows.Next() {
+		var q StudentQuestion
+
+		if err := rows.Scan(&q.ID, &q.RoomID, &q.ParticipantID, &q.Text, &q.Anonymous, &q.Status, &q.Pinned, &q.AskedAt); err != nil {
+			return nil, errors.Wrap(err, "unable to scan student question")
+		}
+
+		q.Upvoters = make([]string, 0)
+		questions = append(questions, &q)
+		byID[q.ID] = &q
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "unable to list student questions")
+	}
+
+	upvotes, err := s.db.QueryContext(ctx, `SELECT v.question_id, v.participant_id FROM student_question_upvotes v
+		JOIN student_questions q ON q.id = v.question_id WHERE q.room_id = ?`, roomID)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to list upvotes")
+	}
+	defer upvotes.Close()
+
+	for upvotes.Next() {
+		var questionID, participantID string
+
+		if err := upvotes.Scan(&questionID, &participantID); err != nil {
+			return nil, errors.Wrap(err, "unable to scan upvote")
+		}
+
+		if q, ok := byID[questionID]; ok {
+			q.Upvoters = append(q.Upvoters, participantID)
+		}
+	}
+
+	return questions, upvotes.Err()
+}
@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+
+	"mnemo/services/poll"
+)
+
+func (s *SQLite) SavePoll(ctx context.Context, p *Poll) error {
+	options, err := json.Marshal(p.Options)
+	if err != nil {
+		return errors.Wrap(err, "unable to marshal options")
+	}
+
+	_, err = s.db.ExecContext(ctx, `INSERT INTO polls (id, room_id, kind, prompt, options, started_at, closed_at) VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET closed_at = excluded.closed_at`,
+		p.ID, p.RoomID, string(p.Kind), p.Prompt, string(options), p.StartedAt.UTC(), nullTime(p.ClosedAt))
+
+	return errors.Wrap(err, "unable to save poll")
+}
+
+func (s *SQLite) ListPolls(ctx context.Context, roomID string) ([]*Poll, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, room_id, kind, prompt, options, started_at, closed_at FROM polls WHERE room_id = ? ORDER BY started_at`, roomID)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to list polls")
+	}
+	defer rows.Close()
+
+	polls := make([]*Poll, 0)
+	for rows.Next() {
+		var (
+			p             Poll
+			kind, options string
+			closedAt      sql.NullTime
+		)
+
+		if err := rows.Scan(&p.ID, &p.RoomID, &kind, &p.Prompt, &options, &p.StartedAt, &closedAt); err != nil {
+			return nil, errors.Wrap(err, "unable to scan poll")
+		}
+
+		p.Kind = poll.Kind(kind)
+		p.ClosedAt = timePtr(closedAt)
+
+		if err := json.Unmarshal([]byte(options), &p.Options); err != nil {
+			return nil, errors.Wrap(err, "unable to unmarshal options")
+		}
+
+		polls = append(polls, &p)
+	}
+
+	return polls, rows.Err()
+}
+
+func (s *SQLite) SavePollResponse(ctx context.Context, r *PollResponse) error {
+	choices, err := json.Marshal(r.Choices)
+	if err != nil {
+		return errors.Wrap(err, "unable to marshal choices")
+	}
+
+	_, err = s.db.ExecContext(ctx, `INSERT INTO poll_responses (poll_id, participant_id, room_id, choices, text, submitted_at) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (poll_id, participant_id) DO UPDATE SET choices = excluded.choices, text = excluded.text, submitted_at = excluded.submitted_at`,
+		r.PollID, r.ParticipantID, r.RoomID, string(choices), r.Text, r.SubmittedAt.UTC())
+
+	return errors.Wrap(err, "unable to save poll response")
+}
+
+func (s *SQLite) ListPollResponses(ctx context.Context, roomID string) ([]*PollResponse, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT poll_id, participant_id, room_id, choices, text, submitted_at FROM poll_responses WHERE room_id = ? ORDER BY submitted_at`, roomID)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to list poll responses")
+	}
+	defer rows.Close()
+
+	responses := make([]*PollResponse, 0)
+	for rows.Next() {
+		var (
+			r       PollResponse
+			choices string
+		)
+
+		if err := rows.Scan(&r.PollID, &r.ParticipantID, &r.RoomID, &choices, &r.Text, &r.SubmittedAt); err != nil {
+			return nil, errors.Wrap(err, "unable to scan poll response")
+		}
+
+		if err := json.Unmarshal([]byte(choices), &r.Choices); err != nil {
+			return nil, errors.Wrap(err, "unable to unmarshal choices")
+		}
+
+		responses = append(responses, &r)
+	}
+
+	return responses, rows.Err()
+}
@@ -0,0 +1,145 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+func (s *SQLite) CreateStudent(ctx context.Context, student *Student) error {
+	_, err := s.db.ExecContext(ctx, `INSERT INTO students (id, name, token_hash, created_at) VALUES (?, ?, ?, ?)`,
+		student.ID, student.Name, student.TokenHash, student.CreatedAt.UTC())
+
+	return errors.Wrap(err, "unable to create student")
+}
+
+func (s *SQLite) GetStudent(ctx context.Context, id string) (*Student, error) {
+	return s.getStudent(ctx, `SELECT id, name, token_hash, created_at FROM students WHERE id = ?`, id)
+}
+
+func (s *SQLite) GetStudentByToken(ctx context.Context, tokenHash string) (*Student, error) {
+	return s.getStudent(ctx, `SELECT id, name, token_hash, created_at FROM students WHERE token_hash = ?`, tokenHash)
+}
+
+func (s *SQLite) getStudent(ctx context.Context, query, arg string) (*Student, error) {
+	var student Student
+
+	err := s.db.QueryRowContext(ctx, query, arg).Scan(&student.ID, &student.Name, &student.TokenHash, &student.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to get student")
+	}
+
+	return &student, nil
+}
+
+func (s *SQLite) GetReview(ctx context.Context, studentID, bankQuestionID string) (*Review, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT student_id, bank_question_id, repetitions, interval_days, ease, due_at, last_reviewed_at
+		FROM reviews WHERE student_id = ? AND bank_question_id = ?`, studentID, bankQuestionID)
+
+	review, err := scanReview(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to get review")
+	}
+
+	return review, nil
+}
+
+func (s *SQLite) RecordReview(ctx context.Context, review *Review, entry *ReviewLog) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "unable to begin transaction")
+	}
+	defer tx.Rollback()
+
+	if err := recordReview(ctx, tx, review, entry); err != nil {
+		return err
+	}
+
+	return errors.Wrap(tx.Commit(), "unable to commit review")
+}
+
+func recordReview(ctx context.Context, db dbtx, review *Review, entry *ReviewLog) error {
+	if _, err := db.ExecContext(ctx, `INSERT INTO reviews (student_id, bank_question_id, repetitions, interval_days, ease, due_at, last_reviewed_at) VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (student_id, bank_question_id) DO UPDATE SET repetitions = excluded.repetitions, interval_days = excluded.interval_days,
+			ease = excluded.ease, due_at = excluded.due_at, last_reviewed_at = excluded.last_reviewed_at`,
+		review.StudentID, review.BankQuestionID, review.Repetitions, review.IntervalDays, review.Ease, review.Due.UTC(), nullTime(review.LastReviewed)); err != nil {
+		return errors.Wrap(err, "unable to save review")
+	}
+
+	if _, err := db.ExecContext(ctx, `INSERT INTO review_log (student_id, bank_question_id, room_id, correct, quality, response_time_ms, reviewed_at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		entry.StudentID, entry.BankQuestionID, nullString(entry.RoomID), entry.Correct, entry.Quality, entry.ResponseTime.Milliseconds(), entry.ReviewedAt.UTC()); err != nil {
+		return errors.Wrap(err, "unable to save review log")
+	}
+
+	return nil
+}
+
+func (s *SQLite) ListDueReviews(ctx context.Context, studentID string, now time.Time, limit int) ([]*DueReview, error) {
+	if limit <= 0 {
+		limit = -1 // no limit in SQLite
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT r.student_id, r.bank_question_id, r.repetitions, r.interval_days, r.ease, r.due_at, r.last_reviewed_at,
+			q.id, q.bank_id, q.position, q.kind, q.prompt, q.options, q.correct, q.explanation, q.tags, q.difficulty, q.duration_secs
+		FROM reviews r JOIN bank_questions q ON q.id = r.bank_question_id
+		WHERE r.student_id = ? AND r.due_at <= ?
+		ORDER BY r.due_at LIMIT ?`, studentID, now.UTC(), limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to list due reviews")
+	}
+	defer rows.Close()
+
+	due := make([]*DueReview, 0)
+	for rows.Next() {
+		var (
+			review   Review
+			reviewed sql.NullTime
+		)
+
+		question, err := scanBankQuestion(prefixScanner{rows, []any{
+			&review.StudentID, &review.BankQuestionID, &review.Repetitions, &review.IntervalDays, &review.Ease, &review.Due, &reviewed,
+		}})
+		if err != nil {
+			return nil, errors.Wrap(err, "unable to scan due review")
+		}
+
+		review.LastReviewed = timePtr(reviewed)
+		due = append(due, &DueReview{Review: &review, Question: question})
+	}
+
+	return due, rows.Err()
+}
+
+// prefixScanner scans the leading columns of a joined row into prefix and
+// hands the rest to the caller's destinations.
+type prefixScanner struct {
+	row    scanner
+	prefix []any
+}
+
+func (p prefixScanner) Scan(dest ...any) error {
+	return p.row.Scan(append(p.prefix, dest...)...)
+}
+
+func scanReview(row scanner) (*Review, error) {
+	var (
+		review   Review
+		reviewed sql.NullTime
+	)
+
+	if err := row.Scan(&review.StudentID, &review.BankQuestionID, &review.Repetitions, &review.IntervalDays, &review.Ease, &review.Due, &reviewed); err != nil {
+		return nil, err
+	}
+
+	review.LastReviewed = timePtr(reviewed)
+
+	return &review, nil
+}
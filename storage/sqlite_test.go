@@ -0,0 +1,439 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"mnemo/services/quiz"
+	"mnemo/services/srs"
+)
+
+var testTime = time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+
+func newTestSQLite(t *testing.T) *SQLite {
+	t.Helper()
+
+	s, err := NewSQLite(context.Background(), filepath.Join(t.TempDir(), "mnemo.db"))
+	if err != nil {
+		t.Fatalf("NewSQLite: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+
+	return s
+}
+
+// appliedMigrations lists the versions recorded in schema_migrations.
+func appliedMigrations(t *testing.T, db *sql.DB) []string {
+	t.Helper()
+
+	rows, err := db.Query(`SELECT version FROM schema_migrations ORDER BY version`)
+	if err != nil {
+		t.Fatalf("listing migrations: %v", err)
+	}
+	defer rows.Close()
+
+	var versions []string
+	for rows.Next() {
+		var version string
+		if err := rows.Scan(&version); err != nil {
+			t.Fatalf("scanning migration: %v", err)
+		}
+		versions = append(versions, version)
+	}
+
+	return versions
+}
+
+var allMigrations = []string{"0001_init", "0002_question_banks", "0003_reviews", "0004_board", "0005_polls"}
+
+func TestMigrate(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "mnemo.db")
+
+	s, err := NewSQLite(ctx, path)
+	if err != nil {
+		t.Fatalf("NewSQLite: %v", err)
+	}
+
+	if got := appliedMigrations(t, s.db); !reflect.DeepEqual(got, allMigrations) {
+		t.Fatalf("applied = %v, want %v", got, allMigrations)
+	}
+
+	if err := s.CreateRoom(ctx, &Room{ID: "ABC123", Professor: "prof@example.com", CreatedAt: testTime}); err != nil {
+		t.Fatalf("CreateRoom: %v", err)
+	}
+	s.Close()
+
+	// reopening applies nothing twice and keeps the data
+	s, err = NewSQLite(ctx, path)
+	if err != nil {
+		t.Fatalf("reopening: %v", err)
+	}
+	defer s.Close()
+
+	if got := appliedMigrations(t, s.db); !reflect.DeepEqual(got, allMigrations) {
+		t.Fatalf("applied after reopening = %v, want %v", got, allMigrations)
+	}
+
+	if _, err := s.GetRoom(ctx, "ABC123"); err != nil {
+		t.Fatalf("GetRoom after reopening: %v", err)
+	}
+}
+
+func TestMigrateUpgrade(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "mnemo.db")
+
+	// a database created before question banks existed
+	db, err := sql.Open("sqlite", "file:"+path)
+	if err != nil {
+		t.Fatalf("sql.Open: %v", err)
+	}
+
+	script, err := migrations.ReadFile("migrations/0001_init.sql")
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+
+	for _, stmt := range []string{
+		`CREATE TABLE schema_migrations (version TEXT PRIMARY KEY, applied_at DATETIME NOT NULL)`,
+		string(script),
+		`INSERT INTO schema_migrations (version, applied_at) VALUES ('0001_init', CURRENT_TIMESTAMP)`,
+		`INSERT INTO rooms (id, professor, created_at) VALUES ('OLD001', 'prof@example.com', '2026-01-01 09:00:00')`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("preparing old database: %v", err)
+		}
+	}
+	db.Close()
+
+	s, err := NewSQLite(ctx, path)
+	if err != nil {
+		t.Fatalf("NewSQLite: %v", err)
+	}
+	defer s.Close()
+
+	if got := appliedMigrations(t, s.db); !reflect.DeepEqual(got, allMigrations) {
+		t.Fatalf("applied = %v, want %v", got, allMigrations)
+	}
+
+	room, err := s.GetRoom(ctx, "OLD001")
+	if err != nil {
+		t.Fatalf("GetRoom: %v", err)
+	}
+	if room.BankID != "" || room.BankPosition != 0 {
+		t.Errorf("old room = %+v, want no bank", room)
+	}
+}
+
+func TestRooms(t *testing.T) {
+	ctx := context.Background()
+	s := newTestSQLite(t)
+
+	rooms := []*Room{
+		{ID: "ROOM01", Professor: "prof@example.com", CreatedAt: testTime},
+		{ID: "ROOM02", Professor: "prof@example.com", CreatedAt: testTime.Add(time.Minute)},
+	}
+	for _, room := range rooms {
+		if err := s.CreateRoom(ctx, room); err != nil {
+			t.Fatalf("CreateRoom: %v", err)
+		}
+	}
+
+	if err := s.CreateRoom(ctx, &Room{ID: "ROOM01", Professor: "other@example.com", CreatedAt: testTime}); !errors.Is(err, ErrConflict) {
+		t.Fatalf("reusing a join code = %v, want ErrConflict", err)
+	}
+
+	if err := s.SetRoomBankPosition(ctx, "ROOM02", 3); err != nil {
+		t.Fatalf("SetRoomBankPosition: %v", err)
+	}
+
+	if err := s.CloseRoom(ctx, "ROOM01", testTime.Add(time.Hour)); err != nil {
+		t.Fatalf("CloseRoom: %v", err)
+	}
+	if err := s.CloseRoom(ctx, "ROOM01", testTime.Add(2*time.Hour)); !errors.Is(err, ErrNotFound) {
+		t.Errorf("closing a closed room = %v, want ErrNotFound", err)
+	}
+
+	closed, err := s.GetRoom(ctx, "ROOM01")
+	if err != nil {
+		t.Fatalf("GetRoom: %v", err)
+	}
+	if closed.ClosedAt == nil || !closed.ClosedAt.Equal(testTime.Add(time.Hour)) {
+		t.Errorf("closed at = %v, want %v", closed.ClosedAt, testTime.Add(time.Hour))
+	}
+
+	if _, err := s.GetRoom(ctx, "NOPE00"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetRoom of an unknown room = %v, want ErrNotFound", err)
+	}
+
+	open, err := s.ListOpenRooms(ctx)
+	if err != nil {
+		t.Fatalf("ListOpenRooms: %v", err)
+	}
+	if len(open) != 1 || open[0].ID != "ROOM02" || open[0].BankPosition != 3 || !open[0].CreatedAt.Equal(rooms[1].CreatedAt) {
+		t.Errorf("open rooms = %+v", open)
+	}
+}
+
+func TestRoomActivity(t *testing.T) {
+	ctx := context.Background()
+	s := newTestSQLite(t)
+
+	if err := s.CreateRoom(ctx, &Room{ID: "ROOM01", Professor: "prof@example.com", CreatedAt: testTime}); err != nil {
+		t.Fatalf("CreateRoom: %v", err)
+	}
+
+	participant := &Participant{ID: "p1", RoomID: "ROOM01", Name: "Ada", TokenHash: "hash", JoinedAt: testTime}
+	if err := s.SaveParticipant(ctx, participant); err != nil {
+		t.Fatalf("SaveParticipant: %v", err)
+	}
+
+	deadline := testTime.Add(30 * time.Second)
+	question := &Question{
+		Question: quiz.Question{
+			ID:      "q1",
+			Kind:    quiz.KindMultipleChoice,
+			Prompt:  "Capital of France?",
+			Options: []quiz.Option{{ID: "a", Text: "Paris"}, {ID: "b", Text: "Lyon"}},
+			Correct: []string{"a"},
+		},
+		RoomID:      "ROOM01",
+		PublishedAt: testTime,
+		Deadline:    &deadline,
+	}
+	if err := s.SaveQuestion(ctx, question); err != nil {
+		t.Fatalf("SaveQuestion: %v", err)
+	}
+
+	answer := &Answer{
+		Answer: quiz.Answer{
+			ParticipantID: "p1",
+			QuestionID:    "q1",
+			Choices:       []string{"a"},
+			Correct:       true,
+			SubmittedAt:   testTime.Add(2 * time.Second),
+			ResponseTime:  2 * time.Second,
+		},
+		RoomID: "ROOM01",
+	}
+	if err := s.SaveAnswer(ctx, answer); err != nil {
+		t.Fatalf("SaveAnswer: %v", err)
+	}
+
+	// closing the question scores the answer and the participant at once
+	closedAt := testTime.Add(time.Minute)
+	question.ClosedAt = &closedAt
+	if err := s.SaveQuestion(ctx, question); err != nil {
+		t.Fatalf("SaveQuestion: %v", err)
+	}
+
+	answer.Points = 900
+	participant.Score, participant.Streak = 900, 1
+	if err := s.SaveRoundResults(ctx, &RoundResults{Answers: []*Answer{answer}, Participants: []*Participant{participant}}); err != nil {
+		t.Fatalf("SaveRoundResults: %v", err)
+	}
+
+	participants, err := s.ListParticipants(ctx, "ROOM01")
+	if err != nil {
+		t.Fatalf("ListParticipants: %v", err)
+	}
+	if len(participants) != 1 || !reflect.DeepEqual(*participants[0], *participant) {
+		t.Errorf("participants = %+v, want %+v", participants, participant)
+	}
+
+	questions, err := s.ListQuestions(ctx, "ROOM01")
+	if err != nil {
+		t.Fatalf("ListQuestions: %v", err)
+	}
+	if len(questions) != 1 {
+		t.Fatalf("got %d questions, want 1", len(questions))
+	}
+	if got := questions[0]; !reflect.DeepEqual(got.Question, question.Question) || !got.Deadline.Equal(deadline) || !got.ClosedAt.Equal(closedAt) {
+		t.Errorf("question = %+v, want %+v", got, question)
+	}
+
+	answers, err := s.ListAnswers(ctx, "ROOM01")
+	if err != nil {
+		t.Fatalf("ListAnswers: %v", err)
+	}
+	if len(answers) != 1 || !reflect.DeepEqual(*answers[0], *answer) {
+		t.Errorf("answers = %+v, want %+v", answers, answer)
+	}
+}
+
+func TestSaveRoundResultsIsAtomic(t *testing.T) {
+	ctx := context.Background()
+	s := newTestSQLite(t)
+
+	if err := s.CreateRoom(ctx, &Room{ID: "ROOM01", Professor: "prof@example.com", CreatedAt: testTime}); err != nil {
+		t.Fatalf("CreateRoom: %v", err)
+	}
+
+	participant := &Participant{ID: "p1", RoomID: "ROOM01", Name: "Ada", TokenHash: "hash", JoinedAt: testTime}
+	if err := s.SaveParticipant(ctx, participant); err != nil {
+		t.Fatalf("SaveParticipant: %v", err)
+	}
+
+	// the answer refers to a question that doesn't exist
+	scored := *participant
+	scored.Score = 900
+
+	err := s.SaveRoundResults(ctx, &RoundResults{
+		Participants: []*Participant{&scored},
+		Answers: []*Answer{{
+			Answer: quiz.Answer{ParticipantID: "p1", QuestionID: "missing", Choices: []string{}, SubmittedAt: testTime},
+			RoomID: "ROOM01",
+		}},
+	})
+	if err == nil {
+		t.Fatal("SaveRoundResults saved an answer to an unknown question")
+	}
+
+	participants, err := s.ListParticipants(ctx, "ROOM01")
+	if err != nil {
+		t.Fatalf("ListParticipants: %v", err)
+	}
+	if participants[0].Score != 0 {
+		t.Errorf("score = %d, want the round rolled back", participants[0].Score)
+	}
+}
+
+func TestReviews(t *testing.T) {
+	ctx := context.Background()
+	s := newTestSQLite(t)
+
+	student := &Student{ID: "s1", Name: "Ada", TokenHash: "hash", CreatedAt: testTime}
+	if err := s.CreateStudent(ctx, student); err != nil {
+		t.Fatalf("CreateStudent: %v", err)
+	}
+
+	if got, err := s.GetStudentByToken(ctx, "hash"); err != nil || got.ID != "s1" {
+		t.Fatalf("GetStudentByToken = %+v, %v", got, err)
+	}
+	if _, err := s.GetStudentByToken(ctx, "other"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetStudentByToken of an unknown token = %v, want ErrNotFound", err)
+	}
+
+	if err := s.CreateBank(ctx, &Bank{ID: "b1", Owner: "prof@example.com", Name: "Geography", CreatedAt: testTime, UpdatedAt: testTime}); err != nil {
+		t.Fatalf("CreateBank: %v", err)
+	}
+
+	questions := []*BankQuestion{
+		{Question: quiz.Question{ID: "bq1", Kind: quiz.KindTrueFalse, Prompt: "Paris is in France"}, BankID: "b1", Tags: []string{"europe"}},
+		{Question: quiz.Question{ID: "bq2", Kind: quiz.KindTrueFalse, Prompt: "Lyon is the capital"}, BankID: "b1", Tags: []string{}},
+		{Question: quiz.Question{ID: "bq3", Kind: quiz.KindTrueFalse, Prompt: "Nice is in Italy"}, BankID: "b1", Tags: []string{}},
+	}
+	for _, q := range questions {
+		q.Options = []quiz.Option{{ID: quiz.OptionTrue, Text: "True"}, {ID: quiz.OptionFalse, Text: "False"}}
+		q.Correct = []string{quiz.OptionTrue}
+	}
+	if err := s.CreateBankQuestions(ctx, questions); err != nil {
+		t.Fatalf("CreateBankQuestions: %v", err)
+	}
+
+	if _, err := s.GetReview(ctx, "s1", "bq1"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("GetReview before any attempt = %v, want ErrNotFound", err)
+	}
+
+	now := testTime.Add(48 * time.Hour)
+	due := map[string]time.Time{
+		"bq1": now.Add(-time.Hour),
+		"bq2": now.Add(-24 * time.Hour),
+		"bq3": now.Add(time.Hour), // not due yet
+	}
+
+	for id, dueAt := range due {
+		reviewed := testTime
+		review := &Review{
+			Card:           srs.Card{Repetitions: 1, IntervalDays: 1, Ease: 2.5, Due: dueAt, LastReviewed: &reviewed},
+			StudentID:      "s1",
+			BankQuestionID: id,
+		}
+		entry := &ReviewLog{StudentID: "s1", BankQuestionID: id, Correct: true, Quality: 5, ResponseTime: time.Second, ReviewedAt: reviewed}
+
+		if err := s.RecordReview(ctx, review, entry); err != nil {
+			t.Fatalf("RecordReview: %v", err)
+		}
+	}
+
+	review, err := s.GetReview(ctx, "s1", "bq1")
+	if err != nil {
+		t.Fatalf("GetReview: %v", err)
+	}
+	if !review.Due.Equal(due["bq1"]) || review.Ease != 2.5 || review.LastReviewed == nil {
+		t.Errorf("review = %+v", review)
+	}
+
+	tests := []struct {
+		limit int
+		want  []string
+	}{
+		{0, []string{"bq2", "bq1"}},
+		{1, []string{"bq2"}},
+	}
+
+	for _, tt := range tests {
+		reviews, err := s.ListDueReviews(ctx, "s1", now, tt.limit)
+		if err != nil {
+			t.Fatalf("ListDueReviews: %v", err)
+		}
+
+		var got []string
+		for _, r := range reviews {
+			got = append(got, r.Question.ID)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("due with limit %d = %v, want %v", tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestStudentQuestions(t *testing.T) {
+	ctx := context.Background()
+	s := newTestSQLite(t)
+
+	if err := s.CreateRoom(ctx, &Room{ID: "ROOM01", Professor: "prof@example.com", CreatedAt: testTime}); err != nil {
+		t.Fatalf("CreateRoom: %v", err)
+	}
+	for _, id := range []string{"p1", "p2", "p3"} {
+		if err := s.SaveParticipant(ctx, &Participant{ID: id, RoomID: "ROOM01", Name: id, TokenHash: id, JoinedAt: testTime}); err != nil {
+			t.Fatalf("SaveParticipant: %v", err)
+		}
+	}
+
+	q := &StudentQuestion{ID: "sq1", RoomID: "ROOM01", ParticipantID: "p1", Text: "Why?", Anonymous: true, Status: "open", AskedAt: testTime}
+	if err := s.SaveStudentQuestion(ctx, q); err != nil {
+		t.Fatalf("SaveStudentQuestion: %v", err)
+	}
+
+	q.Status, q.Pinned = "answered", true
+	if err := s.SaveStudentQuestion(ctx, q); err != nil {
+		t.Fatalf("SaveStudentQuestion: %v", err)
+	}
+
+	for _, vote := range []struct {
+		participant string
+		up          bool
+	}{{"p2", true}, {"p3", true}, {"p3", false}, {"p2", true}} {
+		if err := s.SetUpvote(ctx, "sq1", vote.participant, vote.up); err != nil {
+			t.Fatalf("SetUpvote: %v", err)
+		}
+	}
+
+	questions, err := s.ListStudentQuestions(ctx, "ROOM01")
+	if err != nil {
+		t.Fatalf("ListStudentQuestions: %v", err)
+	}
+
+	q.Upvoters = []string{"p2"}
+	if len(questions) != 1 || !reflect.DeepEqual(*questions[0], *q) {
+		t.Errorf("questions = %+v, want %+v", questions, q)
+	}
+}
@@ -0,0 +1,285 @@
+package storage
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"mnemo/services/auth"
+	"mnemo/services/poll"
+	"mnemo/services/quiz"
+	"mnemo/services/srs"
+)
+
+const idBytes = 8
+
+var (
+	ErrNotFound = errors.New("not found")
+	ErrConflict = errors.New("already exists")
+)
+
+// Repository persists everything that happens in a room so sessions survive
+// restarts and can be analyzed afterwards.
+type Repository interface {
+	// Ping checks that the backend is reachable.
+	Ping(ctx context.Context) error
+	Close() error
+
+	// Instructors; the lookups satisfy auth.InstructorStore.
+	SaveInstructor(ctx context.Context, instructor *auth.Instructor) error
+	GetInstructor(id string) (*auth.Instructor, error)
+	GetInstructorByEmail(email string) (*auth.Instructor, error)
+
+	// CreateRoom returns ErrConflict if a room with the same ID was ever
+	// created, so join codes are never reused.
+	CreateRoom(ctx context.Context, room *Room) error
+	GetRoom(ctx context.Context, id string) (*Room, error)
+	CloseRoom(ctx context.Context, id string, at time.Time) error
+	ListOpenRooms(ctx context.Context) ([]*Room, error)
+
+	// SaveParticipant inserts or updates a participant.
+	SaveParticipant(ctx context.Context, participant *Participant) error
+	ListParticipants(ctx context.Context, roomID string) ([]*Participant, error)
+
+	// SaveQuestion inserts or updates a question asked in a room.
+	SaveQuestion(ctx context.Context, question *Question) error
+	ListQuestions(ctx context.Context, roomID string) ([]*Question, error)
+
+	// SaveAnswer inserts or updates an answer.
+	SaveAnswer(ctx context.Context, answer *Answer) error
+	ListAnswers(ctx context.Context, roomID string) ([]*Answer, error)
+
+	// SaveRoundResults saves the outcome of scoring a question in one
+	// transaction.
+	SaveRoundResults(ctx context.Context, results *RoundResults) error
+
+	// SaveStudentQuestion inserts or updates a question asked on a room's
+	// Q&A board. Upvotes are saved with SetUpvote.
+	SaveStudentQuestion(ctx context.Context, question *StudentQuestion) error
+	SetUpvote(ctx context.Context, questionID, participantID string, up bool) error
+	ListStudentQuestions(ctx context.Context, roomID string) ([]*StudentQuestion, error)
+
+	// SavePoll inserts or updates a poll run in a room.
+	SavePoll(ctx context.Context, poll *Poll) error
+	ListPolls(ctx context.Context, roomID string) ([]*Poll, error)
+
+	// SavePollResponse inserts a response or replaces the participant's
+	// earlier one.
+	SavePollResponse(ctx context.Context, response *PollResponse) error
+	ListPollResponses(ctx context.Context, roomID string) ([]*PollResponse, error)
+
+	// SetRoomBankPosition records how many bank questions a room has
+	// stepped through.
+	SetRoomBankPosition(ctx context.Context, roomID string, position int) error
+
+	// Question banks
+	CreateBank(ctx context.Context, bank *Bank) error
+	GetBank(ctx context.Context, id string) (*Bank, error)
+	ListBanks(ctx context.Context, owner string) ([]*Bank, error)
+	UpdateBank(ctx context.Context, bank *Bank) error
+	DeleteBank(ctx context.Context, id string) error
+
+	// CreateBankQuestion appends the question to the end of its bank.
+	CreateBankQuestion(ctx context.Context, question *BankQuestion) error
+	// CreateBankQuestions appends the questions in order, all or none.
+	CreateBankQuestions(ctx context.Context, questions []*BankQuestion) error
+	GetBankQuestion(ctx context.Context, bankID, id string) (*BankQuestion, error)
+	UpdateBankQuestion(ctx context.Context, question *BankQuestion) error
+	DeleteBankQuestion(ctx context.Context, bankID, id string) error
+	ListBankQuestions(ctx context.Context, bankID string) ([]*BankQuestion, error)
+
+	// Students and their spaced-repetition state
+	CreateStudent(ctx context.Context, student *Student) error
+	GetStudent(ctx context.Context, id string) (*Student, error)
+	GetStudentByToken(ctx context.Context, tokenHash string) (*Student, error)
+
+	// GetReview returns ErrNotFound for questions the student never answered.
+	GetReview(ctx context.Context, studentID, bankQuestionID string) (*Review, error)
+
+	// RecordReview saves the new scheduling state and appends the attempt
+	// to the student's history.
+	RecordReview(ctx context.Context, review *Review, entry *ReviewLog) error
+
+	// ListDueReviews returns the student's reviews due at or before now,
+	// most overdue first. limit <= 0 means no limit.
+	ListDueReviews(ctx context.Context, studentID string, now time.Time, limit int) ([]*DueReview, error)
+}
+
+type Room struct {
+	ID        string
+	Professor string
+	CreatedAt time.Time
+	ClosedAt  *time.Time
+
+	// BankID is set for rooms started from a question bank
+	BankID       string
+	BankPosition int
+}
+
+type Participant struct {
+	ID        string
+	RoomID    string
+	Name      string
+	TokenHash string // sha256 of the resume token; the token itself is never stored
+	Score     int
+	Streak    int
+	JoinedAt  time.Time
+
+	// StudentID links the participant to a student identity that spans
+	// rooms
+	StudentID string
+}
+
+// Student is a learner across rooms. Like participants they authenticate
+// with a random token of which only the hash is stored.
+type Student struct {
+	ID        string
+	Name      string
+	TokenHash string
+	CreatedAt time.Time
+}
+
+// Review is a student's spaced-repetition state for one bank question.
+type Review struct {
+	srs.Card
+
+	StudentID      string `json:"student_id"`
+	BankQuestionID string `json:"bank_question_id"`
+}
+
+// ReviewLog is one graded attempt at a bank question.
+type ReviewLog struct {
+	StudentID      string
+	BankQuestionID string
+	RoomID         string // empty for self-paced reviews
+	Correct        bool
+	Quality        int
+	ResponseTime   time.Duration
+	ReviewedAt     time.Time
+}
+
+// DueReview is a review together with the question to ask.
+type DueReview struct {
+	*Review
+
+	Question *BankQuestion `json:"question"`
+}
+
+type Question struct {
+	quiz.Question
+
+	RoomID         string
+	BankQuestionID string // empty for questions typed in live
+	PublishedAt    time.Time
+	Deadline       *time.Time
+	ClosedAt       *time.Time
+}
+
+type Answer struct {
+	quiz.Answer
+
+	RoomID string
+}
+
+// RoundResults is what scoring a question changed: the points of the correct
+// answers, the participants' scores and streaks, and the rescheduled reviews
+// of students answering a bank question.
+type RoundResults struct {
+	Answers      []*Answer
+	Participants []*Participant
+	Reviews      []*ReviewUpdate
+}
+
+// ReviewUpdate is a review's new scheduling state and the attempt that led
+// to it.
+type ReviewUpdate struct {
+	Review *Review
+	Entry  *ReviewLog
+}
+
+// StudentQuestion is a question a student asked on a room's Q&A board.
+type StudentQuestion struct {
+	ID            string
+	RoomID        string
+	ParticipantID string
+	Text          string
+	Anonymous     bool // hidden from other students, never from the professor
+	Status        string
+	Pinned        bool
+	AskedAt       time.Time
+
+	// Upvoters are the participants that upvoted the question
+	Upvoters []string
+}
+
+type Poll struct {
+	poll.Poll
+
+	RoomID    string
+	StartedAt time.Time
+	ClosedAt  *time.Time
+}
+
+type PollResponse struct {
+	poll.Response
+
+	RoomID string
+}
+
+const (
+	DifficultyEasy   = "easy"
+	DifficultyMedium = "medium"
+	DifficultyHard   = "hard"
+)
+
+// Bank is a reusable collection of questions owned by an instructor.
+type Bank struct {
+	ID          string    `json:"id"`
+	Owner       string    `json:"owner"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type BankQuestion struct {
+	quiz.Question
+
+	BankID       string   `json:"bank_id"`
+	Position     int      `json:"position"`
+	Tags         []string `json:"tags"`
+	Difficulty   string   `json:"difficulty,omitempty"`
+	DurationSecs int      `json:"duration_secs,omitempty"`
+}
+
+// Validate checks the question itself as well as the bank metadata.
+func (q *BankQuestion) Validate() error {
+	if err := q.Question.Validate(); err != nil {
+		return err
+	}
+
+	switch q.Difficulty {
+	case "", DifficultyEasy, DifficultyMedium, DifficultyHard:
+	default:
+		return errors.Wrapf(quiz.ErrInvalidQuestion, "unknown difficulty '%s'", q.Difficulty)
+	}
+
+	if q.DurationSecs < 0 || time.Duration(q.DurationSecs)*time.Second > quiz.MaxDuration {
+		return errors.Wrapf(quiz.ErrInvalidQuestion, "duration must be between 0 and %d seconds", int(quiz.MaxDuration.Seconds()))
+	}
+
+	return nil
+}
+
+// NewID returns a random identifier for new records.
+func NewID() (string, error) {
+	b := make([]byte, idBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}